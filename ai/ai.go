@@ -0,0 +1,104 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/michalopenmakers/lazyreview/config"
+)
+
+// Finding is a single remark produced by a review provider.
+type Finding struct {
+	File         string `json:"file"`
+	StartLine    int    `json:"start_line"`
+	EndLine      int    `json:"end_line"`
+	Severity     string `json:"severity"`
+	Category     string `json:"category"`
+	Message      string `json:"message"`
+	SuggestedFix string `json:"suggested_fix"`
+}
+
+// ReviewResult holds the outcome of a review: the text shown to the user
+// and the findings extracted from it. Findings are empty when the model
+// answered with free text instead of structured JSON.
+type ReviewResult struct {
+	Text     string
+	Summary  string
+	Findings []Finding
+}
+
+// ReviewRequest describes what a provider should review.
+type ReviewRequest struct {
+	Changes      string
+	IsFullReview bool
+	// PreviousReview is set for incremental reviews: Changes then holds only
+	// the commits pushed since that review, which is passed as context.
+	PreviousReview string
+	// ChunkTokens is the token budget of one request; larger diffs are
+	// split into chunks. See ChunkBudget.
+	ChunkTokens int
+	// FileContext holds the post-change content of changed files. Each
+	// chunk of the diff is sent with the context of its own files.
+	FileContext []FileContext
+}
+
+// FileContext is the content of a changed file at the reviewed commit, whole
+// or as excerpts, with line numbers of the new version.
+type FileContext struct {
+	Path string
+	Text string
+}
+
+// ReviewProvider is implemented by every AI backend able to review a diff.
+type ReviewProvider interface {
+	Name() string
+	Review(ctx context.Context, req ReviewRequest) (*ReviewResult, error)
+}
+
+// Factory builds a provider from the application configuration.
+type Factory func(cfg *config.Config) ReviewProvider
+
+const DefaultProvider = "openai"
+
+var (
+	factoriesMutex sync.RWMutex
+	factories      = make(map[string]Factory)
+)
+
+// Register makes a provider available under the given name. It is meant to be
+// called from the init function of the package implementing the provider.
+func Register(name string, factory Factory) {
+	factoriesMutex.Lock()
+	defer factoriesMutex.Unlock()
+	factories[strings.ToLower(name)] = factory
+}
+
+// Providers returns the names of all registered providers.
+func Providers() []string {
+	factoriesMutex.RLock()
+	defer factoriesMutex.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewProvider returns the provider selected in AIModelConfig.
+func NewProvider(cfg *config.Config) (ReviewProvider, error) {
+	name := strings.ToLower(strings.TrimSpace(cfg.AIModelConfig.Provider))
+	if name == "" {
+		name = DefaultProvider
+	}
+	factoriesMutex.RLock()
+	factory, ok := factories[name]
+	factoriesMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown AI provider %q", name)
+	}
+	return factory(cfg), nil
+}
@@ -0,0 +1,335 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/michalopenmakers/lazyreview/config"
+	"github.com/michalopenmakers/lazyreview/diff"
+)
+
+const (
+	defaultContextWindow = 8192
+	// maxChunkTokens keeps chunks small enough for focused answers even on
+	// models with very large context windows.
+	maxChunkTokens = 24000
+	minChunkTokens = 1000
+	// promptOverheadTokens covers the system prompt and instructions.
+	promptOverheadTokens = 800
+)
+
+// contextWindows maps model name prefixes to their context size in tokens.
+// The longest matching prefix wins.
+var contextWindows = map[string]int{
+	"gpt-3.5":        16385,
+	"gpt-4":          8192,
+	"gpt-4-turbo":    128000,
+	"gpt-4o":         128000,
+	"gpt-4.1":        1000000,
+	"gpt-5":          400000,
+	"o1":             200000,
+	"o3":             200000,
+	"o4":             200000,
+	"claude":         200000,
+	"llama3":         8192,
+	"llama3.1":       128000,
+	"llama3.2":       128000,
+	"codellama":      16384,
+	"qwen2.5-coder":  32768,
+	"deepseek-coder": 16384,
+	"mistral":        32768,
+	"gemma":          8192,
+}
+
+// EstimateTokens approximates the number of tokens in s. About four bytes
+// per token holds for code and English text across current tokenizers.
+func EstimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+func contextWindow(model string) int {
+	model = strings.ToLower(model)
+	if idx := strings.LastIndex(model, "/"); idx >= 0 {
+		model = model[idx+1:]
+	}
+	best, window := 0, defaultContextWindow
+	for prefix, size := range contextWindows {
+		if strings.HasPrefix(model, prefix) && len(prefix) > best {
+			best, window = len(prefix), size
+		}
+	}
+	return window
+}
+
+// ChunkBudget returns how many tokens of diff fit in one review request: the
+// configured AIModelConfig.ChunkTokens, or what is left of the model's
+// context window after the answer and the prompt.
+func ChunkBudget(cfg config.AIModelConfig) int {
+	if cfg.ChunkTokens > 0 {
+		return cfg.ChunkTokens
+	}
+	budget := contextWindow(cfg.Model) - cfg.MaxTokens - promptOverheadTokens
+	if budget > maxChunkTokens {
+		budget = maxChunkTokens
+	}
+	if budget < minChunkTokens {
+		budget = minChunkTokens
+	}
+	return budget
+}
+
+// diffFile is one file of a unified diff: its ---/+++ header and hunks.
+type diffFile struct {
+	header string
+	hunks  []string
+}
+
+func (f diffFile) String() string {
+	return f.header + strings.Join(f.hunks, "")
+}
+
+// splitDiff cuts a combined diff into files and hunks. Text before the first
+// file header is kept as a file without a header.
+func splitDiff(diff string) []diffFile {
+	lines := strings.SplitAfter(diff, "\n")
+	var files []diffFile
+	var hunk strings.Builder
+	flushHunk := func() {
+		if hunk.Len() > 0 {
+			last := &files[len(files)-1]
+			last.hunks = append(last.hunks, hunk.String())
+			hunk.Reset()
+		}
+	}
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") {
+			flushHunk()
+			files = append(files, diffFile{header: line + lines[i+1]})
+			i++
+			continue
+		}
+		if len(files) == 0 {
+			files = append(files, diffFile{})
+		}
+		if strings.HasPrefix(line, "@@") {
+			flushHunk()
+		}
+		hunk.WriteString(line)
+	}
+	flushHunk()
+	return files
+}
+
+// ChunkDiff splits a diff into chunks of at most budget estimated tokens.
+// Chunks break on file boundaries, then on hunk boundaries of files too large
+// for one chunk, and only as a last resort between lines of a single hunk.
+// Every chunk of a split file repeats the file header, and every part of a
+// split hunk gets a hunk header of its own.
+func ChunkDiff(diff string, budget int) []string {
+	if EstimateTokens(diff) <= budget {
+		return []string{diff}
+	}
+	var chunks []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			chunks = append(chunks, current.String())
+			current.Reset()
+		}
+	}
+	add := func(text string) {
+		if EstimateTokens(current.String())+EstimateTokens(text) > budget {
+			flush()
+		}
+		current.WriteString(text)
+	}
+
+	for _, file := range splitDiff(diff) {
+		text := file.String()
+		if EstimateTokens(text) <= budget {
+			add(text)
+			continue
+		}
+		flush()
+		hunkBudget := budget - EstimateTokens(file.header)
+		for _, hunk := range file.hunks {
+			for _, part := range splitHunk(hunk, hunkBudget) {
+				if current.Len() == 0 || EstimateTokens(current.String())+EstimateTokens(part) > budget {
+					flush()
+					current.WriteString(file.header)
+				}
+				current.WriteString(part)
+			}
+		}
+		flush()
+	}
+	flush()
+	return chunks
+}
+
+// splitHunk cuts a hunk into parts of at most budget tokens on line
+// boundaries. Each part starts with an @@ header whose start lines and counts
+// cover just the lines of that part, so its lines can still be parsed and
+// mapped to the file.
+func splitHunk(hunk string, budget int) []string {
+	if EstimateTokens(hunk) <= budget {
+		return []string{hunk}
+	}
+	lines := strings.SplitAfter(hunk, "\n")
+	parsed := diff.ParseHunks(lines[0])
+	if len(parsed) == 0 {
+		// Tekst bez nagłówka hunka nie ma numerów linii do przeliczenia
+		return splitLines(hunk, budget)
+	}
+	// Opis sekcji po drugim "@@", np. nazwa funkcji, trafia do każdej części
+	_, section, _ := strings.Cut(strings.TrimPrefix(strings.TrimRight(lines[0], "\n"), "@@"), "@@")
+	bodyBudget := max(budget-EstimateTokens(lines[0])-2, 1)
+
+	var parts []string
+	var body strings.Builder
+	oldLine, newLine := parsed[0].OldStart, parsed[0].NewStart
+	// Pusty zakres wskazuje linię przed hunkiem
+	if parsed[0].OldLines == 0 {
+		oldLine++
+	}
+	if parsed[0].NewLines == 0 {
+		newLine++
+	}
+	partOld, partNew, oldCount, newCount := oldLine, newLine, 0, 0
+	flush := func() {
+		if body.Len() == 0 {
+			return
+		}
+		parts = append(parts, fmt.Sprintf("@@ -%s +%s @@%s\n", hunkRange(partOld, oldCount), hunkRange(partNew, newCount), section)+body.String())
+		body.Reset()
+		partOld, partNew, oldCount, newCount = oldLine, newLine, 0, 0
+	}
+	for _, line := range lines[1:] {
+		if line == "" {
+			continue
+		}
+		// "\ No newline at end of file" zostaje przy swojej linii
+		if line[0] != '\\' && body.Len() > 0 && EstimateTokens(body.String())+EstimateTokens(line) > bodyBudget {
+			flush()
+		}
+		body.WriteString(line)
+		switch line[0] {
+		case '+':
+			newLine++
+			newCount++
+		case '-':
+			oldLine++
+			oldCount++
+		case '\\':
+		default:
+			oldLine++
+			newLine++
+			oldCount++
+			newCount++
+		}
+	}
+	flush()
+	return parts
+}
+
+// hunkRange formats the start,count pair of a hunk header. An empty range
+// names the line before it, as diff does.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", max(start-1, 0))
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// splitLines cuts text into parts of at most budget tokens on line
+// boundaries. A single line longer than the budget becomes its own part.
+func splitLines(text string, budget int) []string {
+	if EstimateTokens(text) <= budget {
+		return []string{text}
+	}
+	var parts []string
+	var current strings.Builder
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if current.Len() > 0 && EstimateTokens(current.String())+EstimateTokens(line) > budget {
+			parts = append(parts, current.String())
+			current.Reset()
+		}
+		current.WriteString(line)
+	}
+	if current.Len() > 0 {
+		parts = append(parts, current.String())
+	}
+	return parts
+}
+
+// DedupeFindings merges findings reported more than once, typically by
+// reviews of neighbouring chunks. Two findings are duplicates when they point
+// at overlapping lines of the same file, share the category and describe the
+// issue in similar words; the more serious one is kept.
+func DedupeFindings(findings []Finding) []Finding {
+	var unique []Finding
+	for _, f := range findings {
+		merged := false
+		for i := range unique {
+			if !duplicateFindings(unique[i], f) {
+				continue
+			}
+			if SeverityRank(f.Severity) > SeverityRank(unique[i].Severity) {
+				unique[i].Severity = f.Severity
+			}
+			if len(f.Message) > len(unique[i].Message) {
+				unique[i].Message = f.Message
+			}
+			if unique[i].SuggestedFix == "" {
+				unique[i].SuggestedFix = f.SuggestedFix
+			}
+			merged = true
+			break
+		}
+		if !merged {
+			unique = append(unique, f)
+		}
+	}
+	return unique
+}
+
+func duplicateFindings(a, b Finding) bool {
+	if a.File != b.File || !strings.EqualFold(a.Category, b.Category) {
+		return false
+	}
+	if a.StartLine > 0 && b.StartLine > 0 {
+		aEnd, bEnd := max(a.EndLine, a.StartLine), max(b.EndLine, b.StartLine)
+		if a.StartLine > bEnd || b.StartLine > aEnd {
+			return false
+		}
+	}
+	return wordSimilarity(a.Message, b.Message) >= 0.5
+}
+
+// wordSimilarity is the Jaccard index of the sets of words of a and b.
+func wordSimilarity(a, b string) float64 {
+	words := func(s string) map[string]bool {
+		set := make(map[string]bool)
+		for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_')
+		}) {
+			set[w] = true
+		}
+		return set
+	}
+	wa, wb := words(a), words(b)
+	if len(wa) == 0 && len(wb) == 0 {
+		return 1
+	}
+	common := 0
+	for w := range wa {
+		if wb[w] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(wa)+len(wb)-common)
+}
@@ -0,0 +1,294 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/michalopenmakers/lazyreview/diff"
+)
+
+// testFile builds the diff of one file with hunks of n added lines each.
+func testFile(name string, hunks, n int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- a/%s\n+++ b/%s\n", name, name)
+	for h := 0; h < hunks; h++ {
+		fmt.Fprintf(&sb, "@@ -%d,0 +%d,%d @@\n", h*100+1, h*100+1, n)
+		for i := 0; i < n; i++ {
+			fmt.Fprintf(&sb, "+line %d of hunk %d in %s\n", i, h, name)
+		}
+	}
+	return sb.String()
+}
+
+func TestChunkDiff(t *testing.T) {
+	small := testFile("a.go", 1, 3)
+	twoFiles := testFile("a.go", 1, 20) + testFile("b.go", 1, 20)
+	bigFile := testFile("big.go", 4, 20)
+	hugeHunk := testFile("huge.go", 1, 200)
+
+	tests := []struct {
+		name   string
+		diff   string
+		budget int
+		// check verifies the chunks beyond the common invariants
+		check func(t *testing.T, chunks []string)
+	}{
+		{
+			name:   "fits in one chunk",
+			diff:   small,
+			budget: 1000,
+			check: func(t *testing.T, chunks []string) {
+				if len(chunks) != 1 || chunks[0] != small {
+					t.Errorf("chunks = %q, want the diff unchanged", chunks)
+				}
+			},
+		},
+		{
+			name:   "split on file boundaries",
+			diff:   twoFiles,
+			budget: EstimateTokens(twoFiles)/2 + 20,
+			check: func(t *testing.T, chunks []string) {
+				if len(chunks) != 2 || !strings.HasPrefix(chunks[1], "--- a/b.go\n") {
+					t.Errorf("chunks = %q, want one per file", chunks)
+				}
+			},
+		},
+		{
+			name:   "split on hunk boundaries",
+			diff:   bigFile,
+			budget: EstimateTokens(bigFile)/3 + 10,
+			check: func(t *testing.T, chunks []string) {
+				if len(chunks) < 2 {
+					t.Fatalf("got %d chunks, want the file split", len(chunks))
+				}
+				for _, chunk := range chunks {
+					body := strings.TrimPrefix(chunk, "--- a/big.go\n+++ b/big.go\n")
+					if body == chunk {
+						t.Errorf("chunk does not start with the file header: %q", chunk)
+					}
+					if !strings.HasPrefix(body, "@@ ") {
+						t.Errorf("chunk does not start with a hunk: %q", body)
+					}
+				}
+			},
+		},
+		{
+			name:   "split inside a hunk",
+			diff:   hugeHunk,
+			budget: EstimateTokens(hugeHunk) / 4,
+			check: func(t *testing.T, chunks []string) {
+				if len(chunks) < 4 {
+					t.Errorf("got %d chunks, want at least 4", len(chunks))
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := ChunkDiff(tt.diff, tt.budget)
+			var lines []string
+			for _, chunk := range chunks {
+				if tokens := EstimateTokens(chunk); tokens > tt.budget {
+					t.Errorf("chunk of %d tokens exceeds the budget of %d", tokens, tt.budget)
+				}
+				for _, line := range strings.SplitAfter(chunk, "\n") {
+					if strings.HasPrefix(line, "+line") {
+						lines = append(lines, line)
+					}
+				}
+			}
+			// Każda zmieniona linia trafia do dokładnie jednej części
+			if got, want := strings.Join(lines, ""), changedLines(tt.diff); got != want {
+				t.Errorf("chunks lost or repeated diff lines")
+			}
+			tt.check(t, chunks)
+		})
+	}
+}
+
+func TestChunkDiffSplitsHunkHeaders(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("--- a/mixed.go\n+++ b/mixed.go\n@@ -10,150 +10,150 @@ func main() {\n")
+	for i := 0; i < 50; i++ {
+		fmt.Fprintf(&sb, " context %d\n-removed %d\n+added %d\n", i, i, i)
+	}
+	sb.WriteString("\\ No newline at end of file\n")
+	unified := sb.String()
+
+	chunks := ChunkDiff(unified, EstimateTokens(unified)/4)
+	if len(chunks) < 4 {
+		t.Fatalf("got %d chunks, want at least 4", len(chunks))
+	}
+	var got []diff.Line
+	for _, chunk := range chunks {
+		body := strings.TrimPrefix(chunk, "--- a/mixed.go\n+++ b/mixed.go\n")
+		if !strings.HasPrefix(body, "@@ -") || !strings.Contains(strings.SplitN(body, "\n", 2)[0], "@@ func main() {") {
+			t.Errorf("chunk does not start with a hunk header: %q", body)
+		}
+		files := diff.Parse(chunk)
+		if len(files) != 1 {
+			t.Fatalf("chunk parsed into %d files", len(files))
+		}
+		for _, hunk := range files[0].Hunks {
+			oldLines, newLines := 0, 0
+			for _, line := range hunk.Lines {
+				if line.Kind != diff.Added {
+					oldLines++
+				}
+				if line.Kind != diff.Removed {
+					newLines++
+				}
+			}
+			if oldLines != hunk.OldLines || newLines != hunk.NewLines {
+				t.Errorf("header %q does not match %d old and %d new lines", hunk.Header, oldLines, newLines)
+			}
+			got = append(got, hunk.Lines...)
+		}
+	}
+	// Każda linia części ma te same numery co w całym hunku
+	want := diff.Parse(unified)[0].Hunks[0].Lines
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("lines of the parts differ from the hunk:\n%+v\nwant %+v", got, want)
+	}
+	if !strings.HasSuffix(chunks[len(chunks)-1], "+added 49\n\\ No newline at end of file\n") {
+		t.Errorf("last chunk = %q, want the no newline marker after its line", chunks[len(chunks)-1])
+	}
+}
+
+func changedLines(diff string) string {
+	var sb strings.Builder
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		if strings.HasPrefix(line, "+line") {
+			sb.WriteString(line)
+		}
+	}
+	return sb.String()
+}
+
+func TestDedupeFindings(t *testing.T) {
+	nilCheck := Finding{File: "a.go", StartLine: 10, EndLine: 12, Severity: "medium", Category: "bug", Message: "Possible nil pointer dereference of user"}
+	tests := []struct {
+		name     string
+		findings []Finding
+		want     []Finding
+	}{
+		{
+			name: "overlapping lines and similar message",
+			findings: []Finding{
+				nilCheck,
+				{File: "a.go", StartLine: 12, Severity: "high", Category: "Bug", Message: "Possible nil pointer dereference of user here", SuggestedFix: "Check user for nil"},
+			},
+			want: []Finding{
+				{File: "a.go", StartLine: 10, EndLine: 12, Severity: "high", Category: "bug", Message: "Possible nil pointer dereference of user here", SuggestedFix: "Check user for nil"},
+			},
+		},
+		{
+			name: "different files",
+			findings: []Finding{
+				nilCheck,
+				{File: "b.go", StartLine: 10, EndLine: 12, Severity: "medium", Category: "bug", Message: nilCheck.Message},
+			},
+			want: []Finding{
+				nilCheck,
+				{File: "b.go", StartLine: 10, EndLine: 12, Severity: "medium", Category: "bug", Message: nilCheck.Message},
+			},
+		},
+		{
+			name: "lines do not overlap",
+			findings: []Finding{
+				nilCheck,
+				{File: "a.go", StartLine: 40, Severity: "medium", Category: "bug", Message: nilCheck.Message},
+			},
+			want: []Finding{
+				nilCheck,
+				{File: "a.go", StartLine: 40, Severity: "medium", Category: "bug", Message: nilCheck.Message},
+			},
+		},
+		{
+			name: "different problem on the same lines",
+			findings: []Finding{
+				nilCheck,
+				{File: "a.go", StartLine: 11, Severity: "low", Category: "bug", Message: "Loop variable captured by closure"},
+			},
+			want: []Finding{
+				nilCheck,
+				{File: "a.go", StartLine: 11, Severity: "low", Category: "bug", Message: "Loop variable captured by closure"},
+			},
+		},
+		{
+			name: "file level findings",
+			findings: []Finding{
+				{File: "a.go", Severity: "info", Category: "style", Message: "Missing package comment"},
+				{File: "a.go", Severity: "low", Category: "style", Message: "Missing package comment"},
+			},
+			want: []Finding{
+				{File: "a.go", Severity: "low", Category: "style", Message: "Missing package comment"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DedupeFindings(tt.findings); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DedupeFindings() = %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReviewWithMergesChunks(t *testing.T) {
+	diff := testFile("a.go", 1, 200) + testFile("b.go", 1, 200)
+	answers := map[string]string{
+		"a.go": `{"summary": "Part A.", "findings": [{"file": "a.go", "start_line": 5, "end_line": 5, "severity": "high", "category": "bug", "message": "Off by one in loop", "suggested_fix": ""}]}`,
+		"b.go": `{"summary": "Part B.", "findings": [{"file": "b.go", "start_line": 7, "end_line": 7, "severity": "low", "category": "style", "message": "Long line", "suggested_fix": ""}]}`,
+	}
+	merged := `{"summary": "Overall fine.", "findings": [{"file": "a.go", "start_line": 5, "end_line": 5, "severity": "high", "category": "bug", "message": "Off by one in loop", "suggested_fix": ""}]}`
+
+	var mu sync.Mutex
+	var mergePrompt Prompt
+	complete := func(ctx context.Context, prompt Prompt) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case strings.Contains(prompt.User, "--- a/a.go"):
+			return answers["a.go"], nil
+		case strings.Contains(prompt.User, "--- a/b.go"):
+			return answers["b.go"], nil
+		}
+		mergePrompt = prompt
+		return merged, nil
+	}
+
+	result, err := ReviewWith(context.Background(), complete, ReviewRequest{Changes: diff, ChunkTokens: EstimateTokens(diff)/2 + 200})
+	if err != nil {
+		t.Fatalf("ReviewWith: %v", err)
+	}
+	if !strings.Contains(mergePrompt.User, "Part A.") || !strings.Contains(mergePrompt.User, "Long line") {
+		t.Errorf("merge prompt misses the chunk reviews: %q", mergePrompt.User)
+	}
+	if result.Summary != "Overall fine." || len(result.Findings) != 1 {
+		t.Errorf("result = %+v, want the merged review", result)
+	}
+}
+
+func TestReviewWithFailedMerge(t *testing.T) {
+	diff := testFile("a.go", 1, 200) + testFile("b.go", 1, 200)
+	complete := func(ctx context.Context, prompt Prompt) (string, error) {
+		if strings.Contains(prompt.User, "--- a/") {
+			return `{"summary": "Part.", "findings": [{"file": "a.go", "start_line": 5, "end_line": 5, "severity": "high", "category": "bug", "message": "Off by one in loop", "suggested_fix": ""}]}`, nil
+		}
+		return "not JSON", nil
+	}
+
+	result, err := ReviewWith(context.Background(), complete, ReviewRequest{Changes: diff, ChunkTokens: EstimateTokens(diff)/2 + 200})
+	if err != nil {
+		t.Fatalf("ReviewWith: %v", err)
+	}
+	// Ten sam problem z obu części zostaje zgłoszony raz
+	if len(result.Findings) != 1 || result.Summary != "Part.\n\nPart." {
+		t.Errorf("result = %+v, want deduplicated chunk findings", result)
+	}
+}
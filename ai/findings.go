@@ -0,0 +1,169 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Severities lists the finding severities from the most to the least serious.
+var Severities = []string{"critical", "high", "medium", "low", "info"}
+
+// ReviewSchema is the JSON schema the model is asked to follow. It is
+// compatible with OpenAI structured outputs in strict mode.
+var ReviewSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"summary": map[string]any{
+			"type":        "string",
+			"description": "Short overall assessment of the changes.",
+		},
+		"findings": map[string]any{
+			"type": "array",
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"file":          map[string]any{"type": "string", "description": "Path of the file as shown in the diff."},
+					"start_line":    map[string]any{"type": "integer", "description": "First line in the new version of the file, 0 if unknown."},
+					"end_line":      map[string]any{"type": "integer", "description": "Last line in the new version of the file, 0 if unknown."},
+					"severity":      map[string]any{"type": "string", "enum": Severities},
+					"category":      map[string]any{"type": "string", "description": "e.g. bug, security, performance, maintainability, style."},
+					"message":       map[string]any{"type": "string"},
+					"suggested_fix": map[string]any{"type": "string", "description": "Code or description of the fix, empty if none."},
+				},
+				"required":             []string{"file", "start_line", "end_line", "severity", "category", "message", "suggested_fix"},
+				"additionalProperties": false,
+			},
+		},
+	},
+	"required":             []string{"summary", "findings"},
+	"additionalProperties": false,
+}
+
+const findingsInstructions = `Respond only with a JSON object of the form {"summary": string, "findings": [{"file": string, "start_line": integer, "end_line": integer, "severity": "critical"|"high"|"medium"|"low"|"info", "category": string, "message": string, "suggested_fix": string}]}. Line numbers refer to the new version of the file; use 0 when a finding does not apply to specific lines. Do not wrap the JSON in markdown.`
+
+type structuredReview struct {
+	Summary  string    `json:"summary"`
+	Findings []Finding `json:"findings"`
+}
+
+// ParseReview decodes a structured model answer. The second return value is
+// false when the answer is not valid JSON, in which case the caller should
+// fall back to treating it as free text.
+func ParseReview(content string) (*ReviewResult, bool) {
+	trimmed := strings.TrimSpace(content)
+	if strings.HasPrefix(trimmed, "```") {
+		trimmed = strings.TrimPrefix(trimmed, "```json")
+		trimmed = strings.TrimPrefix(trimmed, "```")
+		trimmed = strings.TrimSuffix(trimmed, "```")
+		trimmed = strings.TrimSpace(trimmed)
+	}
+	if !strings.HasPrefix(trimmed, "{") {
+		return nil, false
+	}
+	var parsed structuredReview
+	if err := json.Unmarshal([]byte(trimmed), &parsed); err != nil {
+		return nil, false
+	}
+	for i := range parsed.Findings {
+		parsed.Findings[i].Severity = normalizeSeverity(parsed.Findings[i].Severity)
+	}
+	return &ReviewResult{
+		Text:     FormatReview(parsed.Summary, parsed.Findings),
+		Summary:  parsed.Summary,
+		Findings: parsed.Findings,
+	}, true
+}
+
+func normalizeSeverity(severity string) string {
+	severity = strings.ToLower(strings.TrimSpace(severity))
+	if SeverityRank(severity) < 0 {
+		return "info"
+	}
+	return severity
+}
+
+// SeverityRank returns a number that grows with the seriousness of the
+// severity, or -1 for unknown values.
+func SeverityRank(severity string) int {
+	for i, s := range Severities {
+		if s == severity {
+			return len(Severities) - 1 - i
+		}
+	}
+	return -1
+}
+
+// Location returns the file and line range of the finding in path:line form.
+func (f Finding) Location() string {
+	switch {
+	case f.File == "":
+		return ""
+	case f.StartLine <= 0:
+		return f.File
+	case f.EndLine > f.StartLine:
+		return fmt.Sprintf("%s:%d-%d", f.File, f.StartLine, f.EndLine)
+	default:
+		return fmt.Sprintf("%s:%d", f.File, f.StartLine)
+	}
+}
+
+// Format renders the finding as markdown.
+func (f Finding) Format() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("**[%s]**", strings.ToUpper(f.Severity)))
+	if f.Category != "" {
+		sb.WriteString(" " + f.Category)
+	}
+	if location := f.Location(); location != "" {
+		sb.WriteString(" `" + location + "`")
+	}
+	sb.WriteString("\n" + f.Message + "\n")
+	if strings.TrimSpace(f.SuggestedFix) != "" {
+		sb.WriteString("\nSuggested fix:\n" + f.SuggestedFix + "\n")
+	}
+	return sb.String()
+}
+
+// FormatReview renders a summary and its findings as markdown text, most
+// serious findings first.
+func FormatReview(summary string, findings []Finding) string {
+	var sb strings.Builder
+	if summary != "" {
+		sb.WriteString(summary + "\n")
+	}
+	sorted := make([]Finding, len(findings))
+	copy(sorted, findings)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return SeverityRank(sorted[i].Severity) > SeverityRank(sorted[j].Severity)
+	})
+	for _, f := range sorted {
+		sb.WriteString("\n" + f.Format())
+	}
+	return sb.String()
+}
+
+// CountBySeverity returns the number of findings of each severity.
+func CountBySeverity(findings []Finding) map[string]int {
+	counts := make(map[string]int)
+	for _, f := range findings {
+		counts[f.Severity]++
+	}
+	return counts
+}
+
+// SeveritySummary describes the findings count, e.g. "1 high, 3 low".
+func SeveritySummary(findings []Finding) string {
+	if len(findings) == 0 {
+		return "no findings"
+	}
+	counts := CountBySeverity(findings)
+	var parts []string
+	for _, severity := range Severities {
+		if counts[severity] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[severity], severity))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
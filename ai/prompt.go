@@ -0,0 +1,292 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/michalopenmakers/lazyreview/diff"
+	"github.com/michalopenmakers/lazyreview/logger"
+)
+
+const (
+	fullReviewPrompt   = "You are an experienced developer performing a complete code analysis. This is the project's first review, so analyze the project structure, code quality, potential security issues, performance and adherence to best practices. Be specific and helpful. Provide solution examples when possible."
+	mergeRequestPrompt = "You are an experienced developer performing a merge request code review. Please review the following merge request changes, analyze for bugs, security vulnerabilities, performance issues, and suggest improvements. Be specific and helpful. Provide solution examples when possible."
+	incrementalPrompt  = "You are an experienced developer continuing a merge request code review. New commits were pushed after your previous review. Review only the new changes, analyze them for bugs, security vulnerabilities, performance issues, and check whether they address your previous remarks. Do not repeat remarks that still apply unchanged. Be specific and helpful."
+	mergePrompt        = "You are an experienced developer finishing a merge request code review. The diff was too large for one request, so its parts were reviewed separately. Combine the summaries into one overall assessment and merge findings that describe the same problem. Keep every distinct finding with its file and lines, do not add new findings."
+	contextPrompt      = "The current content of the changed files is provided before the diff for reference, with line numbers of the new version. Use it to check declarations and usages outside the changed hunks, but report findings only for the changes in the diff."
+	// previousReviewLimit caps the previous review passed as context.
+	previousReviewLimit = 4000
+	// defaultChunkTokens is used when the request does not set a budget.
+	defaultChunkTokens = 6000
+	// chunkConcurrency limits parallel requests for chunks of one review.
+	chunkConcurrency = 4
+)
+
+// Prompt is a single system/user message pair sent to a model.
+type Prompt struct {
+	System string
+	User   string
+}
+
+// CompleteFunc sends one prompt to a model and returns its answer.
+type CompleteFunc func(ctx context.Context, prompt Prompt) (string, error)
+
+// BuildPrompts prepares the prompts for a review. Diffs larger than
+// req.ChunkTokens are split by ChunkDiff into parts reviewed separately.
+func BuildPrompts(req ReviewRequest) []Prompt {
+	codeChanges := PreprocessDiff(req.Changes)
+	budget := req.ChunkTokens
+	if budget <= 0 {
+		budget = defaultChunkTokens
+	}
+
+	systemPrompt := mergeRequestPrompt
+	userPrefix := "Please review the following merge request code diff and provide actionable feedback:\n\n"
+	switch {
+	case req.PreviousReview != "" && !req.IsFullReview:
+		previous := req.PreviousReview
+		if len(previous) > previousReviewLimit {
+			previous = previous[:previousReviewLimit] + "\n[...]"
+		}
+		systemPrompt = incrementalPrompt
+		userPrefix = "Your previous review of this merge request:\n\n" + previous + "\n\nReview the following changes pushed since then:\n\n"
+	case req.IsFullReview:
+		systemPrompt = fullReviewPrompt
+	}
+	// Kontekst plików nie jest dzielony, więc zmniejsza budżet na diff
+	budget -= EstimateTokens(userPrefix) + contextTokens(req.FileContext)
+	if budget < minChunkTokens {
+		budget = minChunkTokens
+	}
+
+	chunks := ChunkDiff(codeChanges, budget)
+	prompts := make([]Prompt, 0, len(chunks))
+	for idx, chunk := range chunks {
+		system := systemPrompt + "\n\n" + findingsInstructions
+		if len(chunks) > 1 {
+			system += fmt.Sprintf("\n\nThe diff is split into %d parts reviewed separately; this is part %d. Review only the files shown.", len(chunks), idx+1)
+		}
+		fileContext := formatFileContext(chunkContext(chunk, req.FileContext))
+		if fileContext != "" {
+			system += "\n\n" + contextPrompt
+		}
+		prompts = append(prompts, Prompt{
+			System: system,
+			User:   fileContext + userPrefix + chunk,
+		})
+	}
+	return prompts
+}
+
+// chunkContext selects the file context of the files present in chunk.
+func chunkContext(chunk string, files []FileContext) []FileContext {
+	if len(files) == 0 {
+		return nil
+	}
+	paths := make(map[string]bool)
+	for _, file := range diff.Parse(chunk) {
+		paths[file.NewPath] = true
+	}
+	var selected []FileContext
+	for _, file := range files {
+		if paths[file.Path] {
+			selected = append(selected, file)
+		}
+	}
+	return selected
+}
+
+func formatFileContext(files []FileContext) string {
+	if len(files) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("Current content of the changed files:\n\n")
+	for _, file := range files {
+		sb.WriteString(fmt.Sprintf("### %s\n```\n%s\n```\n\n", file.Path, strings.TrimRight(file.Text, "\n")))
+	}
+	return sb.String()
+}
+
+func contextTokens(files []FileContext) int {
+	tokens := 0
+	for _, file := range files {
+		tokens += EstimateTokens(file.Path) + EstimateTokens(file.Text) + 4
+	}
+	return tokens
+}
+
+// ReviewWith runs a review using the given completion function. Chunks of a
+// large diff are reviewed in parallel and their answers merged into a single
+// review by a final pass. Answers that are not valid structured JSON are kept
+// as free text. The review stops early when ctx is cancelled.
+func ReviewWith(ctx context.Context, complete CompleteFunc, req ReviewRequest) (*ReviewResult, error) {
+	prompts := BuildPrompts(req)
+	if len(prompts) > 1 {
+		logger.Log(fmt.Sprintf("Diff split into %d chunks, reviewing them in parallel", len(prompts)))
+	} else {
+		logger.Log("Sending API request for merge request review")
+	}
+	contents, err := completeAll(ctx, complete, prompts)
+	if err != nil {
+		return nil, err
+	}
+
+	var texts, summaries []string
+	var findings []Finding
+	structured := true
+	for _, content := range contents {
+		logger.Log("AI response: " + content)
+		parsed, ok := ParseReview(content)
+		if !ok {
+			logger.Log("AI response is not structured JSON, using it as free text")
+			texts = append(texts, content)
+			structured = false
+			continue
+		}
+		texts = append(texts, parsed.Text)
+		if parsed.Summary != "" {
+			summaries = append(summaries, parsed.Summary)
+		}
+		findings = append(findings, parsed.Findings...)
+	}
+
+	if len(prompts) > 1 && structured {
+		findings = DedupeFindings(findings)
+		if merged, err := mergeReviews(ctx, complete, summaries, findings); err != nil {
+			logger.Log(fmt.Sprintf("Final merge pass failed, combining chunk reviews: %v", err))
+		} else {
+			logger.Log(fmt.Sprintf("Received merged code review (%s)", SeveritySummary(merged.Findings)))
+			return merged, nil
+		}
+		summary := strings.Join(summaries, "\n\n")
+		logger.Log(fmt.Sprintf("Received API response for code review (%s)", SeveritySummary(findings)))
+		return &ReviewResult{
+			Text:     FormatReview(summary, findings),
+			Summary:  summary,
+			Findings: findings,
+		}, nil
+	}
+
+	logger.Log(fmt.Sprintf("Received API response for code review (%s)", SeveritySummary(findings)))
+	return &ReviewResult{
+		Text:     strings.Join(texts, "\n"),
+		Summary:  strings.Join(summaries, "\n\n"),
+		Findings: findings,
+	}, nil
+}
+
+// completeAll sends the prompts concurrently, at most chunkConcurrency at a
+// time, and returns the answers in prompt order. The first error cancels
+// the remaining requests.
+func completeAll(ctx context.Context, complete CompleteFunc, prompts []Prompt) ([]string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	contents := make([]string, len(prompts))
+	errs := make([]error, len(prompts))
+	sem := make(chan struct{}, chunkConcurrency)
+	var wg sync.WaitGroup
+	for idx, prompt := range prompts {
+		wg.Add(1)
+		go func(idx int, prompt Prompt) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if err := ctx.Err(); err != nil {
+				errs[idx] = err
+				return
+			}
+			logger.Log("System prompt sent to AI: " + prompt.System)
+			logger.Log("User prompt sent to AI: " + prompt.User)
+			content, err := complete(ctx, prompt)
+			if err != nil {
+				errs[idx] = err
+				cancel()
+				return
+			}
+			contents[idx] = content
+		}(idx, prompt)
+	}
+	wg.Wait()
+
+	for idx, err := range errs {
+		if err != nil {
+			if len(prompts) > 1 {
+				return nil, fmt.Errorf("chunk %d of %d: %w", idx+1, len(prompts), err)
+			}
+			return nil, err
+		}
+	}
+	return contents, nil
+}
+
+// mergeReviews asks the model to combine the reviews of all chunks into one
+// coherent review, merging findings that describe the same problem.
+func mergeReviews(ctx context.Context, complete CompleteFunc, summaries []string, findings []Finding) (*ReviewResult, error) {
+	findingsJSON, err := json.Marshal(findings)
+	if err != nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	sb.WriteString("Summaries of the parts:\n")
+	for idx, summary := range summaries {
+		sb.WriteString(fmt.Sprintf("%d. %s\n", idx+1, summary))
+	}
+	sb.WriteString("\nFindings of all parts:\n")
+	sb.Write(findingsJSON)
+
+	logger.Log("Sending final merge pass request")
+	content, err := complete(ctx, Prompt{
+		System: mergePrompt + "\n\n" + findingsInstructions,
+		User:   sb.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	logger.Log("AI response: " + content)
+	merged, ok := ParseReview(content)
+	if !ok {
+		return nil, fmt.Errorf("merge pass answer is not structured JSON")
+	}
+	return merged, nil
+}
+
+// PreprocessDiff drops blank lines and "index" lines between the files of a
+// diff. Hunk lines are kept as they are, since the line numbers of findings
+// and chunks are counted from them.
+func PreprocessDiff(unified string) string {
+	lines := strings.Split(unified, "\n")
+	filtered := make([]string, 0, len(lines))
+	oldLeft, newLeft := 0, 0
+	for _, line := range lines {
+		if oldLeft > 0 || newLeft > 0 {
+			filtered = append(filtered, line)
+			switch {
+			case strings.HasPrefix(line, "-"):
+				oldLeft--
+			case strings.HasPrefix(line, "+"):
+				newLeft--
+			case strings.HasPrefix(line, "\\"):
+			default:
+				// Linia kontekstu, także pusta po obcięciu spacji
+				oldLeft--
+				newLeft--
+			}
+			continue
+		}
+		if hunks := diff.ParseHunks(line); len(hunks) == 1 {
+			oldLeft, newLeft = hunks[0].OldLines, hunks[0].NewLines
+			filtered = append(filtered, line)
+			continue
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "index ") {
+			continue
+		}
+		filtered = append(filtered, line)
+	}
+	return strings.Join(filtered, "\n")
+}
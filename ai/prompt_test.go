@@ -0,0 +1,39 @@
+package ai
+
+import "testing"
+
+func TestPreprocessDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		diff string
+		want string
+	}{
+		{
+			name: "header metadata",
+			diff: "diff --git a/a.go b/a.go\nindex 1234567..89abcde 100644\n--- a/a.go\n+++ b/a.go\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n\n\n",
+			want: "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1,2 +1,2 @@\n a\n-b\n+c",
+		},
+		{
+			name: "comments and blank lines inside hunks",
+			diff: "--- a/a.py\n+++ b/a.py\n@@ -1,4 +1,5 @@\n # header\n \n-import os\n+# TODO: remove\n+\n x = 1\n",
+			want: "--- a/a.py\n+++ b/a.py\n@@ -1,4 +1,5 @@\n # header\n \n-import os\n+# TODO: remove\n+\n x = 1",
+		},
+		{
+			name: "context line stripped of its space",
+			diff: "--- a/a.go\n+++ b/a.go\n@@ -1,3 +1,3 @@\n a\n\n-b\n+c\n",
+			want: "--- a/a.go\n+++ b/a.go\n@@ -1,3 +1,3 @@\n a\n\n-b\n+c",
+		},
+		{
+			name: "no newline marker",
+			diff: "--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-a\n\\ No newline at end of file\n+b\n\\ No newline at end of file\n",
+			want: "--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-a\n\\ No newline at end of file\n+b\n\\ No newline at end of file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PreprocessDiff(tt.diff); got != tt.want {
+				t.Errorf("PreprocessDiff() = %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/michalopenmakers/lazyreview/ai"
+	"github.com/michalopenmakers/lazyreview/config"
+	"github.com/michalopenmakers/lazyreview/httpclient"
+	"github.com/michalopenmakers/lazyreview/logger"
+)
+
+const (
+	DefaultBaseUrl = "https://api.anthropic.com/v1"
+	apiVersion     = "2023-06-01"
+)
+
+type MessagesRequest struct {
+	Model     string    `json:"model"`
+	System    string    `json:"system,omitempty"`
+	Messages  []Message `json:"messages"`
+	MaxTokens int       `json:"max_tokens"`
+}
+
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type MessagesResponse struct {
+	ID      string `json:"id"`
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StopReason string `json:"stop_reason"`
+}
+
+// Provider reviews code using the Anthropic Messages API.
+type Provider struct {
+	ApiUrl    string
+	ApiKey    string
+	Model     string
+	MaxTokens int
+	Client    *httpclient.Client
+}
+
+func init() {
+	ai.Register("anthropic", NewProvider)
+}
+
+func NewProvider(cfg *config.Config) ai.ReviewProvider {
+	return &Provider{
+		ApiUrl:    cfg.AIModelConfig.GetApiUrl(DefaultBaseUrl, "/messages"),
+		ApiKey:    cfg.AIModelConfig.ApiKey,
+		Model:     cfg.AIModelConfig.Model,
+		MaxTokens: cfg.AIModelConfig.MaxTokens,
+		Client:    httpclient.NewSafePost("anthropic", 60*time.Second),
+	}
+}
+
+func (p *Provider) Name() string {
+	return "anthropic"
+}
+
+func (p *Provider) Review(ctx context.Context, req ai.ReviewRequest) (*ai.ReviewResult, error) {
+	logger.Log("Starting Anthropic CodeReview request")
+	return ai.ReviewWith(ctx, p.complete, req)
+}
+
+func (p *Provider) complete(ctx context.Context, prompt ai.Prompt) (string, error) {
+	requestBody, err := json.Marshal(MessagesRequest{
+		Model:  p.Model,
+		System: prompt.System,
+		Messages: []Message{
+			{Role: "user", Content: prompt.User},
+		},
+		MaxTokens: p.MaxTokens,
+	})
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error marshaling request: %v", err))
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", p.ApiUrl, bytes.NewBuffer(requestBody))
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error creating request: %v", err))
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", p.ApiKey)
+	req.Header.Set("anthropic-version", apiVersion)
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		logger.Log(fmt.Sprintf("HTTP request error: %v", err))
+		return "", err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			logger.Log(fmt.Sprintf("Error closing response body: %v", err))
+		}
+	}(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		errMsg := fmt.Sprintf("Anthropic API responded with status code: %d, body: %s", resp.StatusCode, string(body))
+		logger.Log(errMsg)
+		return "", fmt.Errorf(errMsg)
+	}
+	var response MessagesResponse
+	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		logger.Log(fmt.Sprintf("Error decoding response: %v", err))
+		return "", err
+	}
+	var text strings.Builder
+	for _, block := range response.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if text.Len() == 0 {
+		errMsg := "No text content returned"
+		logger.Log(errMsg)
+		return "", fmt.Errorf(errMsg)
+	}
+	if response.StopReason == "max_tokens" {
+		logger.Log("Anthropic response was truncated by max_tokens")
+	}
+	return text.String(), nil
+}
@@ -0,0 +1,114 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/michalopenmakers/lazyreview/ai"
+	"github.com/michalopenmakers/lazyreview/httpclient"
+)
+
+const testDiff = "--- a/main.go\n+++ b/main.go\n@@ -1,2 +1,3 @@\n package main\n+var x = 1\n func main() {}\n"
+
+func newTestProvider(url string) *Provider {
+	client := httpclient.NewSafePost("anthropic-test", 5*time.Second)
+	client.MaxRetries = 0
+	return &Provider{
+		ApiUrl:    url,
+		ApiKey:    "sk-ant-test",
+		Model:     "claude-sonnet-4",
+		MaxTokens: 2000,
+		Client:    client,
+	}
+}
+
+func TestReview(t *testing.T) {
+	var got MessagesRequest
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		// Odpowiedź w dwóch blokach tekstu, przedzielonych blokiem innego typu
+		_, _ = w.Write([]byte(`{"id": "msg_1", "stop_reason": "end_turn", "content": [
+			{"type": "text", "text": "{\"summary\": \"Looks fine.\", \"findings\": [{\"file\": \"main.go\", "},
+			{"type": "thinking", "text": "ignored"},
+			{"type": "text", "text": "\"start_line\": 2, \"end_line\": 0, \"severity\": \"low\", \"category\": \"style\", \"message\": \"Unused variable\", \"suggested_fix\": \"Remove x\"}]}"}
+		]}`))
+	}))
+	defer srv.Close()
+
+	result, err := newTestProvider(srv.URL).Review(context.Background(), ai.ReviewRequest{Changes: testDiff})
+	if err != nil {
+		t.Fatalf("Review: %v", err)
+	}
+
+	if header.Get("x-api-key") != "sk-ant-test" || header.Get("anthropic-version") != apiVersion {
+		t.Errorf("headers = %v", header)
+	}
+	if got.Model != "claude-sonnet-4" || got.MaxTokens != 2000 {
+		t.Errorf("model %q, max tokens %d", got.Model, got.MaxTokens)
+	}
+	if got.System == "" {
+		t.Error("system prompt is empty")
+	}
+	if len(got.Messages) != 1 || got.Messages[0].Role != "user" || !strings.Contains(got.Messages[0].Content, "+var x = 1") {
+		t.Errorf("messages = %+v", got.Messages)
+	}
+
+	if result.Summary != "Looks fine." {
+		t.Errorf("summary = %q", result.Summary)
+	}
+	want := ai.Finding{File: "main.go", StartLine: 2, Severity: "low", Category: "style", Message: "Unused variable", SuggestedFix: "Remove x"}
+	if len(result.Findings) != 1 || result.Findings[0] != want {
+		t.Errorf("findings = %+v, want %+v", result.Findings, want)
+	}
+}
+
+func TestReviewFreeText(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"content": [{"type": "text", "text": "The change looks good."}]}`))
+	}))
+	defer srv.Close()
+
+	result, err := newTestProvider(srv.URL).Review(context.Background(), ai.ReviewRequest{Changes: testDiff})
+	if err != nil {
+		t.Fatalf("Review: %v", err)
+	}
+	if result.Text != "The change looks good." || len(result.Findings) != 0 {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestReviewErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"unauthorized", http.StatusUnauthorized, `{"type": "error", "error": {"type": "authentication_error"}}`},
+		{"overloaded", 529, `{"type": "error", "error": {"type": "overloaded_error"}}`},
+		{"no text", http.StatusOK, `{"content": [{"type": "tool_use"}]}`},
+		{"invalid JSON", http.StatusOK, `{"content": [`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			result, err := newTestProvider(srv.URL).Review(context.Background(), ai.ReviewRequest{Changes: testDiff})
+			if err == nil {
+				t.Fatalf("Review returned %+v, want an error", result)
+			}
+		})
+	}
+}
@@ -5,10 +5,13 @@ import (
 	"github.com/michalopenmakers/lazyreview/config"
 	"github.com/michalopenmakers/lazyreview/review"
 	"github.com/michalopenmakers/lazyreview/store"
+	"github.com/michalopenmakers/lazyreview/webhook"
 )
 
 func InitializeApplication(cfg *config.Config) {
+	review.LoadReviews()
 	review.StartMonitoring(cfg)
+	webhook.Start(cfg)
 }
 
 func GetReviews() []review.CodeReview {
@@ -19,6 +22,21 @@ func GetReviews() []review.CodeReview {
 }
 
 func RestartMonitoring(cfg *config.Config) {
-	review.StopMonitoring()
+	StopMonitoring()
 	review.StartMonitoring(cfg)
+	webhook.Start(cfg)
+}
+
+// StopMonitoring stops the webhook server and the monitoring, webhook first
+// so no event arrives without a queue.
+func StopMonitoring() {
+	webhook.Stop()
+	review.StopMonitoring()
+}
+
+// StopMonitoringAndWait stops like StopMonitoring and waits for the running
+// reviews, for callers that close the state right after.
+func StopMonitoringAndWait() {
+	webhook.Stop()
+	review.StopMonitoringAndWait()
 }
@@ -0,0 +1,179 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/michalopenmakers/lazyreview/ai"
+	"github.com/michalopenmakers/lazyreview/config"
+	"github.com/michalopenmakers/lazyreview/review"
+)
+
+// findingsExitCode is returned by the ci command when findings reach the
+// failing severity, so pipelines can tell them apart from errors.
+const findingsExitCode = 3
+
+// ciChange is the merge or pull request a pipeline job runs for.
+type ciChange struct {
+	source  string
+	project string
+	number  int
+}
+
+// runCI reviews the merge or pull request of the current GitLab CI or GitHub
+// Actions job, posts the review and fails when findings reach the
+// configured severity. Settings can be passed as LAZYREVIEW_* variables.
+func runCI(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlagSet("ci")
+	failOn := fs.String("fail-on", "", "lowest failing severity: critical, high, medium, low, info or none")
+	verdictFlag := fs.String("verdict", string(review.VerdictComment), "comment, request_changes, approve, or auto to suggest one from the findings")
+	noPost := fs.Bool("no-post", false, "print the review without posting it")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errUsage
+	}
+
+	if err := config.ApplyEnv(cfg, os.LookupEnv); err != nil {
+		return err
+	}
+	threshold := *failOn
+	if threshold == "" {
+		threshold = cfg.CIFailOnSeverity
+	}
+	if threshold == "" {
+		threshold = config.DefaultCIFailOnSeverity
+	}
+	if threshold != "none" && ai.SeverityRank(threshold) < 0 {
+		return fmt.Errorf("%w: unknown severity %q", errUsage, threshold)
+	}
+	if *verdictFlag != "auto" && !validVerdict(review.Verdict(*verdictFlag)) {
+		return fmt.Errorf("%w: unknown verdict %q", errUsage, *verdictFlag)
+	}
+
+	change, err := detectCIChange(cfg, os.Getenv)
+	if err != nil {
+		return err
+	}
+	var r review.CodeReview
+	if change.source == "gitlab" {
+		r, err = review.ReviewMergeRequest(ctx, cfg, change.project, change.number)
+	} else {
+		r, err = review.ReviewPullRequest(ctx, cfg, change.project, change.number)
+	}
+	if err != nil {
+		return err
+	}
+	printReview(r)
+
+	if !*noPost {
+		verdict := review.Verdict(*verdictFlag)
+		if *verdictFlag == "auto" {
+			verdict = review.DefaultVerdict(r)
+		}
+		if err := review.SubmitReview(ctx, cfg, r.ID, verdict); err != nil {
+			return fmt.Errorf("posting the review: %w", err)
+		}
+		fmt.Fprintf(stdout, "\nPosted the review (%s)\n", verdict.Label())
+	}
+
+	if threshold == "none" {
+		return nil
+	}
+	failing := 0
+	for _, f := range r.Findings {
+		if ai.SeverityRank(f.Severity) >= ai.SeverityRank(threshold) {
+			failing++
+		}
+	}
+	if failing > 0 {
+		return exitError{
+			code: findingsExitCode,
+			err:  fmt.Errorf("%d findings of %s severity or above", failing, threshold),
+		}
+	}
+	return nil
+}
+
+func validVerdict(verdict review.Verdict) bool {
+	for _, v := range review.Verdicts {
+		if v == verdict {
+			return true
+		}
+	}
+	return false
+}
+
+// detectCIChange reads the merge or pull request from the variables of the
+// GitLab CI or GitHub Actions job and points cfg at the job's API and token.
+func detectCIChange(cfg *config.Config, getenv func(string) string) (ciChange, error) {
+	switch {
+	case getenv("GITLAB_CI") != "":
+		iid := getenv("CI_MERGE_REQUEST_IID")
+		if iid == "" {
+			return ciChange{}, errors.New("not a merge request pipeline: run the job with rules: - if: $CI_PIPELINE_SOURCE == \"merge_request_event\"")
+		}
+		number, err := strconv.Atoi(iid)
+		if err != nil {
+			return ciChange{}, fmt.Errorf("invalid CI_MERGE_REQUEST_IID %q", iid)
+		}
+		project := getenv("CI_PROJECT_ID")
+		if project == "" {
+			return ciChange{}, errors.New("CI_PROJECT_ID is not set")
+		}
+		if apiUrl := getenv("CI_API_V4_URL"); apiUrl != "" {
+			cfg.GitLabConfig.ApiUrl = apiUrl
+		}
+		if cfg.GitLabConfig.ApiToken == "" {
+			cfg.GitLabConfig.ApiToken = getenv("GITLAB_TOKEN")
+		}
+		if cfg.GitLabConfig.ApiToken == "" {
+			return ciChange{}, fmt.Errorf("no GitLab token: set GITLAB_TOKEN or %s", config.EnvName("GitLabConfig.ApiToken"))
+		}
+		return ciChange{source: "gitlab", project: project, number: number}, nil
+
+	case getenv("GITHUB_ACTIONS") != "":
+		eventPath := getenv("GITHUB_EVENT_PATH")
+		if eventPath == "" {
+			return ciChange{}, errors.New("GITHUB_EVENT_PATH is not set")
+		}
+		data, err := os.ReadFile(eventPath)
+		if err != nil {
+			return ciChange{}, err
+		}
+		var event struct {
+			PullRequest *struct {
+				Number int `json:"number"`
+			} `json:"pull_request"`
+			Repository struct {
+				FullName string `json:"full_name"`
+			} `json:"repository"`
+		}
+		if err := json.Unmarshal(data, &event); err != nil {
+			return ciChange{}, fmt.Errorf("reading %s: %w", eventPath, err)
+		}
+		if event.PullRequest == nil {
+			return ciChange{}, fmt.Errorf("not a pull request event (%s): trigger the workflow on pull_request", getenv("GITHUB_EVENT_NAME"))
+		}
+		repository := event.Repository.FullName
+		if repository == "" {
+			repository = getenv("GITHUB_REPOSITORY")
+		}
+		if apiUrl := getenv("GITHUB_API_URL"); apiUrl != "" {
+			cfg.GitHubConfig.ApiUrl = apiUrl
+		}
+		if cfg.GitHubConfig.ApiToken == "" {
+			cfg.GitHubConfig.ApiToken = getenv("GITHUB_TOKEN")
+		}
+		if cfg.GitHubConfig.ApiToken == "" {
+			return ciChange{}, fmt.Errorf("no GitHub token: set GITHUB_TOKEN or %s", config.EnvName("GitHubConfig.ApiToken"))
+		}
+		return ciChange{source: "github", project: repository, number: event.PullRequest.Number}, nil
+	}
+	return ciChange{}, errors.New("no merge or pull request in the environment: ci runs in GitLab CI merge request pipelines and GitHub Actions pull_request workflows")
+}
@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+
+	"github.com/michalopenmakers/lazyreview/config"
+	"github.com/michalopenmakers/lazyreview/state"
+)
+
+// command is a subcommand of the lazyreview binary.
+type command struct {
+	usage   string
+	summary string
+	run     func(ctx context.Context, cfg *config.Config, args []string) error
+	// state marks the commands that read or store reviews
+	state bool
+}
+
+var commands = map[string]command{
+	"daemon": {
+		usage:   "daemon",
+		summary: "monitor merge and pull requests without the GUI",
+		run:     runDaemon,
+		state:   true,
+	},
+	"review": {
+		usage:   "review URL",
+		summary: "review one merge or pull request",
+		run:     runReview,
+		state:   true,
+	},
+	"local": {
+		usage:   "local [-base BRANCH] [-staged] [DIR]",
+		summary: "review a local branch or the staged changes",
+		run:     runLocal,
+		state:   true,
+	},
+	"ci": {
+		usage:   "ci [-fail-on SEVERITY] [-verdict VERDICT|auto] [-no-post]",
+		summary: "review the merge or pull request of a GitLab CI or GitHub Actions job",
+		run:     runCI,
+		state:   true,
+	},
+	"list": {
+		usage:   "list [-json]",
+		summary: "list the generated reviews",
+		run:     runList,
+		state:   true,
+	},
+	"post": {
+		usage:   "post [-verdict comment|request_changes|approve] ID",
+		summary: "submit a review to its merge or pull request",
+		run:     runPost,
+		state:   true,
+	},
+	"config": {
+		usage:   "config get [KEY] | config set KEY VALUE",
+		summary: "show or change the configuration",
+		run:     runConfig,
+	},
+	"state": {
+		usage:   "state show [-json] [ID]",
+		summary: "show the stored state or the history of a review",
+		run:     runState,
+		state:   true,
+	},
+}
+
+// exitError makes Run exit with a code other than 1.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e exitError) Error() string {
+	return e.err.Error()
+}
+
+// errUsage makes Run print the usage of the command and exit with code 2.
+var errUsage = errors.New("invalid usage")
+
+// stdout is where commands print their results; logs go to stderr.
+var stdout io.Writer = os.Stdout
+
+// Run executes the subcommand named by args[0] and returns the exit code.
+// Interrupting or terminating the process cancels the command.
+func Run(cfg *config.Config, args []string) int {
+	if len(args) == 0 {
+		printUsage()
+		return 2
+	}
+	name := args[0]
+	cmd, ok := commands[name]
+	if !ok {
+		if name != "help" && name != "-h" && name != "--help" {
+			fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		}
+		printUsage()
+		return 2
+	}
+
+	if cmd.state {
+		if err := state.Init(cfg.StorageBackend); err != nil {
+			fmt.Fprintf(os.Stderr, "lazyreview: %v\n", err)
+			return 1
+		}
+		defer state.Close()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err := cmd.run(ctx, cfg, args[1:])
+	var exit exitError
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp):
+		if err != errUsage && err != flag.ErrHelp {
+			fmt.Fprintf(os.Stderr, "lazyreview %s: %v\n", name, err)
+		}
+		fmt.Fprintf(os.Stderr, "usage: lazyreview %s\n", cmd.usage)
+		return 2
+	case errors.As(err, &exit):
+		fmt.Fprintf(os.Stderr, "lazyreview %s: %v\n", name, err)
+		return exit.code
+	default:
+		fmt.Fprintf(os.Stderr, "lazyreview %s: %v\n", name, err)
+		return 1
+	}
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "usage: lazyreview [command] [arguments]")
+	fmt.Fprintln(os.Stderr, "\nWithout a command the desktop application is started. Commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-52s %s\n", commands[name].usage, commands[name].summary)
+	}
+}
+
+// newFlagSet creates the flag set of a command; parse errors are returned by
+// parseFlags and reported by Run.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+// parseFlags parses the arguments of a command and wraps a parse error in
+// errUsage, so it is printed together with the usage.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if err == nil || errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", errUsage, err)
+}
@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/michalopenmakers/lazyreview/config"
+)
+
+// runConfig reads and changes ~/.lazyreview_config.json. Keys are dotted
+// field paths such as GitLabConfig.ApiUrl, matched without regard to case.
+func runConfig(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	switch args[0] {
+	case "get":
+		if len(args) == 2 {
+			value, err := config.Get(cfg, args[1])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(stdout, value)
+			return nil
+		}
+		if len(args) != 1 {
+			return errUsage
+		}
+		// Sekrety wypisujemy tylko na wyraźne żądanie pojedynczego klucza
+		w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		for _, key := range config.Keys(cfg) {
+			value, err := config.Get(cfg, key)
+			if err != nil {
+				continue
+			}
+			if config.IsSecret(key) && value != "" {
+				value = "********"
+			}
+			fmt.Fprintf(w, "%s\t%s\n", key, value)
+		}
+		return w.Flush()
+	case "set":
+		if len(args) != 3 {
+			return errUsage
+		}
+		if err := config.Set(cfg, args[1], args[2]); err != nil {
+			return err
+		}
+		if err := config.SaveConfig(cfg); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Saved to %s\n", config.GetConfigFilePath())
+		return nil
+	}
+	return errUsage
+}
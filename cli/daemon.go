@@ -0,0 +1,24 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/michalopenmakers/lazyreview/business"
+	"github.com/michalopenmakers/lazyreview/config"
+	"github.com/michalopenmakers/lazyreview/logger"
+)
+
+// runDaemon monitors merge and pull requests like the desktop application
+// until the process is interrupted.
+func runDaemon(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) > 0 {
+		return errUsage
+	}
+	logger.Log("Starting LazyReview daemon")
+	business.InitializeApplication(cfg)
+	<-ctx.Done()
+	logger.Log("Stopping LazyReview daemon")
+	// Recenzje w toku muszą zapisać stan, zanim main zamknie bazę
+	business.StopMonitoringAndWait()
+	return nil
+}
@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/michalopenmakers/lazyreview/config"
+	"github.com/michalopenmakers/lazyreview/review"
+)
+
+func runLocal(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlagSet("local")
+	base := fs.String("base", "", "branch to compare with, defaults to the default branch of origin")
+	staged := fs.Bool("staged", false, "review the staged changes instead of the branch")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return errUsage
+	}
+
+	r, err := review.ReviewLocal(ctx, cfg, review.LocalOptions{
+		Dir:    fs.Arg(0),
+		Base:   *base,
+		Staged: *staged,
+	})
+	if err != nil {
+		return err
+	}
+	printReview(r)
+	return nil
+}
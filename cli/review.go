@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/michalopenmakers/lazyreview/ai"
+	"github.com/michalopenmakers/lazyreview/config"
+	"github.com/michalopenmakers/lazyreview/review"
+)
+
+func runReview(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	review.LoadReviews()
+	r, err := review.ReviewURL(ctx, cfg, args[0])
+	if err != nil {
+		return err
+	}
+	printReview(r)
+	return nil
+}
+
+// printReview writes the review text with the skipped files and a summary of
+// the findings.
+func printReview(r review.CodeReview) {
+	fmt.Fprintf(stdout, "# %s\n\n", r.Title)
+	if r.ID != "" {
+		fmt.Fprintf(stdout, "ID: %s\n\n", r.ID)
+	}
+	for _, skipped := range r.Skipped {
+		fmt.Fprintf(stdout, "Skipped %s\n", skipped)
+	}
+	if len(r.Skipped) > 0 {
+		fmt.Fprintln(stdout)
+	}
+	fmt.Fprintln(stdout, r.ReviewText)
+	if len(r.Findings) > 0 {
+		fmt.Fprintf(stdout, "\nFindings: %s\n", ai.SeveritySummary(r.Findings))
+	}
+}
+
+func runList(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlagSet("list")
+	asJSON := fs.Bool("json", false, "print the reviews as JSON")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errUsage
+	}
+	review.LoadReviews()
+	reviews := review.GetCodeReviews()
+	if *asJSON {
+		return writeJSON(reviews)
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATUS\tFINDINGS\tREVIEWED\tTITLE")
+	for _, r := range reviews {
+		findings := "-"
+		if len(r.Findings) > 0 {
+			findings = ai.SeveritySummary(r.Findings)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.ID, reviewStatus(r), findings, r.ReviewedAt.Format("2006-01-02 15:04"), r.Title)
+	}
+	return w.Flush()
+}
+
+func reviewStatus(r review.CodeReview) string {
+	switch {
+	case r.IsInProgress:
+		return "in progress"
+	case r.Commented:
+		return "posted (" + string(r.Verdict) + ")"
+	case r.Accepted:
+		return "post failed"
+	case r.ReviewText == "":
+		return "pending"
+	default:
+		return "ready"
+	}
+}
+
+func runPost(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlagSet("post")
+	verdictFlag := fs.String("verdict", "", "comment, request_changes or approve; suggested from the findings when empty")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+	review.LoadReviews()
+	r, err := review.GetReview(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if r.ReviewText == "" {
+		return fmt.Errorf("review %s has not been generated yet", r.ID)
+	}
+
+	verdict := review.DefaultVerdict(r)
+	if *verdictFlag != "" {
+		verdict = review.Verdict(*verdictFlag)
+		if !validVerdict(verdict) {
+			return fmt.Errorf("%w: unknown verdict %q", errUsage, *verdictFlag)
+		}
+	}
+	if err := review.SubmitReview(ctx, cfg, r.ID, verdict); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Submitted %s (%s)\n", r.ID, verdict.Label())
+	return nil
+}
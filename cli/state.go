@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/michalopenmakers/lazyreview/ai"
+	"github.com/michalopenmakers/lazyreview/config"
+	"github.com/michalopenmakers/lazyreview/state"
+)
+
+// runState prints the stored state, or the review runs and post events of
+// one review when its ID is given.
+func runState(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) == 0 || args[0] != "show" {
+		return errUsage
+	}
+	fs := newFlagSet("state show")
+	asJSON := fs.Bool("json", false, "print the state as JSON")
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return errUsage
+	}
+	if fs.NArg() == 1 {
+		return showReviewState(fs.Arg(0), *asJSON)
+	}
+
+	snapshot := state.GetState()
+	if *asJSON {
+		return writeJSON(snapshot)
+	}
+	backend, path := state.Location()
+	fmt.Fprintf(stdout, "Storage: %s (%s)\n", backend, path)
+	fmt.Fprintf(stdout, "Reviews: %d\n", len(snapshot.Reviews))
+
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "\nSOURCE\tPROJECT\tLAST COMMIT\tREVIEWED\tREVIEWS\tCOMMENTED")
+	printProjects(w, "gitlab", snapshot.GitLabProjects)
+	printProjects(w, "github", snapshot.GitHubRepos)
+	return w.Flush()
+}
+
+func printProjects(w *tabwriter.Writer, source string, projects map[string]*state.ProjectState) {
+	ids := make([]string, 0, len(projects))
+	for id := range projects {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		p := projects[id]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%t\n", source, id, shortSHA(p.LastReviewedCommit),
+			time.Unix(p.LastReviewTime, 0).Format("2006-01-02 15:04"), p.ReviewCount, p.Commented)
+	}
+}
+
+func showReviewState(reviewID string, asJSON bool) error {
+	runs := state.GetReviewRuns(reviewID)
+	events := state.GetPostEvents(reviewID)
+	if asJSON {
+		return writeJSON(struct {
+			Runs       []state.ReviewRun
+			PostEvents []state.PostEvent
+		}{runs, events})
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "REVIEWED\tCOMMIT\tSINCE\tPROVIDER\tFINDINGS")
+	for _, run := range runs {
+		findings := "-"
+		if len(run.Findings) > 0 {
+			findings = ai.SeveritySummary(run.Findings)
+		}
+		since := "-"
+		if run.BaseCommit != "" {
+			since = shortSHA(run.BaseCommit)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", run.CreatedAt.Format("2006-01-02 15:04"), shortSHA(run.Commit), since, run.Provider, findings)
+	}
+	if backend, _ := state.Location(); backend != state.BackendBolt {
+		fmt.Fprintln(w, "\nThe json storage backend keeps no post events; set StorageBackend to bolt to record them.")
+	} else if len(events) > 0 {
+		fmt.Fprintln(w, "\nPOSTED\tVERDICT\tRESULT")
+		for _, event := range events {
+			result := "ok"
+			if !event.Success {
+				result = event.Error
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", event.PostedAt.Format("2006-01-02 15:04"), event.Verdict, result)
+		}
+	}
+	return w.Flush()
+}
+
+func writeJSON(value any) error {
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 8 {
+		return sha[:8]
+	}
+	return sha
+}
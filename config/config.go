@@ -2,6 +2,8 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -14,6 +16,72 @@ type Config struct {
 	AIModelConfig                 AIModelConfig
 	MergeRequestsPollingInterval  int
 	ReviewRequestsPollingInterval int
+	// StorageBackend selects where the state is kept: "json" or "bolt".
+	StorageBackend string
+	// ReviewWorkers is the number of reviews generated in parallel.
+	ReviewWorkers int
+	// MaxPages caps how many pages of a list endpoint are fetched. Longer
+	// lists fail instead of being cut short.
+	MaxPages int
+	// IncrementalReviews reviews only the commits pushed since the last
+	// review of a merge/pull request, with that review as context.
+	IncrementalReviews bool
+	DiffFilter         DiffFilterConfig
+	FileContext        FileContextConfig
+	// CIFailOnSeverity is the lowest finding severity that fails
+	// "lazyreview ci"; empty means DefaultCIFailOnSeverity and "none" never
+	// fails.
+	CIFailOnSeverity string
+	Webhook          WebhookConfig
+}
+
+const DefaultCIFailOnSeverity = "high"
+
+const DefaultWebhookListen = ":8090"
+
+// WebhookConfig runs an HTTP server receiving merge and pull request events,
+// so reviews start right after a push instead of at the next poll. Polling
+// keeps running as a fallback for missed deliveries.
+type WebhookConfig struct {
+	Enabled bool
+	// Listen is the address of the server, DefaultWebhookListen when empty.
+	Listen string
+	// GitLabToken is the secret token of the GitLab hook, sent in the
+	// X-Gitlab-Token header.
+	GitLabToken string
+	// GitHubSecret signs GitHub deliveries (X-Hub-Signature-256).
+	GitHubSecret string
+}
+
+const (
+	FileContextOff   = "off"
+	FileContextFull  = "full"
+	FileContextLines = "lines"
+)
+
+// FileContextConfig adds the post-change content of the reviewed files to
+// the prompt, so the AI can see declarations outside the diff hunks.
+type FileContextConfig struct {
+	// Mode is FileContextOff, FileContextFull for whole files or
+	// FileContextLines for Lines lines around every hunk. Files too large
+	// for the budget fall back to the surrounding lines.
+	Mode  string
+	Lines int
+	// MaxTokens caps the context of one review; 0 uses half of the chunk
+	// budget of the model.
+	MaxTokens int
+}
+
+// DiffFilterConfig selects the files of a diff sent to the AI. Globs follow
+// .gitignore conventions; a nil Exclude uses the built-in list of lock files,
+// vendored code and generated assets.
+type DiffFilterConfig struct {
+	Include []string
+	Exclude []string
+	// SkipGenerated skips files marked linguist-generated or
+	// linguist-vendored in .gitattributes and files with a "Code generated
+	// ... DO NOT EDIT" header.
+	SkipGenerated bool
 }
 
 type GitLabConfig struct {
@@ -21,6 +89,13 @@ type GitLabConfig struct {
 	ApiToken   string
 	ApiUrl     string
 	ProjectIDs []string
+	// WatchListMode monitors all open merge requests of ProjectIDs instead
+	// of only those where the user is a reviewer.
+	WatchListMode bool
+	WatchFilter   WatchFilter
+	// MaxConcurrentReviews limits parallel reviews of GitLab MRs, 0 means
+	// no limit other than ReviewWorkers.
+	MaxConcurrentReviews int
 }
 
 func (g *GitLabConfig) GetFullApiUrl() string {
@@ -40,16 +115,114 @@ type GitHubConfig struct {
 	ApiToken     string
 	ApiUrl       string
 	Repositories []string
+	// WatchListMode monitors all open pull requests of Repositories instead
+	// of only those assigned to the user.
+	WatchListMode bool
+	WatchFilter   WatchFilter
+	// MaxConcurrentReviews limits parallel reviews of GitHub PRs, 0 means
+	// no limit other than ReviewWorkers.
+	MaxConcurrentReviews int
 }
 
+// WatchFilter narrows down the merge/pull requests monitored in watch list
+// mode. Empty fields do not filter anything.
+type WatchFilter struct {
+	Labels       []string
+	TargetBranch string
+	Authors      []string
+}
+
+// Matches reports whether a request with the given labels, target branch and
+// author passes the filter. All configured labels have to be present.
+func (f *WatchFilter) Matches(labels []string, targetBranch, author string) bool {
+	if f.TargetBranch != "" && f.TargetBranch != targetBranch {
+		return false
+	}
+	if len(f.Authors) > 0 {
+		found := false
+		for _, a := range f.Authors {
+			if strings.EqualFold(a, author) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, wanted := range f.Labels {
+		found := false
+		for _, label := range labels {
+			if strings.EqualFold(wanted, label) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// GetGitHubApiUrl returns the configured API URL without a trailing slash,
+// or the one of github.com when none is set.
 func (g *GitHubConfig) GetGitHubApiUrl() string {
-	return "https://api.github.com"
+	apiUrl := strings.TrimSuffix(strings.TrimSpace(g.ApiUrl), "/")
+	if apiUrl == "" {
+		return "https://api.github.com"
+	}
+	return apiUrl
 }
 
 type AIModelConfig struct {
+	Provider  string
 	Model     string
 	ApiKey    string
+	ApiUrl    string
 	MaxTokens int
+	// ChunkTokens overrides the diff budget of one request, estimated from
+	// the model's context window when 0.
+	ChunkTokens int
+
+	AzureEndpoint   string
+	AzureDeployment string
+	AzureApiVersion string
+}
+
+const DefaultAzureApiVersion = "2024-10-21"
+
+// GetApiUrl returns the endpoint URL for a provider. ApiUrl overrides the
+// provider's default base URL, which allows pointing LazyReview at
+// self-hosted or OpenAI-compatible servers.
+func (a *AIModelConfig) GetApiUrl(defaultBaseUrl, endpoint string) string {
+	baseUrl := strings.TrimSpace(a.ApiUrl)
+	if baseUrl == "" {
+		baseUrl = defaultBaseUrl
+	}
+	if !strings.HasPrefix(baseUrl, "http://") && !strings.HasPrefix(baseUrl, "https://") {
+		baseUrl = "https://" + baseUrl
+	}
+	baseUrl = strings.TrimSuffix(baseUrl, "/")
+	if strings.HasSuffix(baseUrl, endpoint) {
+		return baseUrl
+	}
+	return baseUrl + endpoint
+}
+
+// GetAzureApiUrl returns the chat completions URL of an Azure OpenAI deployment.
+func (a *AIModelConfig) GetAzureApiUrl() string {
+	endpoint := strings.TrimSpace(a.AzureEndpoint)
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		endpoint = "https://" + endpoint
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	apiVersion := strings.TrimSpace(a.AzureApiVersion)
+	if apiVersion == "" {
+		apiVersion = DefaultAzureApiVersion
+	}
+	return fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+		endpoint, url.PathEscape(strings.TrimSpace(a.AzureDeployment)), url.QueryEscape(apiVersion))
 }
 
 func GetConfigFilePath() string {
@@ -61,29 +234,49 @@ func GetConfigFilePath() string {
 }
 
 func LoadConfig() *Config {
-	configPath := GetConfigFilePath()
-	if _, err := os.Stat(configPath); err == nil {
-		file, err := os.ReadFile(configPath)
-		if err == nil {
-			var cfg Config
-			if err = json.Unmarshal(file, &cfg); err == nil {
-				cfg.GitHubConfig.ApiUrl = "https://api.github.com"
-				if cfg.MergeRequestsPollingInterval == 0 && cfg.ReviewRequestsPollingInterval == 0 {
-					legacyConfig := struct {
-						PollingInterval int
-					}{}
-					if json.Unmarshal(file, &legacyConfig) == nil && legacyConfig.PollingInterval > 0 {
-						cfg.MergeRequestsPollingInterval = legacyConfig.PollingInterval
-						cfg.ReviewRequestsPollingInterval = legacyConfig.PollingInterval
-					} else {
-						cfg.MergeRequestsPollingInterval = 300
-						cfg.ReviewRequestsPollingInterval = 120
-					}
-				}
-				return &cfg
-			}
-		}
+	file, err := os.ReadFile(GetConfigFilePath())
+	if err != nil {
+		return defaultConfig()
+	}
+	// Wartości domyślne są ustawiane przed odczytem, żeby brakujące klucze
+	// starszych plików ich nie zerowały
+	cfg := defaultConfig()
+	if err := json.Unmarshal(file, cfg); err != nil {
+		return defaultConfig()
+	}
+	if cfg.AIModelConfig.Provider == "" {
+		cfg.AIModelConfig.Provider = "openai"
+	}
+	if cfg.StorageBackend == "" {
+		cfg.StorageBackend = "json"
+	}
+	if cfg.ReviewWorkers <= 0 {
+		cfg.ReviewWorkers = 3
+	}
+	if cfg.MaxPages <= 0 {
+		cfg.MaxPages = 10
+	}
+	legacyConfig := struct {
+		PollingInterval               int
+		MergeRequestsPollingInterval  *int
+		ReviewRequestsPollingInterval *int
+	}{}
+	if json.Unmarshal(file, &legacyConfig) == nil && legacyConfig.PollingInterval > 0 &&
+		legacyConfig.MergeRequestsPollingInterval == nil && legacyConfig.ReviewRequestsPollingInterval == nil {
+		cfg.MergeRequestsPollingInterval = legacyConfig.PollingInterval
+		cfg.ReviewRequestsPollingInterval = legacyConfig.PollingInterval
 	}
+	if cfg.MergeRequestsPollingInterval <= 0 {
+		cfg.MergeRequestsPollingInterval = 300
+	}
+	if cfg.ReviewRequestsPollingInterval <= 0 {
+		cfg.ReviewRequestsPollingInterval = 120
+	}
+	return cfg
+}
+
+// defaultConfig returns the settings of a fresh installation.
+func defaultConfig() *Config {
 	return &Config{
 		AppName: "LazyReview",
 		GitLabConfig: GitLabConfig{
@@ -99,17 +292,31 @@ func LoadConfig() *Config {
 			Repositories: []string{},
 		},
 		AIModelConfig: AIModelConfig{
+			Provider:  "openai",
 			Model:     "o3-mini-high",
 			ApiKey:    "",
 			MaxTokens: 4000,
 		},
 		MergeRequestsPollingInterval:  300,
 		ReviewRequestsPollingInterval: 120,
+		StorageBackend:                "json",
+		ReviewWorkers:                 3,
+		MaxPages:                      10,
+		IncrementalReviews:            true,
+		DiffFilter: DiffFilterConfig{
+			SkipGenerated: true,
+		},
+		FileContext: FileContextConfig{
+			Mode:  FileContextOff,
+			Lines: 20,
+		},
+		Webhook: WebhookConfig{
+			Listen: DefaultWebhookListen,
+		},
 	}
 }
 
 func SaveConfig(cfg *Config) error {
-	cfg.GitHubConfig.ApiUrl = "https://api.github.com"
 	data, err := json.MarshalIndent(cfg, "", "  ")
 	if err != nil {
 		return err
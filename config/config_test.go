@@ -0,0 +1,98 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func loadConfigFile(t *testing.T, content string) *Config {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if content != "" {
+		if err := os.WriteFile(filepath.Join(home, ".lazyreview_config.json"), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return LoadConfig()
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		check   func(t *testing.T, cfg *Config)
+	}{
+		{
+			name: "no file",
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.AIModelConfig.Provider != "openai" || cfg.GitLabConfig.ApiUrl != "https://gitlab.com" {
+					t.Errorf("config = %+v", cfg)
+				}
+			},
+		},
+		{
+			name:    "missing keys",
+			content: `{"AppName": "LazyReview", "AIModelConfig": {"Provider": "anthropic", "Model": "claude-sonnet-4"}}`,
+			check: func(t *testing.T, cfg *Config) {
+				if !cfg.IncrementalReviews || !cfg.DiffFilter.SkipGenerated || cfg.FileContext.Lines != 20 || cfg.Webhook.Listen != DefaultWebhookListen {
+					t.Errorf("defaults not applied: %+v", cfg)
+				}
+				if cfg.AIModelConfig.Provider != "anthropic" || cfg.AIModelConfig.Model != "claude-sonnet-4" || cfg.AIModelConfig.MaxTokens != 4000 {
+					t.Errorf("AIModelConfig = %+v", cfg.AIModelConfig)
+				}
+			},
+		},
+		{
+			name:    "explicit values",
+			content: `{"IncrementalReviews": false, "DiffFilter": {"SkipGenerated": false}, "FileContext": {"Mode": "lines", "Lines": 5}}`,
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.IncrementalReviews || cfg.DiffFilter.SkipGenerated || cfg.FileContext.Lines != 5 {
+					t.Errorf("explicit values overwritten: %+v", cfg)
+				}
+			},
+		},
+		{
+			name:    "legacy polling interval",
+			content: `{"PollingInterval": 60}`,
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.MergeRequestsPollingInterval != 60 || cfg.ReviewRequestsPollingInterval != 60 {
+					t.Errorf("polling intervals = %d, %d", cfg.MergeRequestsPollingInterval, cfg.ReviewRequestsPollingInterval)
+				}
+			},
+		},
+		{
+			name:    "zero values",
+			content: `{"MergeRequestsPollingInterval": 0, "ReviewWorkers": 0, "MaxPages": -1, "StorageBackend": ""}`,
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.MergeRequestsPollingInterval != 300 || cfg.ReviewWorkers != 3 || cfg.MaxPages != 10 || cfg.StorageBackend != "json" {
+					t.Errorf("config = %+v", cfg)
+				}
+			},
+		},
+		{
+			name:    "GitHub Enterprise URL",
+			content: `{"GitHubConfig": {"ApiUrl": "https://github.example.com/api/v3/"}}`,
+			check: func(t *testing.T, cfg *Config) {
+				if got := cfg.GitHubConfig.GetGitHubApiUrl(); got != "https://github.example.com/api/v3" {
+					t.Errorf("GetGitHubApiUrl() = %q", got)
+				}
+			},
+		},
+		{
+			name:    "invalid file",
+			content: `{"AppName": `,
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.AppName != "LazyReview" || !cfg.IncrementalReviews {
+					t.Errorf("config = %+v, want the defaults", cfg)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.check(t, loadConfigFile(t, tt.content))
+		})
+	}
+}
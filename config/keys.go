@@ -0,0 +1,149 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// secretFields are the fields holding credentials, masked in listings.
+var secretFields = map[string]bool{
+	"ApiToken":     true,
+	"ApiKey":       true,
+	"GitLabToken":  true,
+	"GitHubSecret": true,
+}
+
+// Keys lists every setting of the configuration as a dotted key, e.g.
+// "GitLabConfig.ApiUrl", in declaration order.
+func Keys(cfg *Config) []string {
+	var keys []string
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			if field.Type.Kind() == reflect.Struct {
+				walk(v.Field(i), prefix+field.Name+".")
+				continue
+			}
+			keys = append(keys, prefix+field.Name)
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem(), "")
+	return keys
+}
+
+// IsSecret reports whether the key holds a credential.
+func IsSecret(key string) bool {
+	return secretFields[key[strings.LastIndex(key, ".")+1:]]
+}
+
+// Get returns the value of a key as text. Lists are joined with commas.
+func Get(cfg *Config, key string) (string, error) {
+	v, err := lookup(cfg, key)
+	if err != nil {
+		return "", err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.String {
+			return strings.Join(v.Interface().([]string), ","), nil
+		}
+	}
+	return "", fmt.Errorf("%s has an unsupported type %s", key, v.Type())
+}
+
+// Set parses the value according to the type of the key and stores it.
+// Lists are separated with commas; an empty value clears them.
+func Set(cfg *Config, key, value string) error {
+	v, err := lookup(cfg, key)
+	if err != nil {
+		return err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s expects true or false, got %q", key, value)
+		}
+		v.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s expects a number, got %q", key, value)
+		}
+		v.SetInt(int64(n))
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("%s has an unsupported type %s", key, v.Type())
+		}
+		items := []string{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("%s has an unsupported type %s", key, v.Type())
+	}
+	return nil
+}
+
+// EnvPrefix starts the environment variables read by ApplyEnv.
+const EnvPrefix = "LAZYREVIEW_"
+
+// EnvName returns the environment variable overriding a key, e.g.
+// LAZYREVIEW_AIMODELCONFIG_APIKEY for AIModelConfig.ApiKey.
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// ApplyEnv overrides the configuration with the environment variables named
+// by EnvName, so that pipelines can run without a configuration file.
+func ApplyEnv(cfg *Config, lookupEnv func(string) (string, bool)) error {
+	for _, key := range Keys(cfg) {
+		value, ok := lookupEnv(EnvName(key))
+		if !ok {
+			continue
+		}
+		if err := Set(cfg, key, value); err != nil {
+			return fmt.Errorf("%s: %w", EnvName(key), err)
+		}
+	}
+	return nil
+}
+
+// lookup finds the field of a dotted key. Key segments are matched without
+// regard to case.
+func lookup(cfg *Config, key string) (reflect.Value, error) {
+	v := reflect.ValueOf(cfg).Elem()
+	for _, name := range strings.Split(key, ".") {
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}, fmt.Errorf("unknown key %s", key)
+		}
+		field := v.FieldByNameFunc(func(field string) bool {
+			return strings.EqualFold(field, name)
+		})
+		if !field.IsValid() {
+			return reflect.Value{}, fmt.Errorf("unknown key %s", key)
+		}
+		v = field
+	}
+	if v.Kind() == reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("%s is a section, use one of its keys, e.g. %s.%s", key, key, v.Type().Field(0).Name)
+	}
+	return v, nil
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestKeys(t *testing.T) {
+	keys := Keys(&Config{})
+	for _, key := range []string{"AppName", "GitLabConfig.ApiToken", "GitLabConfig.WatchFilter.Labels", "AIModelConfig.ChunkTokens", "Webhook.GitHubSecret"} {
+		if !slices.Contains(keys, key) {
+			t.Errorf("Keys() does not contain %s", key)
+		}
+	}
+	for _, key := range []string{"GitLabConfig", "GitLabConfig.WatchFilter"} {
+		if slices.Contains(keys, key) {
+			t.Errorf("Keys() contains the section %s", key)
+		}
+	}
+}
+
+func TestGetSet(t *testing.T) {
+	tests := []struct {
+		key   string
+		value string
+		// want is the value read back, the same as value when empty
+		want string
+	}{
+		{key: "AppName", value: "Reviewer"},
+		{key: "gitlabconfig.apiurl", value: "https://gitlab.example.com"},
+		{key: "GitHubConfig.Enabled", value: "true"},
+		{key: "ReviewWorkers", value: "7"},
+		{key: "GitLabConfig.ProjectIDs", value: " 12, group/project ,,", want: "12,group/project"},
+		{key: "DiffFilter.Exclude", value: ""},
+		{key: "Webhook.Listen", value: "127.0.0.1:9000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			cfg := &Config{}
+			if err := Set(cfg, tt.key, tt.value); err != nil {
+				t.Fatalf("Set(%s, %q): %v", tt.key, tt.value, err)
+			}
+			want := tt.want
+			if want == "" {
+				want = tt.value
+			}
+			got, err := Get(cfg, tt.key)
+			if err != nil {
+				t.Fatalf("Get(%s): %v", tt.key, err)
+			}
+			if got != want {
+				t.Errorf("Get(%s) = %q, want %q", tt.key, got, want)
+			}
+		})
+	}
+}
+
+func TestSetClearsList(t *testing.T) {
+	cfg := &Config{GitHubConfig: GitHubConfig{Repositories: []string{"a/b"}}}
+	if err := Set(cfg, "GitHubConfig.Repositories", ""); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GitHubConfig.Repositories == nil || len(cfg.GitHubConfig.Repositories) != 0 {
+		t.Errorf("Repositories = %#v, want an empty list", cfg.GitHubConfig.Repositories)
+	}
+}
+
+func TestSetErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		value string
+	}{
+		{"unknown key", "GitLabConfig.Missing", "x"},
+		{"unknown section", "Missing.ApiUrl", "x"},
+		{"key below a value", "AppName.Length", "x"},
+		{"section", "GitLabConfig", "x"},
+		{"invalid bool", "GitLabConfig.Enabled", "maybe"},
+		{"invalid number", "MaxPages", "ten"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			if err := Set(cfg, tt.key, tt.value); err == nil {
+				t.Errorf("Set(%s, %q) succeeded, want an error", tt.key, tt.value)
+			}
+			if !reflect.DeepEqual(cfg, &Config{}) {
+				t.Errorf("Set(%s, %q) changed the config: %+v", tt.key, tt.value, cfg)
+			}
+		})
+	}
+}
+
+func TestIsSecret(t *testing.T) {
+	tests := map[string]bool{
+		"GitLabConfig.ApiToken": true,
+		"GitHubConfig.ApiToken": true,
+		"AIModelConfig.ApiKey":  true,
+		"Webhook.GitLabToken":   true,
+		"Webhook.GitHubSecret":  true,
+		"GitLabConfig.ApiUrl":   false,
+		"Webhook.Listen":        false,
+		"AppName":               false,
+	}
+	for key, want := range tests {
+		if got := IsSecret(key); got != want {
+			t.Errorf("IsSecret(%s) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	env := map[string]string{
+		"LAZYREVIEW_AIMODELCONFIG_APIKEY":    "sk-env",
+		"LAZYREVIEW_GITLABCONFIG_PROJECTIDS": "1,2",
+		"LAZYREVIEW_INCREMENTALREVIEWS":      "false",
+	}
+	cfg := &Config{IncrementalReviews: true, AppName: "LazyReview"}
+	err := ApplyEnv(cfg, func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	})
+	if err != nil {
+		t.Fatalf("ApplyEnv: %v", err)
+	}
+	if cfg.AIModelConfig.ApiKey != "sk-env" || !slices.Equal(cfg.GitLabConfig.ProjectIDs, []string{"1", "2"}) || cfg.IncrementalReviews || cfg.AppName != "LazyReview" {
+		t.Errorf("config = %+v", cfg)
+	}
+
+	err = ApplyEnv(cfg, func(name string) (string, bool) {
+		return "many", name == EnvName("MaxPages")
+	})
+	if err == nil {
+		t.Error("ApplyEnv accepted an invalid number")
+	}
+}
@@ -0,0 +1,199 @@
+package diff
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	Added   = '+'
+	Removed = '-'
+	Context = ' '
+)
+
+// Line is a single line of a hunk. OldLine is 0 for added lines and NewLine
+// is 0 for removed lines.
+type Line struct {
+	Kind    byte
+	OldLine int
+	NewLine int
+	Text    string
+}
+
+type Hunk struct {
+	Header   string
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []Line
+}
+
+type File struct {
+	OldPath string
+	NewPath string
+	Hunks   []Hunk
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// ParseHunks parses the hunks of a single file patch, as returned by the
+// GitLab changes and GitHub files endpoints.
+func ParseHunks(patch string) []Hunk {
+	var hunks []Hunk
+	var current *Hunk
+	oldLine, newLine := 0, 0
+	for _, text := range strings.Split(patch, "\n") {
+		if m := hunkHeader.FindStringSubmatch(text); m != nil {
+			hunks = append(hunks, Hunk{
+				Header:   text,
+				OldStart: atoi(m[1]),
+				OldLines: atoiDefault(m[2], 1),
+				NewStart: atoi(m[3]),
+				NewLines: atoiDefault(m[4], 1),
+			})
+			current = &hunks[len(hunks)-1]
+			oldLine, newLine = current.OldStart, current.NewStart
+			continue
+		}
+		if current == nil || text == "" {
+			continue
+		}
+		switch text[0] {
+		case Added:
+			current.Lines = append(current.Lines, Line{Kind: Added, NewLine: newLine, Text: text[1:]})
+			newLine++
+		case Removed:
+			current.Lines = append(current.Lines, Line{Kind: Removed, OldLine: oldLine, Text: text[1:]})
+			oldLine++
+		case Context:
+			current.Lines = append(current.Lines, Line{Kind: Context, OldLine: oldLine, NewLine: newLine, Text: text[1:]})
+			oldLine++
+			newLine++
+		}
+	}
+	return hunks
+}
+
+// Parse splits a multi-file unified diff with ---/+++ headers into files.
+// The a/ and b/ prefixes of git headers are removed from the paths.
+func Parse(unified string) []File {
+	var files []File
+	var patch []string
+	flush := func() {
+		if len(files) > 0 {
+			files[len(files)-1].Hunks = ParseHunks(strings.Join(patch, "\n"))
+		}
+		patch = nil
+	}
+	lines := strings.Split(unified, "\n")
+	for i := 0; i < len(lines); i++ {
+		if strings.HasPrefix(lines[i], "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") {
+			flush()
+			oldPath, newPath := StripGitPrefixes(headerPath(lines[i]), headerPath(lines[i+1]))
+			files = append(files, File{OldPath: oldPath, NewPath: newPath})
+			i++
+			continue
+		}
+		patch = append(patch, lines[i])
+	}
+	flush()
+	return files
+}
+
+// headerPath returns the path of a ---/+++ header line, without the
+// timestamp some tools add after a tab.
+func headerPath(line string) string {
+	path := line[len("--- "):]
+	if idx := strings.Index(path, "\t"); idx >= 0 {
+		path = path[:idx]
+	}
+	return strings.TrimSpace(path)
+}
+
+// StripGitPrefixes removes the a/ and b/ prefixes git adds to the old and new
+// path of a diff header. Paths are left alone unless both sides have the git
+// form, since headers built from forge APIs carry bare paths, which may start
+// with a top-level directory named a or b.
+func StripGitPrefixes(oldPath, newPath string) (string, string) {
+	oldOK := oldPath == "/dev/null" || strings.HasPrefix(oldPath, "a/")
+	newOK := newPath == "/dev/null" || strings.HasPrefix(newPath, "b/")
+	if !oldOK || !newOK || oldPath == newPath {
+		return oldPath, newPath
+	}
+	return strings.TrimPrefix(oldPath, "a/"), strings.TrimPrefix(newPath, "b/")
+}
+
+// ResolvePath finds the file of a diff that path refers to. Paths taken from
+// git headers may still carry the a/ or b/ prefix; an exact match wins, so
+// files in top-level directories named a or b are found too.
+func ResolvePath(path string, paths []string) (string, bool) {
+	path = strings.TrimSpace(path)
+	candidates := []string{path}
+	if rest, ok := strings.CutPrefix(path, "b/"); ok {
+		candidates = append(candidates, rest)
+	} else if rest, ok := strings.CutPrefix(path, "a/"); ok {
+		candidates = append(candidates, rest)
+	}
+	for _, candidate := range candidates {
+		for _, p := range paths {
+			if p == candidate {
+				return p, true
+			}
+		}
+	}
+	return "", false
+}
+
+// FindNewLine looks up a line of the new file version among the hunks.
+func FindNewLine(hunks []Hunk, newLine int) (Line, bool) {
+	for _, hunk := range hunks {
+		for _, line := range hunk.Lines {
+			if line.NewLine == newLine && line.Kind != Removed {
+				return line, true
+			}
+		}
+	}
+	return Line{}, false
+}
+
+// FindLineInRange returns the first line of the new file version between
+// start and end (inclusive) that is visible in the hunks.
+func FindLineInRange(hunks []Hunk, start, end int) (Line, bool) {
+	if start <= 0 {
+		return Line{}, false
+	}
+	if end < start {
+		end = start
+	}
+	for n := start; n <= end; n++ {
+		if line, ok := FindNewLine(hunks, n); ok {
+			return line, true
+		}
+	}
+	return Line{}, false
+}
+
+// String renders the hunk back to unified diff form.
+func (h Hunk) String() string {
+	var sb strings.Builder
+	sb.WriteString(h.Header + "\n")
+	for _, line := range h.Lines {
+		sb.WriteString(fmt.Sprintf("%c%s\n", line.Kind, line.Text))
+	}
+	return sb.String()
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+func atoiDefault(s string, def int) int {
+	if s == "" {
+		return def
+	}
+	return atoi(s)
+}
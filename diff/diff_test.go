@@ -0,0 +1,178 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHunks(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		want  []Hunk
+	}{
+		{
+			name:  "added and removed lines",
+			patch: "@@ -10,3 +10,4 @@ func main() {\n a := 1\n-b := 2\n+b := 3\n+c := 4\n return\n",
+			want: []Hunk{{
+				Header: "@@ -10,3 +10,4 @@ func main() {", OldStart: 10, OldLines: 3, NewStart: 10, NewLines: 4,
+				Lines: []Line{
+					{Kind: Context, OldLine: 10, NewLine: 10, Text: "a := 1"},
+					{Kind: Removed, OldLine: 11, Text: "b := 2"},
+					{Kind: Added, NewLine: 11, Text: "b := 3"},
+					{Kind: Added, NewLine: 12, Text: "c := 4"},
+					{Kind: Context, OldLine: 12, NewLine: 13, Text: "return"},
+				},
+			}},
+		},
+		{
+			name:  "counts default to one",
+			patch: "@@ -1 +1 @@\n-old\n+new\n",
+			want: []Hunk{{
+				Header: "@@ -1 +1 @@", OldStart: 1, OldLines: 1, NewStart: 1, NewLines: 1,
+				Lines: []Line{
+					{Kind: Removed, OldLine: 1, Text: "old"},
+					{Kind: Added, NewLine: 1, Text: "new"},
+				},
+			}},
+		},
+		{
+			name:  "new file",
+			patch: "@@ -0,0 +1,2 @@\n+package main\n+\n",
+			want: []Hunk{{
+				Header: "@@ -0,0 +1,2 @@", OldStart: 0, OldLines: 0, NewStart: 1, NewLines: 2,
+				Lines: []Line{
+					{Kind: Added, NewLine: 1, Text: "package main"},
+					{Kind: Added, NewLine: 2, Text: ""},
+				},
+			}},
+		},
+		{
+			name:  "two hunks and no newline marker",
+			patch: "@@ -1,2 +1,2 @@\n a\n-b\n+B\n@@ -20 +20 @@\n-y\n\\ No newline at end of file\n+z\n",
+			want: []Hunk{
+				{
+					Header: "@@ -1,2 +1,2 @@", OldStart: 1, OldLines: 2, NewStart: 1, NewLines: 2,
+					Lines: []Line{
+						{Kind: Context, OldLine: 1, NewLine: 1, Text: "a"},
+						{Kind: Removed, OldLine: 2, Text: "b"},
+						{Kind: Added, NewLine: 2, Text: "B"},
+					},
+				},
+				{
+					Header: "@@ -20 +20 @@", OldStart: 20, OldLines: 1, NewStart: 20, NewLines: 1,
+					Lines: []Line{
+						{Kind: Removed, OldLine: 20, Text: "y"},
+						{Kind: Added, NewLine: 20, Text: "z"},
+					},
+				},
+			},
+		},
+		{
+			name:  "text before the first hunk",
+			patch: "Binary files differ\n",
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseHunks(tt.patch); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseHunks() = %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	unified := "--- a/old.go\n+++ b/new.go\n@@ -1 +1 @@\n-a\n+b\n" +
+		"--- /dev/null\n+++ b/added.go\t(new)\n@@ -0,0 +1 @@\n+c\n"
+	files := Parse(unified)
+	if len(files) != 2 {
+		t.Fatalf("Parse() returned %d files, want 2", len(files))
+	}
+	paths := [][2]string{{files[0].OldPath, files[0].NewPath}, {files[1].OldPath, files[1].NewPath}}
+	if want := [][2]string{{"old.go", "new.go"}, {"/dev/null", "added.go"}}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths = %v, want %v", paths, want)
+	}
+	if len(files[0].Hunks) != 1 || len(files[1].Hunks) != 1 || files[1].Hunks[0].Lines[0].NewLine != 1 {
+		t.Errorf("hunks = %+v / %+v", files[0].Hunks, files[1].Hunks)
+	}
+}
+
+func TestParseBarePaths(t *testing.T) {
+	// Nagłówki z API GitLaba nie mają prefiksów a/ i b/
+	files := Parse("--- a/handler.go\n+++ a/handler.go\n@@ -1 +1 @@\n-a\n+b\n--- b/x.go\n+++ b/x.go\n@@ -1 +1 @@\n-a\n+b\n")
+	if len(files) != 2 || files[0].NewPath != "a/handler.go" || files[1].OldPath != "b/x.go" {
+		t.Errorf("Parse() = %+v, want the paths unchanged", files)
+	}
+}
+
+func TestStripGitPrefixes(t *testing.T) {
+	tests := []struct {
+		oldPath, newPath string
+		wantOld, wantNew string
+	}{
+		{"a/main.go", "b/main.go", "main.go", "main.go"},
+		{"a/a/main.go", "b/a/main.go", "a/main.go", "a/main.go"},
+		{"/dev/null", "b/new.go", "/dev/null", "new.go"},
+		{"a/gone.go", "/dev/null", "gone.go", "/dev/null"},
+		{"main.go", "main.go", "main.go", "main.go"},
+		{"a/main.go", "a/main.go", "a/main.go", "a/main.go"},
+		{"b/main.go", "b/main.go", "b/main.go", "b/main.go"},
+		{"/dev/null", "/dev/null", "/dev/null", "/dev/null"},
+	}
+	for _, tt := range tests {
+		oldPath, newPath := StripGitPrefixes(tt.oldPath, tt.newPath)
+		if oldPath != tt.wantOld || newPath != tt.wantNew {
+			t.Errorf("StripGitPrefixes(%q, %q) = %q, %q; want %q, %q", tt.oldPath, tt.newPath, oldPath, newPath, tt.wantOld, tt.wantNew)
+		}
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	paths := []string{"main.go", "a/handler.go", "b/x.go", "x.go"}
+	tests := []struct {
+		path   string
+		want   string
+		wantOK bool
+	}{
+		{"main.go", "main.go", true},
+		{"b/main.go", "main.go", true},
+		{"a/main.go", "main.go", true},
+		{"a/handler.go", "a/handler.go", true},
+		{"b/a/handler.go", "a/handler.go", true},
+		{"b/x.go", "b/x.go", true},
+		{" x.go ", "x.go", true},
+		{"other.go", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := ResolvePath(tt.path, paths)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("ResolvePath(%q) = %q, %v; want %q, %v", tt.path, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestFindLineInRange(t *testing.T) {
+	hunks := ParseHunks("@@ -10,3 +10,3 @@\n a\n-b\n+B\n c\n")
+	tests := []struct {
+		name       string
+		start, end int
+		wantLine   int
+		wantOK     bool
+	}{
+		{"context line", 10, 10, 10, true},
+		{"added line", 11, 0, 11, true},
+		{"range reaching into the hunk", 5, 12, 10, true},
+		{"outside the hunk", 20, 25, 0, false},
+		{"unknown line", 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, ok := FindLineInRange(hunks, tt.start, tt.end)
+			if ok != tt.wantOK || line.NewLine != tt.wantLine {
+				t.Errorf("FindLineInRange(%d, %d) = %d, %v; want %d, %v", tt.start, tt.end, line.NewLine, ok, tt.wantLine, tt.wantOK)
+			}
+		})
+	}
+}
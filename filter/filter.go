@@ -0,0 +1,208 @@
+package filter
+
+import (
+	"bufio"
+	"regexp"
+	"strings"
+
+	"github.com/michalopenmakers/lazyreview/config"
+)
+
+// DefaultExclude is used when DiffFilterConfig.Exclude is not set: lock
+// files, vendored dependencies, minified assets and generated protobuf code.
+var DefaultExclude = []string{
+	"go.sum",
+	"package-lock.json",
+	"yarn.lock",
+	"pnpm-lock.yaml",
+	"Cargo.lock",
+	"poetry.lock",
+	"composer.lock",
+	"Gemfile.lock",
+	"vendor/",
+	"node_modules/",
+	"*.min.js",
+	"*.min.css",
+	"*.map",
+	"*.pb.go",
+	"*_pb2.py",
+	"*.pb.cc",
+	"*.pb.h",
+}
+
+// generatedMarker matches the conventional header of generated files, e.g.
+// "// Code generated by protoc-gen-go. DO NOT EDIT." or "@generated".
+var generatedMarker = regexp.MustCompile(`Code generated .*DO NOT EDIT|@generated\b`)
+
+// markerLines is how many lines of a patch are searched for the marker; it
+// is expected in the file header.
+const markerLines = 30
+
+// Skipped describes a file left out of the review.
+type Skipped struct {
+	Path   string
+	Reason string
+}
+
+func (s Skipped) String() string {
+	return s.Path + " (" + s.Reason + ")"
+}
+
+// Filter decides which files of a diff are sent to the AI.
+type Filter struct {
+	include       []*regexp.Regexp
+	exclude       []pattern
+	skipGenerated bool
+	attributes    []attributeRule
+}
+
+type pattern struct {
+	glob string
+	re   *regexp.Regexp
+}
+
+type attributeRule struct {
+	re        *regexp.Regexp
+	generated bool
+	attribute string
+}
+
+// New builds a filter from the configuration.
+func New(cfg config.DiffFilterConfig) *Filter {
+	f := &Filter{skipGenerated: cfg.SkipGenerated}
+	for _, glob := range cfg.Include {
+		if glob = strings.TrimSpace(glob); glob != "" {
+			f.include = append(f.include, compileGlob(glob))
+		}
+	}
+	exclude := cfg.Exclude
+	if exclude == nil {
+		exclude = DefaultExclude
+	}
+	for _, glob := range exclude {
+		if glob = strings.TrimSpace(glob); glob != "" {
+			f.exclude = append(f.exclude, pattern{glob: glob, re: compileGlob(glob)})
+		}
+	}
+	return f
+}
+
+// DetectsGenerated reports whether generated files are skipped, in which
+// case the caller should load the repository's .gitattributes.
+func (f *Filter) DetectsGenerated() bool {
+	return f.skipGenerated
+}
+
+// WithAttributes adds the linguist-generated and linguist-vendored rules of
+// a .gitattributes file.
+func (f *Filter) WithAttributes(gitattributes string) *Filter {
+	scanner := bufio.NewScanner(strings.NewReader(gitattributes))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		for _, attr := range fields[1:] {
+			name, value, _ := strings.Cut(strings.TrimPrefix(attr, "-"), "=")
+			if name != "linguist-generated" && name != "linguist-vendored" {
+				continue
+			}
+			generated := !strings.HasPrefix(attr, "-") && value != "false"
+			f.attributes = append(f.attributes, attributeRule{
+				re:        compileGlob(fields[0]),
+				generated: generated,
+				attribute: name,
+			})
+		}
+	}
+	return f
+}
+
+// Check tells whether the file should be skipped and why. patch is the
+// file's diff, searched for generated-code markers.
+func (f *Filter) Check(filePath, patch string) (string, bool) {
+	if len(f.include) > 0 {
+		included := false
+		for _, re := range f.include {
+			if re.MatchString(filePath) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return "not included", true
+		}
+	}
+	for _, p := range f.exclude {
+		if p.re.MatchString(filePath) {
+			return "excluded by " + p.glob, true
+		}
+	}
+	if !f.skipGenerated {
+		return "", false
+	}
+	// Późniejsze reguły .gitattributes nadpisują wcześniejsze
+	for i := len(f.attributes) - 1; i >= 0; i-- {
+		rule := f.attributes[i]
+		if rule.re.MatchString(filePath) {
+			if rule.generated {
+				return rule.attribute, true
+			}
+			return "", false
+		}
+	}
+	if hasGeneratedMarker(patch) {
+		return "generated", true
+	}
+	return "", false
+}
+
+func hasGeneratedMarker(patch string) bool {
+	lines := strings.SplitN(patch, "\n", markerLines+1)
+	if len(lines) > markerLines {
+		lines = lines[:markerLines]
+	}
+	for _, line := range lines {
+		if generatedMarker.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileGlob turns a gitignore-style glob into a regexp matching paths
+// relative to the repository root. Globs without a slash match the file
+// name at any depth and a leading slash anchors them to the root. A trailing
+// slash matches everything below a directory and "**" matches any number of
+// directories.
+func compileGlob(glob string) *regexp.Regexp {
+	anchored := strings.HasPrefix(glob, "/")
+	glob = strings.TrimPrefix(glob, "/")
+	if strings.HasSuffix(glob, "/") {
+		glob += "**"
+	}
+	var sb strings.Builder
+	sb.WriteString("^")
+	if !anchored && !strings.Contains(strings.TrimSuffix(glob, "/**"), "/") {
+		sb.WriteString("(.*/)?")
+	}
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			sb.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String())
+}
@@ -0,0 +1,88 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/michalopenmakers/lazyreview/config"
+)
+
+func TestCompileGlob(t *testing.T) {
+	tests := []struct {
+		glob  string
+		path  string
+		match bool
+	}{
+		{"go.sum", "go.sum", true},
+		{"go.sum", "tools/go.sum", true},
+		{"go.sum", "go.summary", false},
+		{"*.min.js", "static/app.min.js", true},
+		{"*.min.js", "static/app.js", false},
+		{"*.pb.go", "api/v1/service.pb.go", true},
+		{"vendor/", "vendor/github.com/x/y.go", true},
+		{"vendor/", "pkg/vendor/z.go", true},
+		{"vendor/", "vendored.go", false},
+		{"/build/", "build/out.js", true},
+		{"/build/", "web/build/out.js", false},
+		{"docs/*.md", "docs/index.md", true},
+		{"docs/*.md", "docs/api/index.md", false},
+		{"docs/*.md", "other/docs/index.md", false},
+		{"docs/**/*.md", "docs/api/v1/index.md", true},
+		{"docs/**/*.md", "docs/index.md", true},
+		{"**/testdata/**", "a/b/testdata/c/d.json", true},
+		{"file?.txt", "file1.txt", true},
+		{"file?.txt", "file10.txt", false},
+		{"file?.txt", "dir/file1.txt", true},
+		{"a+b.txt", "a+b.txt", true},
+		{"a+b.txt", "aab.txt", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.glob+" "+tt.path, func(t *testing.T) {
+			if got := compileGlob(tt.glob).MatchString(tt.path); got != tt.match {
+				t.Errorf("compileGlob(%q) matches %q = %v, want %v", tt.glob, tt.path, got, tt.match)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	const gitattributes = `# generated code
+*.gen.go linguist-generated
+api/*.json linguist-generated=true
+third_party/** linguist-vendored
+api/manual.json -linguist-generated
+`
+	generatedPatch := "@@ -0,0 +1,3 @@\n+// Code generated by mockgen. DO NOT EDIT.\n+package mocks\n+\n"
+
+	tests := []struct {
+		name       string
+		cfg        config.DiffFilterConfig
+		attributes string
+		path       string
+		patch      string
+		wantReason string
+		wantSkip   bool
+	}{
+		{name: "plain file", cfg: config.DiffFilterConfig{SkipGenerated: true}, path: "main.go"},
+		{name: "default exclude", path: "web/package-lock.json", wantReason: "excluded by package-lock.json", wantSkip: true},
+		{name: "custom exclude replaces defaults", cfg: config.DiffFilterConfig{Exclude: []string{"*.snap"}}, path: "go.sum"},
+		{name: "custom exclude", cfg: config.DiffFilterConfig{Exclude: []string{"*.snap"}}, path: "ui/__snapshots__/a.snap", wantReason: "excluded by *.snap", wantSkip: true},
+		{name: "empty exclude", cfg: config.DiffFilterConfig{Exclude: []string{}}, path: "go.sum"},
+		{name: "not included", cfg: config.DiffFilterConfig{Include: []string{"src/"}}, path: "docs/readme.md", wantReason: "not included", wantSkip: true},
+		{name: "included", cfg: config.DiffFilterConfig{Include: []string{"src/"}}, path: "src/main.go"},
+		{name: "generated marker", cfg: config.DiffFilterConfig{SkipGenerated: true}, path: "mocks/store.go", patch: generatedPatch, wantReason: "generated", wantSkip: true},
+		{name: "generated marker ignored", path: "mocks/store.go", patch: generatedPatch},
+		{name: "linguist-generated", cfg: config.DiffFilterConfig{SkipGenerated: true}, attributes: gitattributes, path: "pkg/model.gen.go", wantReason: "linguist-generated", wantSkip: true},
+		{name: "linguist-vendored", cfg: config.DiffFilterConfig{SkipGenerated: true}, attributes: gitattributes, path: "third_party/lib/x.c", wantReason: "linguist-vendored", wantSkip: true},
+		{name: "later rule wins", cfg: config.DiffFilterConfig{SkipGenerated: true}, attributes: gitattributes, path: "api/manual.json", patch: generatedPatch},
+		{name: "earlier rule applies", cfg: config.DiffFilterConfig{SkipGenerated: true}, attributes: gitattributes, path: "api/schema.json", wantReason: "linguist-generated", wantSkip: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New(tt.cfg).WithAttributes(tt.attributes)
+			reason, skip := f.Check(tt.path, tt.patch)
+			if reason != tt.wantReason || skip != tt.wantSkip {
+				t.Errorf("Check(%q) = %q, %v; want %q, %v", tt.path, reason, skip, tt.wantReason, tt.wantSkip)
+			}
+		})
+	}
+}
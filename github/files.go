@@ -0,0 +1,103 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"strings"
+
+	"github.com/michalopenmakers/lazyreview/config"
+	"github.com/michalopenmakers/lazyreview/filter"
+	"github.com/michalopenmakers/lazyreview/logger"
+)
+
+// GetFileContent returns the raw content of a file at the given ref, or on
+// the default branch when ref is empty. A missing file yields an error
+// wrapping os.ErrNotExist.
+func GetFileContent(ctx context.Context, cfg *config.Config, repository, filePath, ref string) (string, error) {
+	apiUrl := getFullApiUrl(cfg)
+	segments := strings.Split(filePath, "/")
+	for i, segment := range segments {
+		segments[i] = neturl.PathEscape(segment)
+	}
+	url := fmt.Sprintf("%s/repos/%s/contents/%s", apiUrl, repository, strings.Join(segments, "/"))
+	if ref != "" {
+		url += "?ref=" + neturl.QueryEscape(ref)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error creating request for GitHub file: %v", err))
+		return "", err
+	}
+
+	req.Header.Set("Authorization", "token "+cfg.GitHubConfig.ApiToken)
+	req.Header.Set("Accept", "application/vnd.github.raw")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error connecting to GitHub API (%s): %v", apiUrl, err))
+		return "", err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			logger.Log(fmt.Sprintf("Error closing response body: %v", err))
+		}
+	}(resp.Body)
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("%s not found at %s: %w", filePath, ref, os.ErrNotExist)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		errMsg := fmt.Sprintf("GitHub API (contents) responded with status code %d: %s", resp.StatusCode, string(body))
+		logger.Log(errMsg)
+		return "", fmt.Errorf(errMsg)
+	}
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error reading GitHub file %s: %v", filePath, err))
+		return "", err
+	}
+	return string(content), nil
+}
+
+// loadFilter builds the diff filter, reading .gitattributes at ref when
+// generated files are to be detected.
+func loadFilter(ctx context.Context, cfg *config.Config, repository, ref string) *filter.Filter {
+	f := filter.New(cfg.DiffFilter)
+	if !f.DetectsGenerated() {
+		return f
+	}
+	attributes, err := GetFileContent(ctx, cfg, repository, ".gitattributes", ref)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Log(fmt.Sprintf("Error reading .gitattributes of repo %s: %v", repository, err))
+		}
+		return f
+	}
+	return f.WithAttributes(attributes)
+}
+
+// filterFiles drops the files rejected by the filter.
+func filterFiles(f *filter.Filter, files []PullRequestFile) ([]PullRequestFile, []filter.Skipped) {
+	var kept []PullRequestFile
+	var skipped []filter.Skipped
+	for _, file := range files {
+		if reason, skip := f.Check(file.Filename, file.Patch); skip {
+			skipped = append(skipped, filter.Skipped{Path: file.Filename, Reason: reason})
+			continue
+		}
+		kept = append(kept, file)
+	}
+	if len(skipped) > 0 {
+		logger.Log(fmt.Sprintf("Skipped %d of %d changed files", len(skipped), len(files)))
+	}
+	return kept, skipped
+}
@@ -2,83 +2,86 @@ package github
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	neturl "net/url"
+	"strings"
 	"time"
 
+	"github.com/michalopenmakers/lazyreview/ai"
 	"github.com/michalopenmakers/lazyreview/config"
+	"github.com/michalopenmakers/lazyreview/diff"
+	"github.com/michalopenmakers/lazyreview/filter"
+	"github.com/michalopenmakers/lazyreview/httpclient"
 	"github.com/michalopenmakers/lazyreview/logger"
 )
 
+// client is shared by all GitHub API calls, so rate limits are tracked in one
+// place.
+var client = httpclient.New("github", 30*time.Second)
+
 type PullRequest struct {
 	Number     int `json:"number"`
 	Repository string
 	Title      string `json:"title"`
 	HTMLURL    string `json:"html_url"`
+	// HeadSHA is empty when the PR comes from the assigned issues list,
+	// which does not include the head commit.
+	HeadSHA string
+	// The fields below are set only for PRs read from the pulls endpoints.
+	State        string
+	Labels       []string
+	TargetBranch string
+	Author       string
+	Assignees    []string
 }
 
 func getFullApiUrl(cfg *config.Config) string {
 	return cfg.GitHubConfig.GetGitHubApiUrl()
 }
 
-func GetPullRequestsToReview(cfg *config.Config) ([]PullRequest, error) {
-	logger.Log("Fetching GitHub pull requests assigned for review")
-
-	apiUrl := getFullApiUrl(cfg)
-	url := fmt.Sprintf("%s/issues?filter=assigned&state=open", apiUrl)
-
-	req, err := http.NewRequest("GET", url, nil)
+// getPages fetches every page of a GitHub list endpoint, up to
+// Config.MaxPages. what names the endpoint in logs.
+func getPages[T any](ctx context.Context, cfg *config.Config, url, what string) ([]T, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", httpclient.WithPageSize(url), nil)
 	if err != nil {
-		logger.Log(fmt.Sprintf("Error creating request for GitHub PRs: %v", err))
+		logger.Log(fmt.Sprintf("Error creating request for %s: %v", what, err))
 		return nil, err
 	}
-
 	req.Header.Set("Authorization", "token "+cfg.GitHubConfig.ApiToken)
 	req.Header.Set("Accept", "application/vnd.github.v3+json")
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	items, err := httpclient.GetPages[T](client, req, cfg.MaxPages, httpclient.GitHubNextPage, what)
 	if err != nil {
-		logger.Log(fmt.Sprintf("Error connecting to GitHub API (%s): %v", apiUrl, err))
+		logger.Log(fmt.Sprintf("Error fetching %s from GitHub: %v", what, err))
 		return nil, err
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			logger.Log(fmt.Sprintf("Error closing response body: %v", err))
-		}
-	}(resp.Body)
+	return items, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		errMsg := fmt.Sprintf("GitHub API responded with status code %d: %s", resp.StatusCode, string(body))
-		logger.Log(errMsg)
-		return nil, fmt.Errorf(errMsg)
-	}
+type assignedIssue struct {
+	Number      int    `json:"number"`
+	Title       string `json:"title"`
+	HTMLURL     string `json:"html_url"`
+	PullRequest struct {
+		URL string `json:"url"`
+	} `json:"pull_request"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
 
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		logger.Log(fmt.Sprintf("Error reading API response: %v", err))
-		return nil, err
-	}
-	logger.Log("API response: " + string(bodyBytes))
+func GetPullRequestsToReview(ctx context.Context, cfg *config.Config) ([]PullRequest, error) {
+	logger.Log("Fetching GitHub pull requests assigned for review")
 
-	var issues []struct {
-		Number      int    `json:"number"`
-		Title       string `json:"title"`
-		HTMLURL     string `json:"html_url"`
-		PullRequest struct {
-			URL string `json:"url"`
-		} `json:"pull_request"`
-		Repository struct {
-			FullName string `json:"full_name"`
-		} `json:"repository"`
-	}
+	apiUrl := getFullApiUrl(cfg)
+	url := fmt.Sprintf("%s/issues?filter=assigned&state=open", apiUrl)
 
-	if err := json.Unmarshal(bodyBytes, &issues); err != nil {
-		logger.Log(fmt.Sprintf("Error decoding GitHub response: %v", err))
+	issues, err := getPages[assignedIssue](ctx, cfg, url, "assigned issues")
+	if err != nil {
 		return nil, err
 	}
 
@@ -99,26 +102,154 @@ func GetPullRequestsToReview(cfg *config.Config) ([]PullRequest, error) {
 	return pullRequests, nil
 }
 
-func GetPullRequestChanges(cfg *config.Config, repository string, prID int) (string, error) {
-	logger.Log(fmt.Sprintf("Getting changes for PR #%d in repo %s", prID, repository))
+// GetWatchedPullRequests returns the open pull requests of all repositories
+// listed in GitHubConfig.Repositories that pass the configured watch filter.
+func GetWatchedPullRequests(ctx context.Context, cfg *config.Config) ([]PullRequest, error) {
+	logger.Log(fmt.Sprintf("Fetching open GitHub pull requests in %d watched repositories", len(cfg.GitHubConfig.Repositories)))
+
+	var pullRequests []PullRequest
+	for _, repository := range cfg.GitHubConfig.Repositories {
+		repository = strings.Trim(strings.TrimSpace(repository), "/")
+		if repository == "" {
+			continue
+		}
+		repoPRs, err := getRepositoryPullRequests(ctx, cfg, repository)
+		if err != nil {
+			logger.Log(fmt.Sprintf("Error fetching pull requests of %s: %v", repository, err))
+			continue
+		}
+		pullRequests = append(pullRequests, repoPRs...)
+	}
+
+	logger.Log(fmt.Sprintf("Successfully fetched %d pull requests from watched repositories", len(pullRequests)))
+	return pullRequests, nil
+}
 
+type repositoryPull struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	HTMLURL string `json:"html_url"`
+	State   string `json:"state"`
+	User    struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	Assignees []struct {
+		Login string `json:"login"`
+	} `json:"assignees"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+	Head struct {
+		SHA string `json:"sha"`
+	} `json:"head"`
+}
+
+func getRepositoryPullRequests(ctx context.Context, cfg *config.Config, repository string) ([]PullRequest, error) {
 	apiUrl := getFullApiUrl(cfg)
-	url := fmt.Sprintf("%s/repos/%s/pulls/%d/files", apiUrl, repository, prID)
+	url := fmt.Sprintf("%s/repos/%s/pulls?state=open", apiUrl, repository)
+	if cfg.GitHubConfig.WatchFilter.TargetBranch != "" {
+		url += "&base=" + neturl.QueryEscape(cfg.GitHubConfig.WatchFilter.TargetBranch)
+	}
 
-	req, err := http.NewRequest("GET", url, nil)
+	pulls, err := getPages[repositoryPull](ctx, cfg, url, "repository pull requests")
 	if err != nil {
-		logger.Log(fmt.Sprintf("Error creating request for GitHub PR changes: %v", err))
-		return "", err
+		return nil, err
+	}
+
+	var pullRequests []PullRequest
+	for _, pull := range pulls {
+		pr := toPullRequest(repository, pull)
+		if !cfg.GitHubConfig.WatchFilter.Matches(pr.Labels, pr.TargetBranch, pr.Author) {
+			continue
+		}
+		pullRequests = append(pullRequests, pr)
+	}
+	return pullRequests, nil
+}
+
+func toPullRequest(repository string, pull repositoryPull) PullRequest {
+	pr := PullRequest{
+		Number:       pull.Number,
+		Repository:   repository,
+		Title:        pull.Title,
+		HTMLURL:      pull.HTMLURL,
+		HeadSHA:      pull.Head.SHA,
+		State:        pull.State,
+		TargetBranch: pull.Base.Ref,
+		Author:       pull.User.Login,
+	}
+	for _, label := range pull.Labels {
+		pr.Labels = append(pr.Labels, label.Name)
+	}
+	for _, assignee := range pull.Assignees {
+		pr.Assignees = append(pr.Assignees, assignee.Login)
+	}
+	return pr
+}
+
+type PullRequestFile struct {
+	Filename         string `json:"filename"`
+	PreviousFilename string `json:"previous_filename"`
+	Status           string `json:"status"`
+	Patch            string `json:"patch"`
+}
+
+// GetPullRequestChanges returns the combined diff of the pull request without
+// the files rejected by the configured diff filter, which are listed
+// separately. .gitattributes is read at headSHA, the reviewed commit.
+func GetPullRequestChanges(ctx context.Context, cfg *config.Config, repository string, prID int, headSHA string) (string, []filter.Skipped, error) {
+	files, err := GetPullRequestFiles(ctx, cfg, repository, prID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	files, skipped := filterFiles(loadFilter(ctx, cfg, repository, headSHA), files)
+	combinedChanges := combineFiles(files)
+	logger.Log(fmt.Sprintf("Successfully fetched changes for PR #%d, total size: %d bytes", prID, len(combinedChanges)))
+	return combinedChanges, skipped, nil
+}
+
+func combineFiles(files []PullRequestFile) string {
+	var combinedChanges string
+	for _, file := range files {
+		fileHeader := fmt.Sprintf("--- a/%s\n+++ b/%s\n", file.Filename, file.Filename)
+		if file.Patch != "" {
+			combinedChanges += fileHeader + file.Patch + "\n\n"
+		}
+	}
+	return combinedChanges
+}
+
+// compareFileLimit is the most files the compare endpoint lists.
+const compareFileLimit = 300
+
+// GetCompareChanges returns the combined diff between two commits of the
+// repository. It fails when head does not descend from base, e.g. after a
+// force push, since the delta would not describe the new commits, and when
+// the delta reaches the file limit of the compare endpoint, since files may
+// be missing. Files are filtered like in GetPullRequestChanges.
+func GetCompareChanges(ctx context.Context, cfg *config.Config, repository, base, head string) (string, []filter.Skipped, error) {
+	logger.Log(fmt.Sprintf("Comparing %s...%s in repo %s", shortSHA(base), shortSHA(head), repository))
+
+	apiUrl := getFullApiUrl(cfg)
+	url := fmt.Sprintf("%s/repos/%s/compare/%s...%s", apiUrl, repository, base, head)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error creating request for GitHub compare: %v", err))
+		return "", nil, err
 	}
 
 	req.Header.Set("Authorization", "token "+cfg.GitHubConfig.ApiToken)
 	req.Header.Set("Accept", "application/vnd.github.v3+json")
 
-	client := &http.Client{Timeout: 30 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
 		logger.Log(fmt.Sprintf("Error connecting to GitHub API (%s): %v", apiUrl, err))
-		return "", err
+		return "", nil, err
 	}
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
@@ -129,60 +260,65 @@ func GetPullRequestChanges(cfg *config.Config, repository string, prID int) (str
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		errMsg := fmt.Sprintf("GitHub API responded with status code %d: %s", resp.StatusCode, string(body))
+		errMsg := fmt.Sprintf("GitHub API (compare) responded with status code %d: %s", resp.StatusCode, string(body))
 		logger.Log(errMsg)
-		return "", fmt.Errorf(errMsg)
+		return "", nil, fmt.Errorf(errMsg)
 	}
 
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		logger.Log(fmt.Sprintf("Error reading API response: %v", err))
-		return "", err
+	var compare struct {
+		Status string            `json:"status"`
+		Files  []PullRequestFile `json:"files"`
 	}
-	logger.Log("API response: " + string(bodyBytes))
-
-	var files []struct {
-		Filename string `json:"filename"`
-		Patch    string `json:"patch"`
+	if err := json.NewDecoder(resp.Body).Decode(&compare); err != nil {
+		logger.Log(fmt.Sprintf("Error decoding GitHub compare response: %v", err))
+		return "", nil, err
 	}
-
-	if err := json.Unmarshal(bodyBytes, &files); err != nil {
-		logger.Log(fmt.Sprintf("Error decoding GitHub PR files response: %v", err))
-		return "", err
+	if compare.Status != "ahead" && compare.Status != "identical" {
+		return "", nil, fmt.Errorf("head %s is %s of %s", shortSHA(head), compare.Status, shortSHA(base))
 	}
-
-	var combinedChanges string
-	for _, file := range files {
-		fileHeader := fmt.Sprintf("--- a/%s\n+++ b/%s\n", file.Filename, file.Filename)
-		if file.Patch != "" {
-			combinedChanges += fileHeader + file.Patch + "\n\n"
-		}
+	if len(compare.Files) >= compareFileLimit {
+		return "", nil, fmt.Errorf("compare %s...%s lists %d files, the most GitHub returns", shortSHA(base), shortSHA(head), len(compare.Files))
 	}
 
-	logger.Log(fmt.Sprintf("Successfully fetched changes for PR #%d, total size: %d bytes", prID, len(combinedChanges)))
-	return combinedChanges, nil
+	files, skipped := filterFiles(loadFilter(ctx, cfg, repository, head), compare.Files)
+	combinedChanges := combineFiles(files)
+	logger.Log(fmt.Sprintf("Successfully fetched compare diff, total size: %d bytes", len(combinedChanges)))
+	return combinedChanges, skipped, nil
 }
 
-func GetCurrentCommit(cfg *config.Config, repo string, prID int) (string, error) {
-	logger.Log(fmt.Sprintf("Getting current commit for PR #%d in repo %s", prID, repo))
+func shortSHA(sha string) string {
+	if len(sha) > 8 {
+		return sha[:8]
+	}
+	return sha
+}
+
+func GetPullRequestFiles(ctx context.Context, cfg *config.Config, repository string, prID int) ([]PullRequestFile, error) {
+	logger.Log(fmt.Sprintf("Getting changes for PR #%d in repo %s", prID, repository))
+
+	apiUrl := getFullApiUrl(cfg)
+	url := fmt.Sprintf("%s/repos/%s/pulls/%d/files", apiUrl, repository, prID)
+	return getPages[PullRequestFile](ctx, cfg, url, "pull request files")
+}
 
+// GetPullRequest fetches a single pull request, including its head SHA.
+func GetPullRequest(ctx context.Context, cfg *config.Config, repo string, prID int) (PullRequest, error) {
 	apiUrl := getFullApiUrl(cfg)
-	url := fmt.Sprintf("%s/repos/%s/pulls/%d/commits", apiUrl, repo, prID)
+	url := fmt.Sprintf("%s/repos/%s/pulls/%d", apiUrl, repo, prID)
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		logger.Log(fmt.Sprintf("Error creating request for GitHub PR commits: %v", err))
-		return "", err
+		logger.Log(fmt.Sprintf("Error creating request for GitHub PR: %v", err))
+		return PullRequest{}, err
 	}
 
 	req.Header.Set("Authorization", "token "+cfg.GitHubConfig.ApiToken)
 	req.Header.Set("Accept", "application/vnd.github.v3+json")
 
-	client := &http.Client{Timeout: 30 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
 		logger.Log(fmt.Sprintf("Error connecting to GitHub API (%s): %v", apiUrl, err))
-		return "", err
+		return PullRequest{}, err
 	}
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
@@ -195,61 +331,132 @@ func GetCurrentCommit(cfg *config.Config, repo string, prID int) (string, error)
 		body, _ := io.ReadAll(resp.Body)
 		errMsg := fmt.Sprintf("GitHub API responded with status code %d: %s", resp.StatusCode, string(body))
 		logger.Log(errMsg)
-		return "", fmt.Errorf(errMsg)
+		return PullRequest{}, fmt.Errorf(errMsg)
 	}
 
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		logger.Log(fmt.Sprintf("Error reading API response: %v", err))
-		return "", err
+	var pull repositoryPull
+	if err := json.NewDecoder(resp.Body).Decode(&pull); err != nil {
+		logger.Log(fmt.Sprintf("Error decoding GitHub pull request response: %v", err))
+		return PullRequest{}, err
 	}
-	logger.Log("API response: " + string(bodyBytes))
+	return toPullRequest(repo, pull), nil
+}
 
-	var commits []struct {
-		SHA string `json:"sha"`
-	}
+// GetCurrentCommit returns the head SHA of the pull request. Prefer
+// PullRequest.HeadSHA when the PR was already fetched from a list.
+func GetCurrentCommit(ctx context.Context, cfg *config.Config, repo string, prID int) (string, error) {
+	logger.Log(fmt.Sprintf("Getting current commit for PR #%d in repo %s", prID, repo))
 
-	if err := json.Unmarshal(bodyBytes, &commits); err != nil {
-		logger.Log(fmt.Sprintf("Error decoding GitHub commits response: %v", err))
+	pull, err := GetPullRequest(ctx, cfg, repo, prID)
+	if err != nil {
 		return "", err
 	}
 
-	if len(commits) > 0 {
-		logger.Log(fmt.Sprintf("Current commit for PR #%d: %s", prID, commits[len(commits)-1].SHA))
-		return commits[len(commits)-1].SHA, nil
+	if pull.HeadSHA != "" {
+		logger.Log(fmt.Sprintf("Current commit for PR #%d: %s", prID, pull.HeadSHA))
+		return pull.HeadSHA, nil
 	}
 
-	return "", fmt.Errorf("no commits found for pull request")
+	return "", fmt.Errorf("no head commit found for pull request")
+}
+
+type ReviewComment struct {
+	Path      string `json:"path"`
+	Line      int    `json:"line"`
+	Side      string `json:"side"`
+	StartLine int    `json:"start_line,omitempty"`
+	StartSide string `json:"start_side,omitempty"`
+	Body      string `json:"body"`
+}
+
+type reviewPayload struct {
+	CommitID string          `json:"commit_id,omitempty"`
+	Body     string          `json:"body,omitempty"`
+	Event    string          `json:"event"`
+	Comments []ReviewComment `json:"comments,omitempty"`
 }
 
-func AcceptPullRequest(cfg *config.Config, repository string, prNumber int, reviewMessage string) error {
+// SubmitPullRequestReview submits a single review with the given event
+// (COMMENT, REQUEST_CHANGES or APPROVE) on commitID, the head commit that was
+// reviewed. Findings that point at lines visible in the diff become inline
+// comments; the rest are appended to the review body. When the pull request
+// has moved past commitID, or GitHub rejects a comment position, all findings
+// go to the body instead.
+func SubmitPullRequestReview(ctx context.Context, cfg *config.Config, repository string, prNumber int, commitID, event string, reviewMessage string, findings []ai.Finding) error {
 	apiUrl := cfg.GitHubConfig.GetGitHubApiUrl()
 	url := fmt.Sprintf("%s/repos/%s/pulls/%d/reviews", apiUrl, repository, prNumber)
 
-	payload := map[string]string{
-		"body":  reviewMessage,
-		"event": "APPROVE",
+	payload := reviewPayload{
+		CommitID: commitID,
+		Body:     reviewMessage,
+		Event:    event,
+	}
+	if len(findings) > 0 {
+		payload.Body = ai.FormatReview(reviewMessage, findings)
+		pr, err := GetPullRequest(ctx, cfg, repository, prNumber)
+		if err != nil {
+			return err
+		}
+		if commitID != "" && pr.HeadSHA != commitID {
+			logger.Log(fmt.Sprintf("PR #%d moved from %s to %s since the review, submitting findings in the review body",
+				prNumber, shortSHA(commitID), shortSHA(pr.HeadSHA)))
+		} else {
+			files, err := GetPullRequestFiles(ctx, cfg, repository, prNumber)
+			if err != nil {
+				return err
+			}
+			var unmapped []ai.Finding
+			payload.Comments, unmapped = buildReviewComments(files, findings)
+			payload.Body = ai.FormatReview(reviewMessage, unmapped)
+			logger.Log(fmt.Sprintf("Submitting %d inline comments and %d findings in review body for PR #%d", len(payload.Comments), len(unmapped), prNumber))
+		}
+	}
+	// GitHub wymaga treści dla recenzji innych niż zatwierdzenie
+	if payload.Body == "" && event != "APPROVE" {
+		payload.Body = "LazyReview: " + ai.SeveritySummary(findings)
+	}
+
+	status, err := postReview(ctx, cfg, url, payload)
+	if status == http.StatusUnprocessableEntity && (len(payload.Comments) > 0 || payload.CommitID != "") {
+		// Jeden komentarz poza diffem (albo commit usunięty force-pushem) odrzuca całą recenzję
+		logger.Log(fmt.Sprintf("GitHub rejected the review positions on PR #%d, resubmitting findings in the review body", prNumber))
+		payload.CommitID = ""
+		payload.Comments = nil
+		payload.Body = ai.FormatReview(reviewMessage, findings)
+		if payload.Body == "" && event != "APPROVE" {
+			payload.Body = "LazyReview: " + ai.SeveritySummary(findings)
+		}
+		_, err = postReview(ctx, cfg, url, payload)
 	}
-	jsonPayload, err := json.Marshal(payload)
 	if err != nil {
-		logger.Log(fmt.Sprintf("Error marshaling accept review payload for GitHub: %v", err))
 		return err
 	}
+	logger.Log(fmt.Sprintf("Successfully submitted %s review for PR #%d in repository %s", event, prNumber, repository))
+	return nil
+}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonPayload))
+// postReview sends the review and returns the response status, 0 when no
+// response was received.
+func postReview(ctx context.Context, cfg *config.Config, url string, payload reviewPayload) (int, error) {
+	jsonPayload, err := json.Marshal(payload)
 	if err != nil {
-		logger.Log(fmt.Sprintf("Error creating request for accepting GitHub review: %v", err))
-		return err
+		logger.Log(fmt.Sprintf("Error marshaling review payload for GitHub: %v", err))
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error creating request for submitting GitHub review: %v", err))
+		return 0, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "token "+cfg.GitHubConfig.ApiToken)
 	req.Header.Set("Accept", "application/vnd.github.v3+json")
 
-	client := &http.Client{Timeout: 30 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		logger.Log(fmt.Sprintf("Error sending accept review request to GitHub: %v", err))
-		return err
+		logger.Log(fmt.Sprintf("Error sending review request to GitHub: %v", err))
+		return 0, err
 	}
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
@@ -260,10 +467,70 @@ func AcceptPullRequest(cfg *config.Config, repository string, prNumber int, revi
 
 	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		errMsg := fmt.Sprintf("GitHub API responded with status code %d on accept review: %s", resp.StatusCode, string(body))
+		errMsg := fmt.Sprintf("GitHub API responded with status code %d on review: %s", resp.StatusCode, string(body))
 		logger.Log(errMsg)
-		return fmt.Errorf(errMsg)
+		return resp.StatusCode, fmt.Errorf(errMsg)
 	}
-	logger.Log(fmt.Sprintf("Successfully accepted review for PR #%d in repository %s", prNumber, repository))
-	return nil
+	return resp.StatusCode, nil
+}
+
+func buildReviewComments(files []PullRequestFile, findings []ai.Finding) ([]ReviewComment, []ai.Finding) {
+	hunks := make(map[string][]diff.Hunk)
+	var paths []string
+	for _, file := range files {
+		if file.Patch != "" {
+			hunks[file.Filename] = diff.ParseHunks(file.Patch)
+			paths = append(paths, file.Filename)
+		}
+	}
+
+	var comments []ReviewComment
+	var unmapped []ai.Finding
+	for _, finding := range findings {
+		path, ok := diff.ResolvePath(finding.File, paths)
+		if !ok {
+			unmapped = append(unmapped, finding)
+			continue
+		}
+		fileHunks := hunks[path]
+		end := finding.EndLine
+		if end < finding.StartLine {
+			end = finding.StartLine
+		}
+		// GitHub wskazuje komentarz wielolinijkowy ostatnią linią zakresu
+		var last diff.Line
+		found := false
+		for n := end; n >= finding.StartLine && n > 0; n-- {
+			if line, ok := diff.FindNewLine(fileHunks, n); ok {
+				last, found = line, true
+				break
+			}
+		}
+		if !found {
+			unmapped = append(unmapped, finding)
+			continue
+		}
+		comment := ReviewComment{
+			Path: path,
+			Line: last.NewLine,
+			Side: "RIGHT",
+			Body: finding.Format(),
+		}
+		if first, ok := diff.FindNewLine(fileHunks, finding.StartLine); ok && first.NewLine < last.NewLine && sameHunk(fileHunks, first.NewLine, last.NewLine) {
+			comment.StartLine = first.NewLine
+			comment.StartSide = "RIGHT"
+		}
+		comments = append(comments, comment)
+	}
+	return comments, unmapped
+}
+
+func sameHunk(hunks []diff.Hunk, startLine, endLine int) bool {
+	for _, hunk := range hunks {
+		hunkEnd := hunk.NewStart + hunk.NewLines - 1
+		if startLine >= hunk.NewStart && endLine <= hunkEnd {
+			return true
+		}
+	}
+	return false
 }
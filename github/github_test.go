@@ -0,0 +1,168 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/michalopenmakers/lazyreview/ai"
+	"github.com/michalopenmakers/lazyreview/config"
+)
+
+const testPatch = "@@ -1,3 +1,5 @@\n package main\n+\n+import \"fmt\"\n \n func main() {}\n@@ -10,2 +12,3 @@\n func helper() {\n+\tfmt.Println()\n }"
+
+// testServer serves the GitHub API from handler and returns a config
+// pointing at it.
+func testServer(t *testing.T, handler http.HandlerFunc) *config.Config {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return &config.Config{GitHubConfig: config.GitHubConfig{Enabled: true, ApiToken: "token", ApiUrl: srv.URL}}
+}
+
+func TestBuildReviewComments(t *testing.T) {
+	files := []PullRequestFile{
+		{Filename: "main.go", Patch: testPatch},
+		{Filename: "logo.png"},
+	}
+	tests := []struct {
+		name     string
+		finding  ai.Finding
+		want     *ReviewComment
+		unmapped bool
+	}{
+		{name: "added line", finding: ai.Finding{File: "main.go", StartLine: 3}, want: &ReviewComment{Path: "main.go", Line: 3, Side: "RIGHT"}},
+		{name: "git prefix", finding: ai.Finding{File: "b/main.go", StartLine: 3}, want: &ReviewComment{Path: "main.go", Line: 3, Side: "RIGHT"}},
+		{name: "range", finding: ai.Finding{File: "main.go", StartLine: 2, EndLine: 4}, want: &ReviewComment{Path: "main.go", Line: 4, Side: "RIGHT", StartLine: 2, StartSide: "RIGHT"}},
+		{name: "range ending past the hunk", finding: ai.Finding{File: "main.go", StartLine: 4, EndLine: 8}, want: &ReviewComment{Path: "main.go", Line: 5, Side: "RIGHT", StartLine: 4, StartSide: "RIGHT"}},
+		{name: "range across hunks", finding: ai.Finding{File: "main.go", StartLine: 5, EndLine: 13}, want: &ReviewComment{Path: "main.go", Line: 13, Side: "RIGHT"}},
+		{name: "line outside the diff", finding: ai.Finding{File: "main.go", StartLine: 8}, unmapped: true},
+		{name: "file without patch", finding: ai.Finding{File: "logo.png", StartLine: 1}, unmapped: true},
+		{name: "unknown file", finding: ai.Finding{File: "other.go", StartLine: 1}, unmapped: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.finding.Severity, tt.finding.Message = "low", "Check this"
+			comments, unmapped := buildReviewComments(files, []ai.Finding{tt.finding})
+			if tt.unmapped {
+				if len(comments) != 0 || len(unmapped) != 1 {
+					t.Errorf("buildReviewComments() = %+v, %+v; want the finding unmapped", comments, unmapped)
+				}
+				return
+			}
+			if len(comments) != 1 || len(unmapped) != 0 {
+				t.Fatalf("buildReviewComments() = %+v, %+v; want one comment", comments, unmapped)
+			}
+			tt.want.Body = tt.finding.Format()
+			if comments[0] != *tt.want {
+				t.Errorf("comment = %+v, want %+v", comments[0], *tt.want)
+			}
+		})
+	}
+}
+
+// reviewServer serves a pull request at head and records the submitted
+// reviews, rejecting those with inline comments when rejectComments is set.
+func reviewServer(t *testing.T, head string, rejectComments bool, reviews *[]reviewPayload) *config.Config {
+	return testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/repos/o/r/pulls/5/reviews":
+			var payload reviewPayload
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+				t.Errorf("decoding review: %v", err)
+			}
+			*reviews = append(*reviews, payload)
+			if rejectComments && len(payload.Comments) > 0 {
+				http.Error(w, `{"message":"Line could not be resolved"}`, http.StatusUnprocessableEntity)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		case r.URL.Path == "/repos/o/r/pulls/5":
+			fmt.Fprintf(w, `{"number":5,"head":{"sha":%q}}`, head)
+		case r.URL.Path == "/repos/o/r/pulls/5/files":
+			json.NewEncoder(w).Encode([]PullRequestFile{{Filename: "main.go", Patch: testPatch}})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func TestSubmitPullRequestReview(t *testing.T) {
+	findings := []ai.Finding{
+		{File: "main.go", StartLine: 3, Severity: "high", Message: "Unused import"},
+		{File: "main.go", StartLine: 8, Severity: "low", Message: "Outside the diff"},
+	}
+	tests := []struct {
+		name           string
+		head           string
+		rejectComments bool
+		wantPosts      int
+		wantComments   int
+		wantCommit     string
+	}{
+		{name: "inline comments", head: "abc", wantPosts: 1, wantComments: 1, wantCommit: "abc"},
+		{name: "head moved", head: "def", wantPosts: 1, wantCommit: "abc"},
+		{name: "positions rejected", head: "abc", rejectComments: true, wantPosts: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reviews []reviewPayload
+			cfg := reviewServer(t, tt.head, tt.rejectComments, &reviews)
+			if err := SubmitPullRequestReview(context.Background(), cfg, "o/r", 5, "abc", "COMMENT", "Summary.", findings); err != nil {
+				t.Fatalf("SubmitPullRequestReview: %v", err)
+			}
+			if len(reviews) != tt.wantPosts {
+				t.Fatalf("posted %d reviews, want %d", len(reviews), tt.wantPosts)
+			}
+			last := reviews[len(reviews)-1]
+			if len(last.Comments) != tt.wantComments || last.CommitID != tt.wantCommit {
+				t.Errorf("review = %+v", last)
+			}
+			// Każde znalezisko trafia albo do komentarza, albo do treści recenzji
+			if !strings.Contains(last.Body, "Outside the diff") || strings.Contains(last.Body, "Unused import") != (tt.wantComments == 0) {
+				t.Errorf("review body = %q", last.Body)
+			}
+		})
+	}
+}
+
+func TestGetCompareChanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  string
+		files   int
+		wantErr bool
+	}{
+		{name: "ahead", status: "ahead", files: 2},
+		{name: "identical", status: "identical"},
+		{name: "diverged", status: "diverged", files: 2, wantErr: true},
+		{name: "behind", status: "behind", wantErr: true},
+		{name: "file limit", status: "ahead", files: compareFileLimit, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/repos/o/r/compare/aaa...bbb" {
+					http.NotFound(w, r)
+					return
+				}
+				files := make([]PullRequestFile, tt.files)
+				for i := range files {
+					files[i] = PullRequestFile{Filename: fmt.Sprintf("file%d.go", i), Patch: "@@ -1 +1 @@\n-a\n+b"}
+				}
+				json.NewEncoder(w).Encode(map[string]any{"status": tt.status, "files": files})
+			})
+			changes, _, err := GetCompareChanges(context.Background(), cfg, "o/r", "aaa", "bbb")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetCompareChanges error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && strings.Count(changes, "+b") != tt.files {
+				t.Errorf("GetCompareChanges() = %q, want %d files", changes, tt.files)
+			}
+		})
+	}
+}
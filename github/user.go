@@ -0,0 +1,73 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/michalopenmakers/lazyreview/config"
+	"github.com/michalopenmakers/lazyreview/httpclient"
+	"github.com/michalopenmakers/lazyreview/logger"
+)
+
+type User struct {
+	ID    int    `json:"id"`
+	Login string `json:"login"`
+	Name  string `json:"name"`
+}
+
+var users httpclient.UserCache[User]
+
+// GetCurrentUser returns the owner of the configured token. The result is
+// cached per API URL and token, so it is requested only once.
+func GetCurrentUser(ctx context.Context, cfg *config.Config) (*User, error) {
+	apiUrl := getFullApiUrl(cfg)
+	return users.Get(apiUrl, cfg.GitHubConfig.ApiToken, func() (*User, error) {
+		return fetchCurrentUser(ctx, cfg, apiUrl)
+	})
+}
+
+func fetchCurrentUser(ctx context.Context, cfg *config.Config, apiUrl string) (*User, error) {
+	logger.Log("Resolving GitHub user for the configured token")
+	url := fmt.Sprintf("%s/user", apiUrl)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error creating request for GitHub user: %v", err))
+		return nil, err
+	}
+	req.Header.Set("Authorization", "token "+cfg.GitHubConfig.ApiToken)
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error connecting to GitHub API (%s): %v", apiUrl, err))
+		return nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			logger.Log(fmt.Sprintf("Error closing response body: %v", err))
+		}
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		errMsg := fmt.Sprintf("GitHub API (user) responded with status code %d: %s", resp.StatusCode, string(body))
+		logger.Log(errMsg)
+		return nil, fmt.Errorf(errMsg)
+	}
+
+	user := &User{}
+	if err := json.NewDecoder(resp.Body).Decode(user); err != nil {
+		logger.Log(fmt.Sprintf("Error decoding GitHub user response: %v", err))
+		return nil, err
+	}
+	if user.Login == "" {
+		return nil, fmt.Errorf("GitHub returned no login for the configured token")
+	}
+
+	logger.Log(fmt.Sprintf("GitHub token belongs to %s", user.Login))
+	return user, nil
+}
@@ -0,0 +1,97 @@
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"os"
+
+	"github.com/michalopenmakers/lazyreview/config"
+	"github.com/michalopenmakers/lazyreview/filter"
+	"github.com/michalopenmakers/lazyreview/logger"
+)
+
+// GetFileContent returns the raw content of a file at the given ref. A
+// missing file yields an error wrapping os.ErrNotExist.
+func GetFileContent(ctx context.Context, cfg *config.Config, projectID, filePath, ref string) (string, error) {
+	apiUrl := cfg.GitLabConfig.GetFullApiUrl()
+	url := fmt.Sprintf("%s/projects/%s/repository/files/%s/raw?ref=%s", apiUrl, projectID, neturl.PathEscape(filePath), neturl.QueryEscape(ref))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error creating request for GitLab file: %v", err))
+		return "", err
+	}
+
+	req.Header.Set("PRIVATE-TOKEN", cfg.GitLabConfig.ApiToken)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error connecting to GitLab API (%s): %v", apiUrl, err))
+		return "", err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			logger.Log(fmt.Sprintf("Error closing response body: %v", err))
+		}
+	}(resp.Body)
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("%s not found at %s: %w", filePath, ref, os.ErrNotExist)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		errMsg := fmt.Sprintf("GitLab API (files) responded with status code %d: %s", resp.StatusCode, string(body))
+		logger.Log(errMsg)
+		return "", fmt.Errorf(errMsg)
+	}
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error reading GitLab file %s: %v", filePath, err))
+		return "", err
+	}
+	return string(content), nil
+}
+
+// loadFilter builds the diff filter, reading .gitattributes at ref when
+// generated files are to be detected.
+func loadFilter(ctx context.Context, cfg *config.Config, projectID, ref string) *filter.Filter {
+	f := filter.New(cfg.DiffFilter)
+	if !f.DetectsGenerated() || ref == "" {
+		return f
+	}
+	attributes, err := GetFileContent(ctx, cfg, projectID, ".gitattributes", ref)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Log(fmt.Sprintf("Error reading .gitattributes of project %s: %v", projectID, err))
+		}
+		return f
+	}
+	return f.WithAttributes(attributes)
+}
+
+// filterChanges drops the changes rejected by the filter.
+func filterChanges(f *filter.Filter, changes []Change) ([]Change, []filter.Skipped) {
+	var kept []Change
+	var skipped []filter.Skipped
+	for _, change := range changes {
+		filePath := change.NewPath
+		if change.DeletedFile {
+			filePath = change.OldPath
+		}
+		if reason, skip := f.Check(filePath, change.Diff); skip {
+			skipped = append(skipped, filter.Skipped{Path: filePath, Reason: reason})
+			continue
+		}
+		kept = append(kept, change)
+	}
+	if len(skipped) > 0 {
+		logger.Log(fmt.Sprintf("Skipped %d of %d changed files", len(skipped), len(changes)))
+	}
+	return kept, skipped
+}
@@ -2,43 +2,155 @@ package gitlab
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/michalopenmakers/lazyreview/ai"
 	"github.com/michalopenmakers/lazyreview/config"
+	"github.com/michalopenmakers/lazyreview/diff"
+	"github.com/michalopenmakers/lazyreview/filter"
+	"github.com/michalopenmakers/lazyreview/httpclient"
 	"io"
 	"net/http"
+	neturl "net/url"
 	"strings"
 	"time"
 
 	"github.com/michalopenmakers/lazyreview/logger"
 )
 
+// client is shared by all GitLab API calls, so rate limits are tracked in one
+// place.
+var client = httpclient.New("gitlab", 30*time.Second)
+
+// getPages fetches every page of a GitLab list endpoint, up to
+// Config.MaxPages. what names the endpoint in logs.
+func getPages[T any](ctx context.Context, cfg *config.Config, url, what string) ([]T, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", httpclient.WithPageSize(url), nil)
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error creating request for %s: %v", what, err))
+		return nil, err
+	}
+	req.Header.Set("PRIVATE-TOKEN", cfg.GitLabConfig.ApiToken)
+
+	items, err := httpclient.GetPages[T](client, req, cfg.MaxPages, httpclient.GitLabNextPage, what)
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error fetching %s from GitLab: %v", what, err))
+		return nil, err
+	}
+	return items, nil
+}
+
 type MergeRequest struct {
-	IID       int    `json:"iid"`
-	ProjectID int    `json:"project_id"`
-	Title     string `json:"title"`
-	WebURL    string `json:"web_url"`
+	IID          int      `json:"iid"`
+	ProjectID    int      `json:"project_id"`
+	Title        string   `json:"title"`
+	WebURL       string   `json:"web_url"`
+	TargetBranch string   `json:"target_branch"`
+	Labels       []string `json:"labels"`
+	State        string   `json:"state"`
+	Author       struct {
+		Username string `json:"username"`
+	} `json:"author"`
+	Reviewers []struct {
+		Username string `json:"username"`
+	} `json:"reviewers"`
+	SHA string `json:"sha"`
+	// DiffRefs is returned only by the single merge request endpoint.
+	DiffRefs *DiffRefs `json:"diff_refs"`
 }
 
-func GetMergeRequestChanges(cfg *config.Config, projectID string, mrID int) (string, error) {
-	logger.Log(fmt.Sprintf("Getting changes for MR #%d in project %s", mrID, projectID))
+// HeadSHA returns the commit the merge request currently points at.
+func (mr MergeRequest) HeadSHA() string {
+	if mr.DiffRefs != nil && mr.DiffRefs.HeadSHA != "" {
+		return mr.DiffRefs.HeadSHA
+	}
+	return mr.SHA
+}
+
+type DiffRefs struct {
+	BaseSHA  string `json:"base_sha"`
+	HeadSHA  string `json:"head_sha"`
+	StartSHA string `json:"start_sha"`
+}
+
+type Change struct {
+	OldPath     string `json:"old_path"`
+	NewPath     string `json:"new_path"`
+	Diff        string `json:"diff"`
+	NewFile     bool   `json:"new_file"`
+	RenamedFile bool   `json:"renamed_file"`
+	DeletedFile bool   `json:"deleted_file"`
+}
+
+type MergeRequestChanges struct {
+	SHA      string   `json:"sha"`
+	DiffRefs DiffRefs `json:"diff_refs"`
+	Changes  []Change `json:"changes"`
+}
+
+// GetMergeRequestChanges returns the combined diff of the merge request
+// without the files rejected by the configured diff filter, which are listed
+// separately.
+func GetMergeRequestChanges(ctx context.Context, cfg *config.Config, projectID string, mrID int) (string, []filter.Skipped, error) {
+	mrChanges, err := GetMergeRequestDiff(ctx, cfg, projectID, mrID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	ref := mrChanges.DiffRefs.HeadSHA
+	if ref == "" {
+		ref = mrChanges.SHA
+	}
+	f := loadFilter(ctx, cfg, projectID, ref)
+	changes, skipped := filterChanges(f, mrChanges.Changes)
+	combinedDiff := combineChanges(changes)
+	logger.Log(fmt.Sprintf("Successfully fetched changes for MR #%d, total size: %d bytes", mrID, len(combinedDiff)))
+	return combinedDiff, skipped, nil
+}
+
+func combineChanges(changes []Change) string {
+	var combinedDiff string
+	for _, change := range changes {
+		fileHeader := fmt.Sprintf("--- %s\n+++ %s\n", change.OldPath, change.NewPath)
+		combinedDiff += fileHeader + change.Diff + "\n\n"
+	}
+	return combinedDiff
+}
+
+// GetCompareChanges returns the combined diff between two commits of the
+// project, filtered like GetMergeRequestChanges. It is used to review only the
+// commits pushed since the last review, and fails when from is no longer an
+// ancestor of to, e.g. after a force push.
+func GetCompareChanges(ctx context.Context, cfg *config.Config, projectID, from, to string) (string, []filter.Skipped, error) {
+	logger.Log(fmt.Sprintf("Comparing %s...%s in project %s", shortSHA(from), shortSHA(to), projectID))
+
+	base, err := mergeBase(ctx, cfg, projectID, from, to)
+	if err != nil {
+		return "", nil, err
+	}
+	if base != from {
+		return "", nil, fmt.Errorf("commit %s is no longer an ancestor of %s", shortSHA(from), shortSHA(to))
+	}
 
 	apiUrl := cfg.GitLabConfig.GetFullApiUrl()
-	url := fmt.Sprintf("%s/projects/%s/merge_requests/%d/changes", apiUrl, projectID, mrID)
+	query := neturl.Values{}
+	query.Set("from", from)
+	query.Set("to", to)
+	url := fmt.Sprintf("%s/projects/%s/repository/compare?%s", apiUrl, neturl.PathEscape(projectID), query.Encode())
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		logger.Log(fmt.Sprintf("Error creating request for GitLab MR changes: %v", err))
-		return "", err
+		logger.Log(fmt.Sprintf("Error creating request for GitLab compare: %v", err))
+		return "", nil, err
 	}
 
 	req.Header.Set("PRIVATE-TOKEN", cfg.GitLabConfig.ApiToken)
 
-	client := &http.Client{Timeout: 30 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
 		logger.Log(fmt.Sprintf("Error connecting to GitLab API (%s): %v", apiUrl, err))
-		return "", err
+		return "", nil, err
 	}
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
@@ -49,57 +161,89 @@ func GetMergeRequestChanges(cfg *config.Config, projectID string, mrID int) (str
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		errMsg := fmt.Sprintf("GitLab API responded with status code %d: %s", resp.StatusCode, string(body))
+		errMsg := fmt.Sprintf("GitLab API (compare) responded with status code %d: %s", resp.StatusCode, string(body))
 		logger.Log(errMsg)
-		return "", fmt.Errorf(errMsg)
+		return "", nil, fmt.Errorf(errMsg)
 	}
 
-	bodyBytes, err := io.ReadAll(resp.Body)
+	var compare struct {
+		Diffs []Change `json:"diffs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&compare); err != nil {
+		logger.Log(fmt.Sprintf("Error decoding GitLab compare response: %v", err))
+		return "", nil, err
+	}
+
+	changes, skipped := filterChanges(loadFilter(ctx, cfg, projectID, to), compare.Diffs)
+	combinedDiff := combineChanges(changes)
+	logger.Log(fmt.Sprintf("Successfully fetched compare diff, total size: %d bytes", len(combinedDiff)))
+	return combinedDiff, skipped, nil
+}
+
+// mergeBase returns the common ancestor of two commits of the project.
+func mergeBase(ctx context.Context, cfg *config.Config, projectID, from, to string) (string, error) {
+	apiUrl := cfg.GitLabConfig.GetFullApiUrl()
+	query := neturl.Values{}
+	query.Add("refs[]", from)
+	query.Add("refs[]", to)
+	url := fmt.Sprintf("%s/projects/%s/repository/merge_base?%s", apiUrl, neturl.PathEscape(projectID), query.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		logger.Log(fmt.Sprintf("Error reading API response: %v", err))
+		logger.Log(fmt.Sprintf("Error creating request for GitLab merge base: %v", err))
 		return "", err
 	}
-	logger.Log("API response: " + string(bodyBytes))
 
-	var response struct {
-		Changes []struct {
-			OldPath     string `json:"old_path"`
-			NewPath     string `json:"new_path"`
-			Diff        string `json:"diff"`
-			RenamedFile bool   `json:"renamed_file"`
-		} `json:"changes"`
-	}
+	req.Header.Set("PRIVATE-TOKEN", cfg.GitLabConfig.ApiToken)
 
-	if err := json.Unmarshal(bodyBytes, &response); err != nil {
-		logger.Log(fmt.Sprintf("Error decoding GitLab response: %v", err))
+	resp, err := client.Do(req)
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error connecting to GitLab API (%s): %v", apiUrl, err))
 		return "", err
 	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			logger.Log(fmt.Sprintf("Error closing response body: %v", err))
+		}
+	}(resp.Body)
 
-	var combinedDiff string
-	for _, change := range response.Changes {
-		fileHeader := fmt.Sprintf("--- %s\n+++ %s\n", change.OldPath, change.NewPath)
-		combinedDiff += fileHeader + change.Diff + "\n\n"
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("GitLab API (merge base) responded with status code %d: %s", resp.StatusCode, string(body))
 	}
 
-	logger.Log(fmt.Sprintf("Successfully fetched changes for MR #%d, total size: %d bytes", mrID, len(combinedDiff)))
-	return combinedDiff, nil
+	var commit struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&commit); err != nil {
+		logger.Log(fmt.Sprintf("Error decoding GitLab merge base response: %v", err))
+		return "", err
+	}
+	return commit.ID, nil
 }
 
-func GetMergeRequestsToReview(cfg *config.Config) ([]MergeRequest, error) {
-	logger.Log("Fetching GitLab merge requests assigned for review")
+func shortSHA(sha string) string {
+	if len(sha) > 8 {
+		return sha[:8]
+	}
+	return sha
+}
+
+func GetMergeRequestDiff(ctx context.Context, cfg *config.Config, projectID string, mrID int) (*MergeRequestChanges, error) {
+	logger.Log(fmt.Sprintf("Getting changes for MR #%d in project %s", mrID, projectID))
 
 	apiUrl := cfg.GitLabConfig.GetFullApiUrl()
-	url := fmt.Sprintf("%s/merge_requests?reviewer_username=michal.zuchowski&state=opened", apiUrl)
+	url := fmt.Sprintf("%s/projects/%s/merge_requests/%d/changes", apiUrl, projectID, mrID)
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		logger.Log(fmt.Sprintf("Error creating request for GitLab MRs: %v", err))
+		logger.Log(fmt.Sprintf("Error creating request for GitLab MR changes: %v", err))
 		return nil, err
 	}
 
 	req.Header.Set("PRIVATE-TOKEN", cfg.GitLabConfig.ApiToken)
 
-	client := &http.Client{Timeout: 30 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
 		logger.Log(fmt.Sprintf("Error connecting to GitLab API (%s): %v", apiUrl, err))
@@ -124,37 +268,95 @@ func GetMergeRequestsToReview(cfg *config.Config) ([]MergeRequest, error) {
 		logger.Log(fmt.Sprintf("Error reading API response: %v", err))
 		return nil, err
 	}
-	logger.Log("API response: " + string(bodyBytes))
 
-	var mergeRequests []MergeRequest
-	if err := json.Unmarshal(bodyBytes, &mergeRequests); err != nil {
+	var response MergeRequestChanges
+	if err := json.Unmarshal(bodyBytes, &response); err != nil {
 		logger.Log(fmt.Sprintf("Error decoding GitLab response: %v", err))
 		return nil, err
 	}
+	return &response, nil
+}
+
+func GetMergeRequestsToReview(ctx context.Context, cfg *config.Config) ([]MergeRequest, error) {
+	logger.Log("Fetching GitLab merge requests assigned for review")
+
+	user, err := GetCurrentUser(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	apiUrl := cfg.GitLabConfig.GetFullApiUrl()
+	url := fmt.Sprintf("%s/merge_requests?reviewer_username=%s&state=opened", apiUrl, neturl.QueryEscape(user.Username))
+
+	mergeRequests, err := getPages[MergeRequest](ctx, cfg, url, "merge requests")
+	if err != nil {
+		return nil, err
+	}
 
 	logger.Log(fmt.Sprintf("Successfully fetched %d merge requests for review", len(mergeRequests)))
 	return mergeRequests, nil
 }
 
-func GetCurrentCommit(cfg *config.Config, projectID string, mrID int) (string, error) {
-	logger.Log(fmt.Sprintf("Getting current commit for MR #%d in project %s", mrID, projectID))
+// GetWatchedMergeRequests returns the open merge requests of all projects
+// listed in GitLabConfig.ProjectIDs that pass the configured watch filter.
+func GetWatchedMergeRequests(ctx context.Context, cfg *config.Config) ([]MergeRequest, error) {
+	logger.Log(fmt.Sprintf("Fetching open GitLab merge requests in %d watched projects", len(cfg.GitLabConfig.ProjectIDs)))
+
+	var mergeRequests []MergeRequest
+	for _, projectID := range cfg.GitLabConfig.ProjectIDs {
+		projectID = strings.TrimSpace(projectID)
+		if projectID == "" {
+			continue
+		}
+		projectMRs, err := getProjectMergeRequests(ctx, cfg, projectID)
+		if err != nil {
+			logger.Log(fmt.Sprintf("Error fetching merge requests of project %s: %v", projectID, err))
+			continue
+		}
+		for _, mr := range projectMRs {
+			if cfg.GitLabConfig.WatchFilter.Matches(mr.Labels, mr.TargetBranch, mr.Author.Username) {
+				mergeRequests = append(mergeRequests, mr)
+			}
+		}
+	}
+
+	logger.Log(fmt.Sprintf("Successfully fetched %d merge requests from watched projects", len(mergeRequests)))
+	return mergeRequests, nil
+}
+
+func getProjectMergeRequests(ctx context.Context, cfg *config.Config, projectID string) ([]MergeRequest, error) {
+	apiUrl := cfg.GitLabConfig.GetFullApiUrl()
+	query := neturl.Values{}
+	query.Set("state", "opened")
+	filter := cfg.GitLabConfig.WatchFilter
+	if len(filter.Labels) > 0 {
+		query.Set("labels", strings.Join(filter.Labels, ","))
+	}
+	if filter.TargetBranch != "" {
+		query.Set("target_branch", filter.TargetBranch)
+	}
+	url := fmt.Sprintf("%s/projects/%s/merge_requests?%s", apiUrl, neturl.PathEscape(projectID), query.Encode())
+	return getPages[MergeRequest](ctx, cfg, url, "project merge requests")
+}
 
+// GetMergeRequest fetches a single merge request. projectID may also be
+// the URL-encoded path of the project.
+func GetMergeRequest(ctx context.Context, cfg *config.Config, projectID string, mrID int) (MergeRequest, error) {
 	apiUrl := cfg.GitLabConfig.GetFullApiUrl()
-	url := fmt.Sprintf("%s/projects/%s/merge_requests/%d/commits", apiUrl, projectID, mrID)
+	url := fmt.Sprintf("%s/projects/%s/merge_requests/%d", apiUrl, projectID, mrID)
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		logger.Log(fmt.Sprintf("Error creating request for GitLab MR commits: %v", err))
-		return "", err
+		logger.Log(fmt.Sprintf("Error creating request for GitLab MR: %v", err))
+		return MergeRequest{}, err
 	}
 
 	req.Header.Set("PRIVATE-TOKEN", cfg.GitLabConfig.ApiToken)
 
-	client := &http.Client{Timeout: 30 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
 		logger.Log(fmt.Sprintf("Error connecting to GitLab API (%s): %v", apiUrl, err))
-		return "", err
+		return MergeRequest{}, err
 	}
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
@@ -167,56 +369,60 @@ func GetCurrentCommit(cfg *config.Config, projectID string, mrID int) (string, e
 		body, _ := io.ReadAll(resp.Body)
 		errMsg := fmt.Sprintf("GitLab API responded with status code %d: %s", resp.StatusCode, string(body))
 		logger.Log(errMsg)
-		return "", fmt.Errorf(errMsg)
+		return MergeRequest{}, fmt.Errorf(errMsg)
 	}
 
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		logger.Log(fmt.Sprintf("Error reading API response: %v", err))
-		return "", err
+	var mr MergeRequest
+	if err := json.NewDecoder(resp.Body).Decode(&mr); err != nil {
+		logger.Log(fmt.Sprintf("Error decoding GitLab merge request response: %v", err))
+		return MergeRequest{}, err
 	}
-	logger.Log("API response: " + string(bodyBytes))
+	return mr, nil
+}
 
-	var commits []struct {
-		ID string `json:"id"`
-	}
+// GetCurrentCommit returns the head SHA of the merge request. Prefer
+// MergeRequest.HeadSHA when the MR was already fetched from a list.
+func GetCurrentCommit(ctx context.Context, cfg *config.Config, projectID string, mrID int) (string, error) {
+	logger.Log(fmt.Sprintf("Getting current commit for MR #%d in project %s", mrID, projectID))
 
-	if err := json.Unmarshal(bodyBytes, &commits); err != nil {
-		logger.Log(fmt.Sprintf("Error decoding GitLab commits response: %v", err))
+	mr, err := GetMergeRequest(ctx, cfg, projectID, mrID)
+	if err != nil {
 		return "", err
 	}
 
-	if len(commits) > 0 {
-		logger.Log(fmt.Sprintf("Current commit for MR #%d: %s", mrID, commits[0].ID))
-		return commits[0].ID, nil
+	if sha := mr.HeadSHA(); sha != "" {
+		logger.Log(fmt.Sprintf("Current commit for MR #%d: %s", mrID, sha))
+		return sha, nil
 	}
 
-	return "", fmt.Errorf("no commits found for merge request")
+	return "", fmt.Errorf("no head commit found for merge request")
 }
 
-func AcceptMergeRequestReview(cfg *config.Config, projectID string, mrID int, reviewText string) error {
+func AcceptMergeRequestReview(ctx context.Context, cfg *config.Config, projectID string, mrID int, reviewText string) error {
 	logger.Log(fmt.Sprintf("Accepting review for MR #%d in project %s", mrID, projectID))
-	apiUrl := cfg.GitLabConfig.GetFullApiUrl()
-	discussionUrl := fmt.Sprintf("%s/projects/%s/merge_requests/%d/discussions", apiUrl, projectID, mrID)
-	payload := map[string]string{
+	payload := map[string]any{
 		"body": reviewText,
 	}
-	jsonPayload, err := json.Marshal(payload)
-	if err != nil {
-		logger.Log(fmt.Sprintf("Error marshaling review payload: %v", err))
+	if err := createDiscussion(ctx, cfg, projectID, mrID, payload); err != nil {
 		return err
 	}
-	req, err := http.NewRequest("POST", discussionUrl, bytes.NewBuffer(jsonPayload))
+	logger.Log(fmt.Sprintf("Successfully accepted review for MR #%d", mrID))
+	return nil
+}
+
+func ApproveMergeRequest(ctx context.Context, cfg *config.Config, projectID string, mrID int) error {
+	logger.Log(fmt.Sprintf("Approving MR #%d in project %s", mrID, projectID))
+	apiUrl := cfg.GitLabConfig.GetFullApiUrl()
+	url := fmt.Sprintf("%s/projects/%s/merge_requests/%d/approve", apiUrl, projectID, mrID)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
 	if err != nil {
-		logger.Log(fmt.Sprintf("Error creating request for review: %v", err))
+		logger.Log(fmt.Sprintf("Error creating request for approval: %v", err))
 		return err
 	}
-	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("PRIVATE-TOKEN", cfg.GitLabConfig.ApiToken)
-	client := &http.Client{Timeout: 30 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		logger.Log(fmt.Sprintf("Error sending review request: %v", err))
+		logger.Log(fmt.Sprintf("Error sending approval request: %v", err))
 		return err
 	}
 	defer func(Body io.ReadCloser) {
@@ -227,31 +433,120 @@ func AcceptMergeRequestReview(cfg *config.Config, projectID string, mrID int, re
 	}(resp.Body)
 	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		errMsg := fmt.Sprintf("GitLab API responded with status code %d on review: %s", resp.StatusCode, string(body))
+		errMsg := fmt.Sprintf("GitLab API responded with status code %d on approve: %s", resp.StatusCode, string(body))
 		logger.Log(errMsg)
 		return fmt.Errorf(errMsg)
 	}
-	logger.Log(fmt.Sprintf("Successfully accepted review for MR #%d", mrID))
+	logger.Log(fmt.Sprintf("Successfully approved MR #%d", mrID))
 	return nil
 }
 
-func HasMyComment(cfg *config.Config, projectID string, mrID int) (bool, error) {
-	logger.Log(fmt.Sprintf("Checking for my comment in MR #%d (project %s)", mrID, projectID))
-	apiUrl := cfg.GitLabConfig.GetFullApiUrl()
-	url := fmt.Sprintf("%s/projects/%s/merge_requests/%d/discussions", apiUrl, projectID, mrID)
-
-	req, err := http.NewRequest("GET", url, nil)
+// PostMergeRequestFindings posts every finding as a discussion positioned on
+// its line of the diff. Findings that cannot be mapped to a diff line are
+// collected, together with the summary, in a single general discussion.
+// reviewedSHA is the head commit the findings refer to; when the merge
+// request has moved past it, the line numbers no longer match and all
+// findings go to the general discussion.
+func PostMergeRequestFindings(ctx context.Context, cfg *config.Config, projectID string, mrID int, reviewedSHA, summary string, findings []ai.Finding) error {
+	logger.Log(fmt.Sprintf("Posting %d findings as diff discussions on MR #%d in project %s", len(findings), mrID, projectID))
+	mrChanges, err := GetMergeRequestDiff(ctx, cfg, projectID, mrID)
 	if err != nil {
-		logger.Log(fmt.Sprintf("Error creating request for discussions: %v", err))
-		return false, err
+		return err
+	}
+
+	var unmapped []ai.Finding
+	posted := 0
+	if reviewedSHA != "" && mrChanges.DiffRefs.HeadSHA != reviewedSHA {
+		logger.Log(fmt.Sprintf("MR #%d moved from %s to %s since the review, posting findings in the summary",
+			mrID, shortSHA(reviewedSHA), shortSHA(mrChanges.DiffRefs.HeadSHA)))
+		unmapped = findings
+		findings = nil
+	}
+	for _, finding := range findings {
+		position, ok := findingPosition(mrChanges, finding)
+		if !ok {
+			unmapped = append(unmapped, finding)
+			continue
+		}
+		payload := map[string]any{
+			"body":     finding.Format(),
+			"position": position,
+		}
+		if err := createDiscussion(ctx, cfg, projectID, mrID, payload); err != nil {
+			logger.Log(fmt.Sprintf("Could not post finding at %s inline, adding it to the summary: %v", finding.Location(), err))
+			unmapped = append(unmapped, finding)
+			continue
+		}
+		posted++
+	}
+
+	if summary != "" || len(unmapped) > 0 {
+		body := ai.FormatReview(summary, unmapped)
+		if err := createDiscussion(ctx, cfg, projectID, mrID, map[string]any{"body": body}); err != nil {
+			return err
+		}
+	}
+	logger.Log(fmt.Sprintf("Posted %d inline and %d summary findings on MR #%d", posted, len(unmapped), mrID))
+	return nil
+}
+
+func findingPosition(mrChanges *MergeRequestChanges, finding ai.Finding) (map[string]any, bool) {
+	if mrChanges.DiffRefs.HeadSHA == "" {
+		return nil, false
+	}
+	paths := make([]string, 0, len(mrChanges.Changes))
+	for _, change := range mrChanges.Changes {
+		paths = append(paths, change.NewPath)
+	}
+	path, ok := diff.ResolvePath(finding.File, paths)
+	if !ok {
+		return nil, false
+	}
+	for _, change := range mrChanges.Changes {
+		if change.NewPath != path {
+			continue
+		}
+		line, ok := diff.FindLineInRange(diff.ParseHunks(change.Diff), finding.StartLine, finding.EndLine)
+		if !ok {
+			return nil, false
+		}
+		position := map[string]any{
+			"position_type": "text",
+			"base_sha":      mrChanges.DiffRefs.BaseSHA,
+			"start_sha":     mrChanges.DiffRefs.StartSHA,
+			"head_sha":      mrChanges.DiffRefs.HeadSHA,
+			"old_path":      change.OldPath,
+			"new_path":      change.NewPath,
+			"new_line":      line.NewLine,
+		}
+		// Niezmienione linie wymagają podania również numeru w starej wersji pliku
+		if line.Kind == diff.Context {
+			position["old_line"] = line.OldLine
+		}
+		return position, true
 	}
+	return nil, false
+}
 
+func createDiscussion(ctx context.Context, cfg *config.Config, projectID string, mrID int, payload map[string]any) error {
+	apiUrl := cfg.GitLabConfig.GetFullApiUrl()
+	discussionUrl := fmt.Sprintf("%s/projects/%s/merge_requests/%d/discussions", apiUrl, projectID, mrID)
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error marshaling review payload: %v", err))
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", discussionUrl, bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error creating request for review: %v", err))
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("PRIVATE-TOKEN", cfg.GitLabConfig.ApiToken)
-	client := &http.Client{Timeout: 30 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		logger.Log(fmt.Sprintf("Error connecting to GitLab API for discussions: %v", err))
-		return false, err
+		logger.Log(fmt.Sprintf("Error sending review request: %v", err))
+		return err
 	}
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
@@ -259,39 +554,42 @@ func HasMyComment(cfg *config.Config, projectID string, mrID int) (bool, error)
 			logger.Log(fmt.Sprintf("Error closing response body: %v", err))
 		}
 	}(resp.Body)
-
-	if resp.StatusCode != http.StatusOK {
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		errMsg := fmt.Sprintf("GitLab API (discussions) responded with status code %d: %s", resp.StatusCode, string(body))
+		errMsg := fmt.Sprintf("GitLab API responded with status code %d on review: %s", resp.StatusCode, string(body))
 		logger.Log(errMsg)
-		return false, fmt.Errorf(errMsg)
+		return fmt.Errorf(errMsg)
 	}
+	return nil
+}
 
-	bodyBytes, err := io.ReadAll(resp.Body)
+type mrDiscussion struct {
+	ID    string `json:"id"`
+	Notes []struct {
+		ID     int    `json:"id"`
+		Body   string `json:"body"`
+		Author struct {
+			Username string `json:"username"`
+		} `json:"author"`
+		System bool `json:"system"`
+	} `json:"notes"`
+}
+
+func HasMyComment(ctx context.Context, cfg *config.Config, projectID string, mrID int) (bool, error) {
+	logger.Log(fmt.Sprintf("Checking for my comment in MR #%d (project %s)", mrID, projectID))
+	user, err := GetCurrentUser(ctx, cfg)
 	if err != nil {
-		logger.Log(fmt.Sprintf("Error reading discussions response: %v", err))
 		return false, err
 	}
+	myUsername := user.Username
 
-	var discussions []struct {
-		ID    string `json:"id"`
-		Notes []struct {
-			ID     int    `json:"id"`
-			Body   string `json:"body"`
-			Author struct {
-				Username string `json:"username"`
-			} `json:"author"`
-			System bool `json:"system"`
-		} `json:"notes"`
-	}
-
-	err = json.Unmarshal(bodyBytes, &discussions)
+	apiUrl := cfg.GitLabConfig.GetFullApiUrl()
+	url := fmt.Sprintf("%s/projects/%s/merge_requests/%d/discussions", apiUrl, projectID, mrID)
+	discussions, err := getPages[mrDiscussion](ctx, cfg, url, "discussions")
 	if err != nil {
-		logger.Log(fmt.Sprintf("Error decoding discussions response: %v", err))
 		return false, err
 	}
 
-	myUsername := "michal.zuchowski"
 	foundMyComment := false
 
 	logger.Log(fmt.Sprintf("Found %d discussions in MR #%d", len(discussions), mrID))
@@ -323,63 +621,21 @@ func HasMyComment(cfg *config.Config, projectID string, mrID int) (bool, error)
 	return foundMyComment, nil
 }
 
-func HasReplyOnMyComment(cfg *config.Config, projectID string, mrID int) (bool, error) {
+func HasReplyOnMyComment(ctx context.Context, cfg *config.Config, projectID string, mrID int) (bool, error) {
 	logger.Log(fmt.Sprintf("Checking for replies on my comments in MR #%d (project %s)", mrID, projectID))
-	apiUrl := cfg.GitLabConfig.GetFullApiUrl()
-	url := fmt.Sprintf("%s/projects/%s/merge_requests/%d/discussions", apiUrl, projectID, mrID)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		logger.Log(fmt.Sprintf("Error creating request for discussions: %v", err))
-		return false, err
-	}
-
-	req.Header.Set("PRIVATE-TOKEN", cfg.GitLabConfig.ApiToken)
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		logger.Log(fmt.Sprintf("Error connecting to GitLab API for discussions: %v", err))
-		return false, err
-	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			logger.Log(fmt.Sprintf("Error closing response body: %v", err))
-		}
-	}(resp.Body)
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		errMsg := fmt.Sprintf("GitLab API (discussions) responded with status code %d: %s", resp.StatusCode, string(body))
-		logger.Log(errMsg)
-		return false, fmt.Errorf(errMsg)
-	}
-
-	bodyBytes, err := io.ReadAll(resp.Body)
+	user, err := GetCurrentUser(ctx, cfg)
 	if err != nil {
-		logger.Log(fmt.Sprintf("Error reading discussions response: %v", err))
 		return false, err
 	}
+	myUsername := user.Username
 
-	var discussions []struct {
-		ID    string `json:"id"`
-		Notes []struct {
-			ID     int    `json:"id"`
-			Body   string `json:"body"`
-			Author struct {
-				Username string `json:"username"`
-			} `json:"author"`
-		} `json:"notes"`
-	}
-
-	err = json.Unmarshal(bodyBytes, &discussions)
+	apiUrl := cfg.GitLabConfig.GetFullApiUrl()
+	url := fmt.Sprintf("%s/projects/%s/merge_requests/%d/discussions", apiUrl, projectID, mrID)
+	discussions, err := getPages[mrDiscussion](ctx, cfg, url, "discussions")
 	if err != nil {
-		logger.Log(fmt.Sprintf("Error decoding discussions response: %v", err))
 		return false, err
 	}
 
-	myUsername := "michal.zuchowski"
-
 	logger.Log(fmt.Sprintf("Analyzing %d discussions for replies in MR #%d", len(discussions), mrID))
 
 	for i, discussion := range discussions {
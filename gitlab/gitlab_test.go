@@ -0,0 +1,168 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/michalopenmakers/lazyreview/ai"
+	"github.com/michalopenmakers/lazyreview/config"
+)
+
+const testDiff = "@@ -1,3 +1,5 @@\n package main\n+\n+import \"fmt\"\n \n func main() {}\n"
+
+var testChanges = &MergeRequestChanges{
+	DiffRefs: DiffRefs{BaseSHA: "base", StartSHA: "start", HeadSHA: "head"},
+	Changes: []Change{
+		{OldPath: "old.go", NewPath: "main.go", Diff: testDiff, RenamedFile: true},
+		{OldPath: "a/handler.go", NewPath: "a/handler.go", Diff: testDiff},
+	},
+}
+
+// testServer serves the GitLab API from handler and returns a config
+// pointing at it.
+func testServer(t *testing.T, handler http.HandlerFunc) *config.Config {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return &config.Config{GitLabConfig: config.GitLabConfig{Enabled: true, ApiToken: "token", ApiUrl: srv.URL}}
+}
+
+func TestFindingPosition(t *testing.T) {
+	tests := []struct {
+		name    string
+		finding ai.Finding
+		want    map[string]any
+	}{
+		{name: "added line", finding: ai.Finding{File: "main.go", StartLine: 3},
+			want: map[string]any{"old_path": "old.go", "new_path": "main.go", "new_line": 3}},
+		{name: "context line", finding: ai.Finding{File: "main.go", StartLine: 4},
+			want: map[string]any{"old_path": "old.go", "new_path": "main.go", "new_line": 4, "old_line": 2}},
+		{name: "git prefix", finding: ai.Finding{File: "b/main.go", StartLine: 2},
+			want: map[string]any{"old_path": "old.go", "new_path": "main.go", "new_line": 2}},
+		{name: "directory named a", finding: ai.Finding{File: "a/handler.go", StartLine: 2},
+			want: map[string]any{"old_path": "a/handler.go", "new_path": "a/handler.go", "new_line": 2}},
+		{name: "line outside the diff", finding: ai.Finding{File: "main.go", StartLine: 20}},
+		{name: "unknown file", finding: ai.Finding{File: "other.go", StartLine: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			position, ok := findingPosition(testChanges, tt.finding)
+			if ok != (tt.want != nil) {
+				t.Fatalf("findingPosition() = %v, %v", position, ok)
+			}
+			if !ok {
+				return
+			}
+			tt.want["position_type"] = "text"
+			tt.want["base_sha"], tt.want["start_sha"], tt.want["head_sha"] = "base", "start", "head"
+			if fmt.Sprint(position) != fmt.Sprint(tt.want) {
+				t.Errorf("findingPosition() = %v, want %v", position, tt.want)
+			}
+		})
+	}
+
+	if _, ok := findingPosition(&MergeRequestChanges{Changes: testChanges.Changes}, ai.Finding{File: "main.go", StartLine: 3}); ok {
+		t.Error("findingPosition() positioned a finding without diff refs")
+	}
+}
+
+func TestPostMergeRequestFindings(t *testing.T) {
+	findings := []ai.Finding{
+		{File: "main.go", StartLine: 3, Severity: "high", Message: "Unused import"},
+		{File: "main.go", StartLine: 20, Severity: "low", Message: "Outside the diff"},
+	}
+	tests := []struct {
+		name        string
+		reviewedSHA string
+		wantInline  int
+	}{
+		{name: "positioned", reviewedSHA: "head", wantInline: 1},
+		{name: "head moved", reviewedSHA: "older"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var discussions []map[string]any
+			cfg := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.URL.Path == "/api/v4/projects/42/merge_requests/7/changes":
+					json.NewEncoder(w).Encode(testChanges)
+				case r.Method == http.MethodPost && r.URL.Path == "/api/v4/projects/42/merge_requests/7/discussions":
+					var payload map[string]any
+					if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+						t.Errorf("decoding discussion: %v", err)
+					}
+					discussions = append(discussions, payload)
+					w.WriteHeader(http.StatusCreated)
+				default:
+					http.NotFound(w, r)
+				}
+			})
+			if err := PostMergeRequestFindings(context.Background(), cfg, "42", 7, tt.reviewedSHA, "Summary.", findings); err != nil {
+				t.Fatalf("PostMergeRequestFindings: %v", err)
+			}
+			if len(discussions) != tt.wantInline+1 {
+				t.Fatalf("created %d discussions, want %d", len(discussions), tt.wantInline+1)
+			}
+			for _, d := range discussions[:tt.wantInline] {
+				if d["position"] == nil || !strings.Contains(d["body"].(string), "Unused import") {
+					t.Errorf("inline discussion = %v", d)
+				}
+			}
+			// Podsumowanie zbiera znaleziska, których nie dało się umieścić w diffie
+			summary := discussions[tt.wantInline]
+			body := summary["body"].(string)
+			if summary["position"] != nil || !strings.Contains(body, "Summary.") || !strings.Contains(body, "Outside the diff") ||
+				strings.Contains(body, "Unused import") != (tt.wantInline == 0) {
+				t.Errorf("summary discussion = %v", summary)
+			}
+		})
+	}
+}
+
+func TestGetCompareChanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    string
+		wantErr bool
+	}{
+		{name: "fast forward", base: "aaa"},
+		{name: "force push", base: "ccc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compared := false
+			cfg := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/api/v4/projects/42/repository/merge_base":
+					if refs := r.URL.Query()["refs[]"]; len(refs) != 2 || refs[0] != "aaa" || refs[1] != "bbb" {
+						t.Errorf("merge base of %v", refs)
+					}
+					fmt.Fprintf(w, `{"id":%q}`, tt.base)
+				case "/api/v4/projects/42/repository/compare":
+					compared = true
+					json.NewEncoder(w).Encode(map[string]any{"diffs": []Change{{OldPath: "main.go", NewPath: "main.go", Diff: testDiff}}})
+				default:
+					http.NotFound(w, r)
+				}
+			})
+			changes, _, err := GetCompareChanges(context.Background(), cfg, "42", "aaa", "bbb")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetCompareChanges error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if compared {
+					t.Error("GetCompareChanges compared commits that are not a fast forward")
+				}
+				return
+			}
+			if !strings.HasPrefix(changes, "--- main.go\n+++ main.go\n@@ -1,3 +1,5 @@") {
+				t.Errorf("GetCompareChanges() = %q", changes)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/michalopenmakers/lazyreview/config"
+	"github.com/michalopenmakers/lazyreview/httpclient"
+	"github.com/michalopenmakers/lazyreview/logger"
+)
+
+type User struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+}
+
+var users httpclient.UserCache[User]
+
+// GetCurrentUser returns the owner of the configured token. The result is
+// cached per API URL and token, so it is requested only once.
+func GetCurrentUser(ctx context.Context, cfg *config.Config) (*User, error) {
+	apiUrl := cfg.GitLabConfig.GetFullApiUrl()
+	return users.Get(apiUrl, cfg.GitLabConfig.ApiToken, func() (*User, error) {
+		return fetchCurrentUser(ctx, cfg, apiUrl)
+	})
+}
+
+func fetchCurrentUser(ctx context.Context, cfg *config.Config, apiUrl string) (*User, error) {
+	logger.Log("Resolving GitLab user for the configured token")
+	url := fmt.Sprintf("%s/user", apiUrl)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error creating request for GitLab user: %v", err))
+		return nil, err
+	}
+	req.Header.Set("PRIVATE-TOKEN", cfg.GitLabConfig.ApiToken)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error connecting to GitLab API (%s): %v", apiUrl, err))
+		return nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			logger.Log(fmt.Sprintf("Error closing response body: %v", err))
+		}
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		errMsg := fmt.Sprintf("GitLab API (user) responded with status code %d: %s", resp.StatusCode, string(body))
+		logger.Log(errMsg)
+		return nil, fmt.Errorf(errMsg)
+	}
+
+	user := &User{}
+	if err := json.NewDecoder(resp.Body).Decode(user); err != nil {
+		logger.Log(fmt.Sprintf("Error decoding GitLab user response: %v", err))
+		return nil, err
+	}
+	if user.Username == "" {
+		return nil, fmt.Errorf("GitLab returned no username for the configured token")
+	}
+
+	logger.Log(fmt.Sprintf("GitLab token belongs to %s", user.Username))
+	return user, nil
+}
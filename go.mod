@@ -7,6 +7,7 @@ toolchain go1.24.2
 require (
 	fyne.io/fyne/v2 v2.5.5
 	github.com/gen2brain/beeep v0.0.0-20240516210008-9c006672e7f4
+	go.etcd.io/bbolt v1.4.3
 )
 
 require (
@@ -34,7 +35,7 @@ require (
 	github.com/rymdport/portal v0.3.0 // indirect
 	github.com/srwiley/oksvg v0.0.0-20221011165216-be6e8873101c // indirect
 	github.com/srwiley/rasterx v0.0.0-20220730225603-2ab79fcdd4ef // indirect
-	github.com/stretchr/testify v1.8.4 // indirect
+	github.com/stretchr/testify v1.10.0 // indirect
 	github.com/tadvi/systray v0.0.0-20190226123456-11a2b8fa57af // indirect
 	github.com/yuin/goldmark v1.7.1 // indirect
 	golang.org/x/image v0.18.0 // indirect
@@ -0,0 +1,14 @@
+//go:build !nogui
+
+package main
+
+import (
+	"github.com/michalopenmakers/lazyreview/business"
+	"github.com/michalopenmakers/lazyreview/config"
+	"github.com/michalopenmakers/lazyreview/ui"
+)
+
+func startGUI(cfg *config.Config) {
+	business.InitializeApplication(cfg)
+	ui.StartUI()
+}
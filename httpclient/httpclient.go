@@ -0,0 +1,286 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/michalopenmakers/lazyreview/logger"
+)
+
+const (
+	DefaultMaxRetries = 4
+	DefaultBaseDelay  = time.Second
+	DefaultMaxDelay   = 30 * time.Second
+	// DefaultMaxWait is the longest wait for a rate limit reset; responses
+	// asking for a longer pause are returned to the caller instead.
+	DefaultMaxWait = 2 * time.Minute
+)
+
+// Client wraps http.Client with retries for network errors, 5xx responses
+// and rate limiting. Rate limit headers of every response are recorded under
+// the client name, so pollers can slow down before the limit is hit.
+//
+// Only idempotent requests are repeated after a server error or a lost
+// response, since the server may already have handled them. POST and PATCH
+// requests are retried when they were rate limited or the connection failed
+// before anything was sent.
+type Client struct {
+	Name       string
+	HTTP       *http.Client
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+	MaxWait    time.Duration
+	// SafePost marks POST requests of this API as free of side effects, e.g.
+	// AI completions, so they are retried like GET requests.
+	SafePost bool
+}
+
+// New creates a client with the default retry policy.
+func New(name string, timeout time.Duration) *Client {
+	return &Client{
+		Name:       name,
+		HTTP:       &http.Client{Timeout: timeout},
+		MaxRetries: DefaultMaxRetries,
+		BaseDelay:  DefaultBaseDelay,
+		MaxDelay:   DefaultMaxDelay,
+		MaxWait:    DefaultMaxWait,
+	}
+}
+
+// NewSafePost creates a client for an API whose POST requests have no side
+// effects, so they may be repeated after server errors.
+func NewSafePost(name string, timeout time.Duration) *Client {
+	c := New(name, timeout)
+	c.SafePost = true
+	return c
+}
+
+// Do sends the request, retrying it when the failure is transient. Requests
+// with a body are retried only when the body can be rewound, which is the
+// case for requests built from bytes.Buffer, bytes.Reader or strings.Reader.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	repeatable := idempotent(req.Method) || (c.SafePost && req.Method == http.MethodPost)
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.Body != nil {
+			if req.GetBody == nil {
+				return nil, fmt.Errorf("cannot retry %s %s: request body is not replayable", req.Method, req.URL.Redacted())
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		// Po wysłaniu nagłówków serwer mógł już obsłużyć żądanie
+		var sent atomic.Bool
+		traced := req.WithContext(httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+			WroteHeaders: func() { sent.Store(true) },
+		}))
+		resp, err := c.HTTP.Do(traced)
+		if err != nil {
+			if ctx.Err() != nil || attempt >= c.MaxRetries || (!repeatable && sent.Load()) {
+				return nil, err
+			}
+			delay := c.backoff(attempt)
+			logger.Log(fmt.Sprintf("%s request failed (%v), retrying in %s", c.Name, err, delay))
+			if err := sleep(ctx, delay); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		recordLimits(c.Name, resp.Header)
+		if !retryable(resp, repeatable) || attempt >= c.MaxRetries {
+			return resp, nil
+		}
+		delay, ok := c.retryDelay(resp, attempt)
+		if !ok {
+			return resp, nil
+		}
+		logger.Log(fmt.Sprintf("%s responded with status code %d, retrying in %s", c.Name, resp.StatusCode, delay))
+		drain(resp)
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// idempotent reports whether repeating a request with the method has the
+// same effect as sending it once.
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryable reports whether the response is worth repeating: server errors
+// of repeatable requests, and for every request 429 and GitHub's 403 for an
+// exhausted rate limit, which reject the request without handling it.
+func retryable(resp *http.Response, repeatable bool) bool {
+	switch {
+	case resp.StatusCode >= 500:
+		return repeatable
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return true
+	case resp.StatusCode == http.StatusForbidden:
+		return resp.Header.Get("Retry-After") != "" || resp.Header.Get("X-RateLimit-Remaining") == "0"
+	}
+	return false
+}
+
+// retryDelay picks the wait before the next attempt. Server hints win over
+// the exponential backoff; a hint longer than MaxWait aborts the retries.
+func (c *Client) retryDelay(resp *http.Response, attempt int) (time.Duration, bool) {
+	delay, hinted := serverDelay(resp.StatusCode, resp.Header, time.Now())
+	if !hinted {
+		return c.backoff(attempt), true
+	}
+	if delay > c.MaxWait {
+		logger.Log(fmt.Sprintf("%s asked to wait %s, not retrying", c.Name, delay.Round(time.Second)))
+		return 0, false
+	}
+	return delay, true
+}
+
+// backoff returns an exponential delay with random jitter.
+func (c *Client) backoff(attempt int) time.Duration {
+	ceiling := c.BaseDelay << attempt
+	if ceiling <= 0 || ceiling > c.MaxDelay {
+		ceiling = c.MaxDelay
+	}
+	return ceiling/2 + time.Duration(rand.Int63n(int64(ceiling/2)+1))
+}
+
+// serverDelay reads the wait requested by the server: Retry-After, the
+// reset time of an exhausted GitHub or GitLab limit, or OpenAI's reset
+// durations.
+func serverDelay(status int, h http.Header, now time.Time) (time.Duration, bool) {
+	if value := h.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+		if at, err := http.ParseTime(value); err == nil {
+			return nonNegative(at.Sub(now)), true
+		}
+	}
+	for _, prefix := range []string{"X-RateLimit-", "RateLimit-"} {
+		if h.Get(prefix+"Remaining") != "0" {
+			continue
+		}
+		if reset, err := strconv.ParseInt(h.Get(prefix+"Reset"), 10, 64); err == nil {
+			return nonNegative(time.Unix(reset, 0).Sub(now)), true
+		}
+	}
+	if status != http.StatusTooManyRequests {
+		return 0, false
+	}
+	// OpenAI podaje czas do odnowienia limitu jako np. "1s" lub "6m0s"
+	for _, name := range []string{"X-Ratelimit-Reset-Requests", "X-Ratelimit-Reset-Tokens"} {
+		if d, err := time.ParseDuration(h.Get(name)); err == nil && d > 0 {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func drain(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if err := resp.Body.Close(); err != nil {
+		logger.Log(fmt.Sprintf("Error closing response body: %v", err))
+	}
+}
+
+// RateLimit is the last known rate limit state of an API.
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+var (
+	limitsMutex sync.Mutex
+	limits      = make(map[string]RateLimit)
+)
+
+func recordLimits(name string, h http.Header) {
+	for _, prefix := range []string{"X-RateLimit-", "RateLimit-"} {
+		remaining, err := strconv.Atoi(h.Get(prefix + "Remaining"))
+		if err != nil {
+			continue
+		}
+		limit, _ := strconv.Atoi(h.Get(prefix + "Limit"))
+		rl := RateLimit{Limit: limit, Remaining: remaining}
+		if reset, err := strconv.ParseInt(h.Get(prefix+"Reset"), 10, 64); err == nil {
+			rl.Reset = time.Unix(reset, 0)
+		}
+		limitsMutex.Lock()
+		limits[name] = rl
+		limitsMutex.Unlock()
+		return
+	}
+}
+
+// Limits returns the last rate limit reported to the named client.
+func Limits(name string) (RateLimit, bool) {
+	limitsMutex.Lock()
+	defer limitsMutex.Unlock()
+	rl, ok := limits[name]
+	return rl, ok
+}
+
+// PollInterval stretches the base polling interval as the remaining quota
+// of the named API drops. Below 10% of the limit the next poll waits for
+// the reset.
+func PollInterval(name string, base time.Duration) time.Duration {
+	rl, ok := Limits(name)
+	if !ok || rl.Limit <= 0 {
+		return base
+	}
+	ratio := float64(rl.Remaining) / float64(rl.Limit)
+	interval := base
+	switch {
+	case ratio < 0.1:
+		if untilReset := time.Until(rl.Reset); untilReset > interval {
+			interval = untilReset
+		} else {
+			interval = base * 4
+		}
+	case ratio < 0.25:
+		interval = base * 2
+	}
+	if interval != base {
+		logger.Log(fmt.Sprintf("%s rate limit at %d/%d, next poll in %s", name, rl.Remaining, rl.Limit, interval.Round(time.Second)))
+	}
+	return interval
+}
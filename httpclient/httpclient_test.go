@@ -0,0 +1,172 @@
+package httpclient
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestClient() *Client {
+	c := New("test", 5*time.Second)
+	c.BaseDelay = time.Millisecond
+	c.MaxDelay = 5 * time.Millisecond
+	c.MaxRetries = 3
+	return c
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestDoRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		safePost bool
+		// statuses are answered in turn, the last one repeatedly
+		statuses     []int
+		header       http.Header
+		wantStatus   int
+		wantAttempts int32
+	}{
+		{name: "GET after server errors", method: http.MethodGet, statuses: []int{502, 503, 200}, wantStatus: 200, wantAttempts: 3},
+		{name: "GET gives up", method: http.MethodGet, statuses: []int{500}, wantStatus: 500, wantAttempts: 4},
+		{name: "PUT after server error", method: http.MethodPut, statuses: []int{500, 204}, wantStatus: 204, wantAttempts: 2},
+		{name: "POST not repeated after server error", method: http.MethodPost, statuses: []int{500, 200}, wantStatus: 500, wantAttempts: 1},
+		{name: "PATCH not repeated after server error", method: http.MethodPatch, statuses: []int{503, 200}, wantStatus: 503, wantAttempts: 1},
+		{name: "safe POST after server error", method: http.MethodPost, safePost: true, statuses: []int{500, 200}, wantStatus: 200, wantAttempts: 2},
+		{name: "POST after rate limit", method: http.MethodPost, statuses: []int{429, 201}, wantStatus: 201, wantAttempts: 2},
+		{name: "GitHub rate limit", method: http.MethodGet, statuses: []int{403, 200}, header: http.Header{"Retry-After": {"0"}}, wantStatus: 200, wantAttempts: 2},
+		{name: "plain forbidden", method: http.MethodGet, statuses: []int{403, 200}, wantStatus: 403, wantAttempts: 1},
+		{name: "client error", method: http.MethodGet, statuses: []int{404, 200}, wantStatus: 404, wantAttempts: 1},
+		{name: "wait longer than MaxWait", method: http.MethodGet, statuses: []int{429, 200}, header: http.Header{"Retry-After": {"3600"}}, wantStatus: 429, wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(attempts.Add(1)) - 1
+				if body, _ := io.ReadAll(r.Body); r.Method != http.MethodGet && string(body) != "payload" {
+					t.Errorf("attempt %d: body = %q", n+1, body)
+				}
+				status := tt.statuses[min(n, len(tt.statuses)-1)]
+				for name, values := range tt.header {
+					w.Header()[name] = values
+				}
+				w.WriteHeader(status)
+			}))
+			defer srv.Close()
+
+			c := newTestClient()
+			c.SafePost = tt.safePost
+			var body io.Reader
+			if tt.method != http.MethodGet {
+				body = strings.NewReader("payload")
+			}
+			req, _ := http.NewRequest(tt.method, srv.URL, body)
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Fatalf("Do: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus || attempts.Load() != tt.wantAttempts {
+				t.Errorf("got %d after %d attempts, want %d after %d", resp.StatusCode, attempts.Load(), tt.wantStatus, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestDoNetworkErrors(t *testing.T) {
+	errReset := errors.New("connection reset by peer")
+	tests := []struct {
+		name   string
+		method string
+		// sent tells whether the request reached the server before failing
+		sent         bool
+		wantAttempts int32
+	}{
+		{name: "GET lost response", method: http.MethodGet, sent: true, wantAttempts: 4},
+		{name: "POST lost response", method: http.MethodPost, sent: true, wantAttempts: 1},
+		{name: "POST not sent", method: http.MethodPost, sent: false, wantAttempts: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			c := newTestClient()
+			c.HTTP.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				attempts.Add(1)
+				if tt.sent {
+					if trace := httptrace.ContextClientTrace(req.Context()); trace != nil && trace.WroteHeaders != nil {
+						trace.WroteHeaders()
+					}
+				}
+				return nil, errReset
+			})
+			req, _ := http.NewRequest(tt.method, "http://example.invalid/", strings.NewReader("payload"))
+			if _, err := c.Do(req); !errors.Is(err, errReset) {
+				t.Errorf("Do error = %v, want %v", err, errReset)
+			}
+			if attempts.Load() != tt.wantAttempts {
+				t.Errorf("%d attempts, want %d", attempts.Load(), tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestServerDelay(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name       string
+		status     int
+		header     http.Header
+		wantDelay  time.Duration
+		wantHinted bool
+	}{
+		{"Retry-After seconds", 429, http.Header{"Retry-After": {"7"}}, 7 * time.Second, true},
+		{"Retry-After date", 503, http.Header{"Retry-After": {now.Add(time.Minute).UTC().Format(http.TimeFormat)}}, time.Minute, true},
+		{"GitHub reset", 403, http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"1700000030"}}, 30 * time.Second, true},
+		{"GitLab reset", 429, http.Header{"Ratelimit-Remaining": {"0"}, "Ratelimit-Reset": {"1700000010"}}, 10 * time.Second, true},
+		{"reset in the past", 403, http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"1690000000"}}, 0, true},
+		{"quota left", 403, http.Header{"X-Ratelimit-Remaining": {"10"}, "X-Ratelimit-Reset": {"1700000030"}}, 0, false},
+		{"OpenAI reset", 429, http.Header{"X-Ratelimit-Reset-Requests": {"1.5s"}}, 1500 * time.Millisecond, true},
+		{"OpenAI header on other status", 500, http.Header{"X-Ratelimit-Reset-Requests": {"1.5s"}}, 0, false},
+		{"no hint", 502, http.Header{}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delay, hinted := serverDelay(tt.status, tt.header, now)
+			if delay != tt.wantDelay || hinted != tt.wantHinted {
+				t.Errorf("serverDelay() = %s, %v; want %s, %v", delay, hinted, tt.wantDelay, tt.wantHinted)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	c := &Client{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{0, 50 * time.Millisecond, 100 * time.Millisecond},
+		{1, 100 * time.Millisecond, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond, 800 * time.Millisecond},
+		{4, 500 * time.Millisecond, time.Second},
+		{70, 500 * time.Millisecond, time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			if d := c.backoff(tt.attempt); d < tt.min || d > tt.max {
+				t.Errorf("backoff(%d) = %s, want between %s and %s", tt.attempt, d, tt.min, tt.max)
+			}
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+
+	"github.com/michalopenmakers/lazyreview/logger"
+)
+
+const (
+	// DefaultMaxPages is used when no page cap is configured.
+	DefaultMaxPages = 10
+	// PageSize is requested from list endpoints; both forges allow 100.
+	PageSize = 100
+)
+
+// ErrPageLimit is returned when a list has more pages than the cap allows, so
+// the items read are incomplete.
+var ErrPageLimit = errors.New("page limit reached")
+
+// NextPageFunc returns the URL of the page following resp, or "" when resp
+// is the last page.
+type NextPageFunc func(resp *http.Response) string
+
+// GitHubNextPage follows the rel="next" entry of the Link header.
+func GitHubNextPage(resp *http.Response) string {
+	for _, link := range strings.Split(resp.Header.Get("Link"), ",") {
+		parts := strings.Split(link, ";")
+		if len(parts) < 2 {
+			continue
+		}
+		for _, param := range parts[1:] {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return strings.Trim(strings.TrimSpace(parts[0]), "<>")
+			}
+		}
+	}
+	return ""
+}
+
+// GitLabNextPage uses the X-Next-Page header, which is empty on the last
+// page.
+func GitLabNextPage(resp *http.Response) string {
+	page := resp.Header.Get("X-Next-Page")
+	if _, err := strconv.Atoi(page); err != nil {
+		return ""
+	}
+	next := *resp.Request.URL
+	query := next.Query()
+	query.Set("page", page)
+	next.RawQuery = query.Encode()
+	return next.String()
+}
+
+// WithPageSize adds per_page to the URL unless it is already set.
+func WithPageSize(rawURL string) string {
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	query := u.Query()
+	if query.Get("per_page") != "" {
+		return rawURL
+	}
+	query.Set("per_page", strconv.Itoa(PageSize))
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// Paginate sends req and then requests the following pages until nextPage
+// returns "". handle is called with every response and the body is closed
+// afterwards; an error from handle stops the pagination. When more than
+// maxPages pages are available, Paginate stops with ErrPageLimit.
+func (c *Client) Paginate(req *http.Request, maxPages int, nextPage NextPageFunc, handle func(resp *http.Response) error) error {
+	if maxPages <= 0 {
+		maxPages = DefaultMaxPages
+	}
+	first := req.URL.Redacted()
+	for page := 1; ; page++ {
+		resp, err := c.Do(req)
+		if err != nil {
+			return err
+		}
+		err = handle(resp)
+		next := ""
+		if err == nil && resp.StatusCode == http.StatusOK {
+			next = nextPage(resp)
+		}
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			logger.Log(fmt.Sprintf("Error closing response body: %v", closeErr))
+		}
+		if err != nil || next == "" {
+			return err
+		}
+		if page >= maxPages {
+			return fmt.Errorf("%w: %s has more than %d pages, raise MaxPages to read them all", ErrPageLimit, first, maxPages)
+		}
+
+		nextReq, err := http.NewRequestWithContext(req.Context(), req.Method, next, nil)
+		if err != nil {
+			return err
+		}
+		nextReq.Header = req.Header.Clone()
+		req = nextReq
+	}
+}
+
+// GetPages reads every page of a list endpoint with Paginate and decodes the
+// JSON array of each page. what names the endpoint in errors.
+func GetPages[T any](c *Client, req *http.Request, maxPages int, nextPage NextPageFunc, what string) ([]T, error) {
+	var items []T
+	err := c.Paginate(req, maxPages, nextPage, func(resp *http.Response) error {
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			return fmt.Errorf("%s API (%s) responded with status code %d: %s", c.Name, what, resp.StatusCode, string(body))
+		}
+		var page []T
+		if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+			return fmt.Errorf("error decoding %s response: %w", what, err)
+		}
+		items = append(items, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
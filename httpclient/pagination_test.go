@@ -0,0 +1,103 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// pagedServer serves a JSON array of one number per page, linking the pages
+// the way GitHub or GitLab does.
+func pagedServer(t *testing.T, pages int, gitlab bool) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+		if r.URL.Query().Get("per_page") != strconv.Itoa(PageSize) {
+			t.Errorf("page %d requested without per_page: %s", page, r.URL)
+		}
+		if page < pages {
+			if gitlab {
+				w.Header().Set("X-Next-Page", strconv.Itoa(page+1))
+			} else {
+				next := fmt.Sprintf("http://%s%s?per_page=%d&page=%d", r.Host, r.URL.Path, PageSize, page+1)
+				w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next", <%s>; rel="last"`, next, next))
+			}
+		} else if gitlab {
+			w.Header().Set("X-Next-Page", "")
+		}
+		fmt.Fprintf(w, "[%d]", page)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestGetPages(t *testing.T) {
+	tests := []struct {
+		name     string
+		gitlab   bool
+		pages    int
+		maxPages int
+		want     int
+		wantErr  error
+	}{
+		{name: "GitHub single page", pages: 1, maxPages: 10, want: 1},
+		{name: "GitHub all pages", pages: 3, maxPages: 10, want: 3},
+		{name: "GitLab all pages", gitlab: true, pages: 3, maxPages: 10, want: 3},
+		{name: "exactly the limit", pages: 3, maxPages: 3, want: 3},
+		{name: "GitHub over the limit", pages: 5, maxPages: 3, wantErr: ErrPageLimit},
+		{name: "GitLab over the limit", gitlab: true, pages: 5, maxPages: 3, wantErr: ErrPageLimit},
+		{name: "default limit", pages: DefaultMaxPages + 1, wantErr: ErrPageLimit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := pagedServer(t, tt.pages, tt.gitlab)
+			nextPage := GitHubNextPage
+			if tt.gitlab {
+				nextPage = GitLabNextPage
+			}
+			req, _ := http.NewRequest(http.MethodGet, WithPageSize(srv.URL+"/items"), nil)
+			items, err := GetPages[int](newTestClient(), req, tt.maxPages, nextPage, "items")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetPages error = %v, want %v", err, tt.wantErr)
+			}
+			if len(items) != tt.want {
+				t.Errorf("GetPages() = %v, want %d items", items, tt.want)
+			}
+			for i, item := range items {
+				if item != i+1 {
+					t.Errorf("item %d = %d, want pages in order", i, item)
+				}
+			}
+		})
+	}
+}
+
+func TestGetPagesStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	defer srv.Close()
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	if items, err := GetPages[int](newTestClient(), req, 10, GitHubNextPage, "items"); err == nil || items != nil {
+		t.Errorf("GetPages() = %v, %v; want an error", items, err)
+	}
+}
+
+func TestWithPageSize(t *testing.T) {
+	tests := []struct{ url, want string }{
+		{"https://api.github.com/repos/o/r/pulls", "https://api.github.com/repos/o/r/pulls?per_page=100"},
+		{"https://gitlab.com/api/v4/merge_requests?state=opened", "https://gitlab.com/api/v4/merge_requests?per_page=100&state=opened"},
+		{"https://gitlab.com/api/v4/merge_requests?per_page=20", "https://gitlab.com/api/v4/merge_requests?per_page=20"},
+	}
+	for _, tt := range tests {
+		if got := WithPageSize(tt.url); got != tt.want {
+			t.Errorf("WithPageSize(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package httpclient
+
+import "sync"
+
+// UserCache keeps the user owning an API token, so GitLab and GitHub resolve
+// it only once per API URL and token. The zero value is ready to use.
+type UserCache[T any] struct {
+	mu    sync.Mutex
+	users map[string]*T
+}
+
+// Get returns the cached user of token at apiURL, calling fetch when there is
+// none yet. Errors are not cached, so the next call tries again.
+func (c *UserCache[T]) Get(apiURL, token string, fetch func() (*T, error)) (*T, error) {
+	key := apiURL + "|" + token
+
+	// Blokada obejmuje tylko mapę; równoległe pierwsze zapytania co najwyżej się powtórzą
+	c.mu.Lock()
+	user, ok := c.users[key]
+	c.mu.Unlock()
+	if ok {
+		return user, nil
+	}
+
+	user, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if c.users == nil {
+		c.users = make(map[string]*T)
+	}
+	c.users[key] = user
+	c.mu.Unlock()
+	return user, nil
+}
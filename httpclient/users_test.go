@@ -0,0 +1,40 @@
+package httpclient
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUserCache(t *testing.T) {
+	var cache UserCache[string]
+	calls := 0
+	fetch := func(name string, err error) func() (*string, error) {
+		return func() (*string, error) {
+			calls++
+			if err != nil {
+				return nil, err
+			}
+			return &name, nil
+		}
+	}
+
+	if _, err := cache.Get("https://gitlab.com/api/v4", "t1", fetch("", errors.New("unauthorized"))); err == nil {
+		t.Fatal("Get() returned no error from fetch")
+	}
+	// Błąd nie jest zapamiętywany
+	if user, err := cache.Get("https://gitlab.com/api/v4", "t1", fetch("alice", nil)); err != nil || *user != "alice" {
+		t.Fatalf("Get() = %v, %v", user, err)
+	}
+	if user, _ := cache.Get("https://gitlab.com/api/v4", "t1", fetch("bob", nil)); *user != "alice" {
+		t.Errorf("Get() = %q, want the cached user", *user)
+	}
+	if user, _ := cache.Get("https://gitlab.com/api/v4", "t2", fetch("bob", nil)); *user != "bob" {
+		t.Errorf("Get() for another token = %q", *user)
+	}
+	if user, _ := cache.Get("https://gitlab.example.com/api/v4", "t1", fetch("carol", nil)); *user != "carol" {
+		t.Errorf("Get() for another API URL = %q", *user)
+	}
+	if calls != 4 {
+		t.Errorf("fetch called %d times, want 4", calls)
+	}
+}
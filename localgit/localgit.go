@@ -0,0 +1,186 @@
+package localgit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/michalopenmakers/lazyreview/config"
+	"github.com/michalopenmakers/lazyreview/filter"
+	"github.com/michalopenmakers/lazyreview/logger"
+)
+
+// StagedRef names the index in GetFileContent: files are read as staged.
+const StagedRef = ""
+
+// git runs a git command in dir and returns its standard output.
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir, "-c", "core.quotePath=false"}, args...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
+
+// TopLevel returns the root of the working copy containing dir.
+func TopLevel(ctx context.Context, dir string) (string, error) {
+	out, err := git(ctx, dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Clean(strings.TrimSpace(out)), nil
+}
+
+// HeadCommit returns the SHA of HEAD.
+func HeadCommit(ctx context.Context, dir string) (string, error) {
+	out, err := git(ctx, dir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// CurrentBranch returns the checked out branch, or "HEAD" when detached.
+func CurrentBranch(ctx context.Context, dir string) (string, error) {
+	out, err := git(ctx, dir, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// DefaultBase guesses the branch a local branch will be merged into: the
+// default branch of origin, or else main or master.
+func DefaultBase(ctx context.Context, dir string) (string, error) {
+	if out, err := git(ctx, dir, "symbolic-ref", "--quiet", "--short", "refs/remotes/origin/HEAD"); err == nil {
+		return strings.TrimSpace(out), nil
+	}
+	for _, candidate := range []string{"origin/main", "origin/master", "main", "master"} {
+		if _, err := git(ctx, dir, "rev-parse", "--verify", "--quiet", candidate+"^{commit}"); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", errors.New("cannot find the base branch, pass it explicitly")
+}
+
+// GetBranchChanges returns the diff of the commits on HEAD since it forked
+// from base (git diff base...HEAD), filtered like the forge diffs.
+func GetBranchChanges(ctx context.Context, cfg *config.Config, dir, base string) (string, []filter.Skipped, error) {
+	out, err := git(ctx, dir, "diff", "--no-color", "--no-ext-diff", "--src-prefix=a/", "--dst-prefix=b/", base+"...HEAD")
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error getting local changes since %s: %v", base, err))
+		return "", nil, err
+	}
+	changes, skipped := filterPatches(loadFilter(ctx, cfg, dir, "HEAD"), splitPatches(out))
+	logger.Log(fmt.Sprintf("Successfully fetched local changes since %s, total size: %d bytes", base, len(changes)))
+	return changes, skipped, nil
+}
+
+// GetStagedChanges returns the diff of the staged changes against HEAD,
+// filtered like the forge diffs.
+func GetStagedChanges(ctx context.Context, cfg *config.Config, dir string) (string, []filter.Skipped, error) {
+	out, err := git(ctx, dir, "diff", "--cached", "--no-color", "--no-ext-diff", "--src-prefix=a/", "--dst-prefix=b/")
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error getting staged changes: %v", err))
+		return "", nil, err
+	}
+	changes, skipped := filterPatches(loadFilter(ctx, cfg, dir, StagedRef), splitPatches(out))
+	logger.Log(fmt.Sprintf("Successfully fetched staged changes, total size: %d bytes", len(changes)))
+	return changes, skipped, nil
+}
+
+// GetFileContent returns a file at the given ref, or its staged version for
+// StagedRef. A missing file yields an error wrapping os.ErrNotExist.
+func GetFileContent(ctx context.Context, dir, filePath, ref string) (string, error) {
+	out, err := git(ctx, dir, "cat-file", "blob", ref+":"+filePath)
+	if err != nil {
+		if _, verr := git(ctx, dir, "cat-file", "-e", ref+":"+filePath); verr != nil {
+			return "", fmt.Errorf("%s not found at %q: %w", filePath, ref, os.ErrNotExist)
+		}
+		return "", err
+	}
+	return out, nil
+}
+
+func loadFilter(ctx context.Context, cfg *config.Config, dir, ref string) *filter.Filter {
+	f := filter.New(cfg.DiffFilter)
+	if !f.DetectsGenerated() {
+		return f
+	}
+	attributes, err := GetFileContent(ctx, dir, ".gitattributes", ref)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Log(fmt.Sprintf("Error reading .gitattributes: %v", err))
+		}
+		return f
+	}
+	return f.WithAttributes(attributes)
+}
+
+// patch is the diff of one file in the ---/+++ form used by the forge diffs.
+type patch struct {
+	path   string
+	header string
+	hunks  string
+}
+
+// splitPatches cuts git diff output into files. Extended headers (index,
+// mode and rename lines) are dropped, as are binary files without hunks.
+func splitPatches(out string) []patch {
+	var patches []patch
+	for _, section := range strings.Split(out, "\ndiff --git ") {
+		section = strings.TrimPrefix(section, "diff --git ")
+		hunkStart := strings.Index(section, "\n@@")
+		if hunkStart < 0 {
+			continue
+		}
+		var oldPath, newPath string
+		for _, line := range strings.Split(section[:hunkStart], "\n") {
+			if strings.HasPrefix(line, "--- ") {
+				oldPath = strings.TrimPrefix(line, "--- ")
+			} else if strings.HasPrefix(line, "+++ ") {
+				newPath = strings.TrimPrefix(line, "+++ ")
+			}
+		}
+		if oldPath == "" || newPath == "" {
+			continue
+		}
+		path := strings.TrimPrefix(newPath, "b/")
+		if newPath == "/dev/null" {
+			path = strings.TrimPrefix(oldPath, "a/")
+		}
+		patches = append(patches, patch{
+			path:   path,
+			header: "--- " + oldPath + "\n+++ " + newPath + "\n",
+			hunks:  strings.TrimSuffix(section[hunkStart+1:], "\n"),
+		})
+	}
+	return patches
+}
+
+func filterPatches(f *filter.Filter, patches []patch) (string, []filter.Skipped) {
+	var sb strings.Builder
+	var skipped []filter.Skipped
+	for _, p := range patches {
+		if reason, skip := f.Check(p.path, p.hunks); skip {
+			skipped = append(skipped, filter.Skipped{Path: p.path, Reason: reason})
+			continue
+		}
+		sb.WriteString(p.header + p.hunks + "\n\n")
+	}
+	if len(skipped) > 0 {
+		logger.Log(fmt.Sprintf("Skipped %d of %d changed files", len(skipped), len(patches)))
+	}
+	return sb.String(), skipped
+}
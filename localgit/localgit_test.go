@@ -0,0 +1,235 @@
+package localgit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/michalopenmakers/lazyreview/config"
+	"github.com/michalopenmakers/lazyreview/filter"
+)
+
+// testRepo is a working copy with a main branch and a feature branch
+// checked out. The feature branch forked from main before main got another
+// commit.
+type testRepo struct {
+	t   *testing.T
+	dir string
+}
+
+func newTestRepo(t *testing.T) *testRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	r := &testRepo{t: t, dir: t.TempDir()}
+	r.git("init", "--quiet", "--initial-branch=main")
+	r.write("main.go", "package main\n\nfunc main() {}\n")
+	r.write("README.md", "# Test\n")
+	r.commit("Initial commit")
+
+	r.git("checkout", "--quiet", "-b", "feature")
+	r.write("main.go", "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hello\")\n}\n")
+	r.write("go.sum", "example.com/x v1.0.0 h1:abc=\n")
+	r.write("gen/model.go", "// Code generated by tool. DO NOT EDIT.\n\npackage gen\n")
+	r.write("api/schema.json", "{}\n")
+	r.write(".gitattributes", "api/*.json linguist-generated\n")
+	r.commit("Add greeting")
+
+	r.git("checkout", "--quiet", "main")
+	r.write("README.md", "# Test\n\nChanged on main.\n")
+	r.commit("Update readme")
+	r.git("checkout", "--quiet", "feature")
+	return r
+}
+
+func (r *testRepo) git(args ...string) string {
+	r.t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", r.dir}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com",
+		"GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1",
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		r.t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return string(out)
+}
+
+func (r *testRepo) write(name, content string) {
+	r.t.Helper()
+	path := filepath.Join(r.dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		r.t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+func (r *testRepo) commit(message string) {
+	r.t.Helper()
+	r.git("add", "-A")
+	r.git("commit", "--quiet", "-m", message)
+}
+
+func skippedPaths(skipped []filter.Skipped) map[string]string {
+	paths := make(map[string]string)
+	for _, s := range skipped {
+		paths[s.Path] = s.Reason
+	}
+	return paths
+}
+
+func TestGetBranchChanges(t *testing.T) {
+	r := newTestRepo(t)
+	cfg := &config.Config{DiffFilter: config.DiffFilterConfig{SkipGenerated: true}}
+
+	changes, skipped, err := GetBranchChanges(context.Background(), cfg, r.dir, "main")
+	if err != nil {
+		t.Fatalf("GetBranchChanges: %v", err)
+	}
+	if !strings.Contains(changes, "--- a/main.go\n+++ b/main.go\n@@ ") || !strings.Contains(changes, "+\tfmt.Println(\"hello\")\n") {
+		t.Errorf("changes do not contain the diff of main.go:\n%s", changes)
+	}
+	// Zmiany gałęzi main po rozwidleniu nie należą do przeglądu
+	if strings.Contains(changes, "README.md") {
+		t.Errorf("changes contain a commit of the base branch:\n%s", changes)
+	}
+	if strings.Contains(changes, "index ") || strings.Contains(changes, "diff --git") {
+		t.Errorf("changes contain git extended headers:\n%s", changes)
+	}
+
+	want := map[string]string{
+		"go.sum":          "excluded by go.sum",
+		"gen/model.go":    "generated",
+		"api/schema.json": "linguist-generated",
+	}
+	got := skippedPaths(skipped)
+	for path, reason := range want {
+		if got[path] != reason {
+			t.Errorf("%s skipped as %q, want %q", path, got[path], reason)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("skipped = %v, want %v", got, want)
+	}
+}
+
+func TestGetBranchChangesUnknownBase(t *testing.T) {
+	r := newTestRepo(t)
+	if _, _, err := GetBranchChanges(context.Background(), &config.Config{}, r.dir, "develop"); err == nil {
+		t.Error("GetBranchChanges succeeded for a missing base branch")
+	}
+}
+
+func TestGetStagedChanges(t *testing.T) {
+	r := newTestRepo(t)
+	cfg := &config.Config{}
+
+	changes, skipped, err := GetStagedChanges(context.Background(), cfg, r.dir)
+	if err != nil {
+		t.Fatalf("GetStagedChanges: %v", err)
+	}
+	if changes != "" || len(skipped) != 0 {
+		t.Errorf("GetStagedChanges() = %q, %v; want no changes", changes, skipped)
+	}
+
+	r.write("main.go", "package main\n\nfunc main() {\n\tpanic(\"staged\")\n}\n")
+	r.git("add", "main.go")
+	r.write("README.md", "# Not staged\n")
+	r.write("new.go", "package main\n")
+	r.git("add", "new.go")
+
+	changes, _, err = GetStagedChanges(context.Background(), cfg, r.dir)
+	if err != nil {
+		t.Fatalf("GetStagedChanges: %v", err)
+	}
+	if !strings.Contains(changes, "+\tpanic(\"staged\")") {
+		t.Errorf("staged change of main.go missing:\n%s", changes)
+	}
+	if !strings.Contains(changes, "--- /dev/null\n+++ b/new.go\n") {
+		t.Errorf("staged new file missing:\n%s", changes)
+	}
+	if strings.Contains(changes, "Not staged") {
+		t.Errorf("changes contain an unstaged file:\n%s", changes)
+	}
+
+	content, err := GetFileContent(context.Background(), r.dir, "main.go", StagedRef)
+	if err != nil || !strings.Contains(content, "staged") {
+		t.Errorf("GetFileContent(staged) = %q, %v", content, err)
+	}
+}
+
+func TestGetFileContent(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+
+	content, err := GetFileContent(ctx, r.dir, "README.md", "main")
+	if err != nil || !strings.Contains(content, "Changed on main.") {
+		t.Errorf("GetFileContent(README.md, main) = %q, %v", content, err)
+	}
+	content, err = GetFileContent(ctx, r.dir, "README.md", "HEAD")
+	if err != nil || content != "# Test\n" {
+		t.Errorf("GetFileContent(README.md, HEAD) = %q, %v", content, err)
+	}
+	if _, err := GetFileContent(ctx, r.dir, "missing.go", "HEAD"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("GetFileContent(missing.go) error = %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestRepositoryInfo(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+
+	sub := filepath.Join(r.dir, "gen")
+	root, err := TopLevel(ctx, sub)
+	if err != nil {
+		t.Fatalf("TopLevel: %v", err)
+	}
+	if want, _ := filepath.EvalSymlinks(r.dir); root != want && root != r.dir {
+		t.Errorf("TopLevel() = %q, want %q", root, r.dir)
+	}
+	if branch, err := CurrentBranch(ctx, r.dir); err != nil || branch != "feature" {
+		t.Errorf("CurrentBranch() = %q, %v", branch, err)
+	}
+	if base, err := DefaultBase(ctx, r.dir); err != nil || base != "main" {
+		t.Errorf("DefaultBase() = %q, %v", base, err)
+	}
+	head := strings.TrimSpace(r.git("rev-parse", "HEAD"))
+	if commit, err := HeadCommit(ctx, r.dir); err != nil || commit != head {
+		t.Errorf("HeadCommit() = %q, %v; want %q", commit, err, head)
+	}
+	if _, err := TopLevel(ctx, t.TempDir()); err == nil {
+		t.Error("TopLevel succeeded outside a working copy")
+	}
+}
+
+func TestSplitPatches(t *testing.T) {
+	out := "diff --git a/old.go b/new.go\n" +
+		"similarity index 90%\nrename from old.go\nrename to new.go\nindex 111..222 100644\n" +
+		"--- a/old.go\n+++ b/new.go\n@@ -1 +1 @@\n-a\n+b\n" +
+		"diff --git a/logo.png b/logo.png\nindex 333..444 100644\nBinary files a/logo.png and b/logo.png differ\n" +
+		"diff --git a/gone.go b/gone.go\ndeleted file mode 100644\nindex 555..000\n" +
+		"--- a/gone.go\n+++ /dev/null\n@@ -1 +0,0 @@\n-package gone\n"
+
+	patches := splitPatches(out)
+	want := []patch{
+		{path: "new.go", header: "--- a/old.go\n+++ b/new.go\n", hunks: "@@ -1 +1 @@\n-a\n+b"},
+		{path: "gone.go", header: "--- a/gone.go\n+++ /dev/null\n", hunks: "@@ -1 +0,0 @@\n-package gone"},
+	}
+	if len(patches) != len(want) {
+		t.Fatalf("splitPatches() = %+v, want %+v", patches, want)
+	}
+	for i := range want {
+		if patches[i] != want[i] {
+			t.Errorf("patch %d = %+v, want %+v", i, patches[i], want[i])
+		}
+	}
+}
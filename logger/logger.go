@@ -3,6 +3,7 @@ package logger
 import (
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"sync"
@@ -52,6 +53,16 @@ func init() {
 	Logger = slog.New(memHandler)
 }
 
+// SetOutput redirects the printed logs, e.g. to stderr for commands writing
+// their results to stdout. The logs are still kept in memory.
+func SetOutput(w io.Writer) {
+	memHandler.mu.Lock()
+	defer memHandler.mu.Unlock()
+	memHandler.handler = slog.NewTextHandler(w, &slog.HandlerOptions{
+		AddSource: false,
+	})
+}
+
 func Log(msg string) {
 	Logger.Info(msg)
 }
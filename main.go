@@ -1,17 +1,31 @@
 package main
 
 import (
-	"github.com/michalopenmakers/lazyreview/business"
+	"fmt"
+	"os"
+
+	_ "github.com/michalopenmakers/lazyreview/anthropic"
+	"github.com/michalopenmakers/lazyreview/cli"
 	"github.com/michalopenmakers/lazyreview/config"
 	"github.com/michalopenmakers/lazyreview/logger"
+	_ "github.com/michalopenmakers/lazyreview/ollama"
+	_ "github.com/michalopenmakers/lazyreview/openai"
 	"github.com/michalopenmakers/lazyreview/state"
-	"github.com/michalopenmakers/lazyreview/ui"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		// Tryb wiersza poleceń: wyniki na stdout, logi na stderr
+		logger.SetOutput(os.Stderr)
+		os.Exit(cli.Run(config.LoadConfig(), os.Args[1:]))
+	}
+
 	logger.Log("Uruchamianie aplikacji LazyReview")
-	state.Init()
 	cfg := config.LoadConfig()
-	business.InitializeApplication(cfg)
-	ui.StartUI()
+	if err := state.Init(cfg.StorageBackend); err != nil {
+		fmt.Fprintf(os.Stderr, "lazyreview: %v\n", err)
+		os.Exit(1)
+	}
+	defer state.Close()
+	startGUI(cfg)
 }
@@ -0,0 +1,17 @@
+//go:build nogui
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/michalopenmakers/lazyreview/config"
+)
+
+// startGUI replaces the desktop application in builds without Fyne, meant
+// for servers: only the commands are available.
+func startGUI(cfg *config.Config) {
+	fmt.Fprintln(os.Stderr, "lazyreview was built without the GUI, run \"lazyreview help\" for the available commands")
+	os.Exit(2)
+}
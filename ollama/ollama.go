@@ -0,0 +1,134 @@
+package ollama
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/michalopenmakers/lazyreview/ai"
+	"github.com/michalopenmakers/lazyreview/config"
+	"github.com/michalopenmakers/lazyreview/httpclient"
+	"github.com/michalopenmakers/lazyreview/logger"
+)
+
+const DefaultBaseUrl = "http://localhost:11434"
+
+type ChatRequest struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+	Stream   bool      `json:"stream"`
+	Format   any       `json:"format,omitempty"`
+	Options  Options   `json:"options,omitempty"`
+}
+
+type Options struct {
+	NumPredict int `json:"num_predict,omitempty"`
+}
+
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ChatResponse struct {
+	Model   string  `json:"model"`
+	Message Message `json:"message"`
+	Done    bool    `json:"done"`
+	Error   string  `json:"error"`
+}
+
+// Provider reviews code using a self-hosted model served by Ollama.
+type Provider struct {
+	ApiUrl    string
+	ApiKey    string
+	Model     string
+	MaxTokens int
+	Client    *httpclient.Client
+}
+
+func init() {
+	ai.Register("ollama", NewProvider)
+}
+
+func NewProvider(cfg *config.Config) ai.ReviewProvider {
+	return &Provider{
+		ApiUrl:    cfg.AIModelConfig.GetApiUrl(DefaultBaseUrl, "/api/chat"),
+		ApiKey:    cfg.AIModelConfig.ApiKey,
+		Model:     cfg.AIModelConfig.Model,
+		MaxTokens: cfg.AIModelConfig.MaxTokens,
+		// Modele uruchamiane lokalnie potrafią odpowiadać znacznie wolniej
+		Client: httpclient.NewSafePost("ollama", 5*time.Minute),
+	}
+}
+
+func (p *Provider) Name() string {
+	return "ollama"
+}
+
+func (p *Provider) Review(ctx context.Context, req ai.ReviewRequest) (*ai.ReviewResult, error) {
+	logger.Log(fmt.Sprintf("Starting Ollama CodeReview request (%s)", p.ApiUrl))
+	return ai.ReviewWith(ctx, p.complete, req)
+}
+
+func (p *Provider) complete(ctx context.Context, prompt ai.Prompt) (string, error) {
+	requestBody, err := json.Marshal(ChatRequest{
+		Model: p.Model,
+		Messages: []Message{
+			{Role: "system", Content: prompt.System},
+			{Role: "user", Content: prompt.User},
+		},
+		Stream:  false,
+		Format:  ai.ReviewSchema,
+		Options: Options{NumPredict: p.MaxTokens},
+	})
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error marshaling request: %v", err))
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", p.ApiUrl, bytes.NewBuffer(requestBody))
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error creating request: %v", err))
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.ApiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.ApiKey)
+	}
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		logger.Log(fmt.Sprintf("HTTP request error: %v", err))
+		return "", err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			logger.Log(fmt.Sprintf("Error closing response body: %v", err))
+		}
+	}(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		errMsg := fmt.Sprintf("Ollama API responded with status code: %d, body: %s", resp.StatusCode, string(body))
+		logger.Log(errMsg)
+		return "", fmt.Errorf(errMsg)
+	}
+	var response ChatResponse
+	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		logger.Log(fmt.Sprintf("Error decoding response: %v", err))
+		return "", err
+	}
+	if response.Error != "" {
+		errMsg := "Ollama returned an error: " + response.Error
+		logger.Log(errMsg)
+		return "", fmt.Errorf(errMsg)
+	}
+	if response.Message.Content == "" {
+		errMsg := "No message content returned"
+		logger.Log(errMsg)
+		return "", fmt.Errorf(errMsg)
+	}
+	return response.Message.Content, nil
+}
@@ -0,0 +1,116 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/michalopenmakers/lazyreview/ai"
+	"github.com/michalopenmakers/lazyreview/httpclient"
+)
+
+const testDiff = "--- a/main.go\n+++ b/main.go\n@@ -1,2 +1,3 @@\n package main\n+var x = 1\n func main() {}\n"
+
+func newTestProvider(url string) *Provider {
+	client := httpclient.NewSafePost("ollama-test", 5*time.Second)
+	client.MaxRetries = 0
+	return &Provider{
+		ApiUrl:    url,
+		Model:     "qwen2.5-coder",
+		MaxTokens: 512,
+		Client:    client,
+	}
+}
+
+func TestReview(t *testing.T) {
+	var got struct {
+		ChatRequest
+		Format map[string]any `json:"format"`
+	}
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		answer := `{"summary": "Minor remarks.", "findings": [{"file": "main.go", "start_line": 2, "end_line": 2, "severity": "medium", "category": "maintainability", "message": "Global variable", "suggested_fix": ""}]}`
+		data, _ := json.Marshal(ChatResponse{Model: "qwen2.5-coder", Message: Message{Role: "assistant", Content: answer}, Done: true})
+		_, _ = w.Write(data)
+	}))
+	defer srv.Close()
+
+	result, err := newTestProvider(srv.URL).Review(context.Background(), ai.ReviewRequest{Changes: testDiff})
+	if err != nil {
+		t.Fatalf("Review: %v", err)
+	}
+
+	if auth != "" {
+		t.Errorf("Authorization = %q, want none without an API key", auth)
+	}
+	if got.Model != "qwen2.5-coder" || got.Stream || got.Options.NumPredict != 512 {
+		t.Errorf("request = %+v", got.ChatRequest)
+	}
+	if got.Format["type"] != "object" {
+		t.Errorf("format = %v, want the review schema", got.Format)
+	}
+	if len(got.Messages) != 2 || !strings.Contains(got.Messages[1].Content, "+var x = 1") {
+		t.Errorf("messages = %+v", got.Messages)
+	}
+
+	want := ai.Finding{File: "main.go", StartLine: 2, EndLine: 2, Severity: "medium", Category: "maintainability", Message: "Global variable"}
+	if len(result.Findings) != 1 || result.Findings[0] != want {
+		t.Errorf("findings = %+v, want %+v", result.Findings, want)
+	}
+}
+
+func TestReviewApiKey(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"message": {"role": "assistant", "content": "No issues."}, "done": true}`))
+	}))
+	defer srv.Close()
+
+	p := newTestProvider(srv.URL)
+	p.ApiKey = "secret"
+	result, err := p.Review(context.Background(), ai.ReviewRequest{Changes: testDiff})
+	if err != nil {
+		t.Fatalf("Review: %v", err)
+	}
+	if result.Text != "No issues." {
+		t.Errorf("text = %q", result.Text)
+	}
+}
+
+func TestReviewErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"model not found", http.StatusNotFound, `{"error": "model \"qwen2.5-coder\" not found"}`},
+		{"error in body", http.StatusOK, `{"error": "out of memory"}`},
+		{"empty message", http.StatusOK, `{"message": {"role": "assistant", "content": ""}, "done": true}`},
+		{"invalid JSON", http.StatusOK, `{"message": `},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			result, err := newTestProvider(srv.URL).Review(context.Background(), ai.ReviewRequest{Changes: testDiff})
+			if err == nil {
+				t.Fatalf("Review returned %+v, want an error", result)
+			}
+		})
+	}
+}
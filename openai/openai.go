@@ -2,21 +2,39 @@ package openai
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/michalopenmakers/lazyreview/ai"
 	"github.com/michalopenmakers/lazyreview/config"
+	"github.com/michalopenmakers/lazyreview/httpclient"
 	"github.com/michalopenmakers/lazyreview/logger"
 )
 
+const DefaultBaseUrl = "https://api.openai.com/v1"
+
 type CompletionRequest struct {
-	Model               string    `json:"model"`
-	Messages            []Message `json:"messages"`
-	MaxCompletionTokens int       `json:"max_completion_tokens"`
+	Model               string          `json:"model"`
+	Messages            []Message       `json:"messages"`
+	MaxCompletionTokens int             `json:"max_completion_tokens"`
+	ResponseFormat      *ResponseFormat `json:"response_format,omitempty"`
+}
+
+type ResponseFormat struct {
+	Type       string      `json:"type"`
+	JSONSchema *JSONSchema `json:"json_schema,omitempty"`
+}
+
+type JSONSchema struct {
+	Name   string         `json:"name"`
+	Schema map[string]any `json:"schema"`
+	Strict bool           `json:"strict"`
 }
 
 type Message struct {
@@ -33,160 +51,152 @@ type CompletionResponse struct {
 	} `json:"choices"`
 }
 
-func CodeReview(cfg *config.Config, codeChanges string, isFullReview bool) (string, error) {
-	logger.Log("Starting CodeReview request")
-	codeChanges = preprocessDiff(codeChanges)
-
-	url := "https://api.openai.com/v1/chat/completions"
-	var promptText string
-	if isFullReview {
-		promptText = "You are an experienced developer performing a complete code analysis. This is the project's first review, so analyze the project structure, code quality, potential security issues, performance and adherence to best practices. Be specific and helpful. Provide solution examples when possible."
-	} else {
-		// Zmieniony prompt dla review merge request
-		promptText = "You are an experienced developer performing a merge request code review. Please review the following merge request changes, analyze for bugs, security vulnerabilities, performance issues, and suggest improvements. Be specific and helpful. Provide solution examples when possible."
+// Provider reviews code using the OpenAI chat completions API. The same
+// request format is served by Azure OpenAI deployments, which differ only in
+// the URL scheme and the authentication header.
+type Provider struct {
+	ApiUrl    string
+	ApiKey    string
+	Model     string
+	MaxTokens int
+	Client    *httpclient.Client
+
+	// ApiKeyHeader, when set, carries the raw API key instead of the
+	// "Authorization: Bearer" header.
+	ApiKeyHeader string
+
+	name string
+	// noSchema is set once the endpoint rejected response_format, so later
+	// chunks do not repeat the failing request. A provider is created for
+	// every review, so the next review tries the schema again.
+	noSchema atomic.Bool
+}
+
+func init() {
+	ai.Register("openai", NewProvider)
+	ai.Register("azure", NewAzureProvider)
+}
+
+func NewProvider(cfg *config.Config) ai.ReviewProvider {
+	return &Provider{
+		ApiUrl:    cfg.AIModelConfig.GetApiUrl(DefaultBaseUrl, "/chat/completions"),
+		ApiKey:    cfg.AIModelConfig.ApiKey,
+		Model:     cfg.AIModelConfig.Model,
+		MaxTokens: cfg.AIModelConfig.MaxTokens,
+		Client:    httpclient.NewSafePost("openai", 60*time.Second),
 	}
-	if isFullReview && len(codeChanges) > 1500 {
-		var aggregatedReview string
-		segmentSize := 1500
-		segments := []string{}
-		for i := 0; i < len(codeChanges); i += segmentSize {
-			end := i + segmentSize
-			if end > len(codeChanges) {
-				end = len(codeChanges)
-			}
-			segments = append(segments, codeChanges[i:end])
-		}
-		for idx, segment := range segments {
-			segPrompt := fmt.Sprintf("%s\n\nSegment %d of %d", promptText, idx+1, len(segments))
-			messages := []Message{
-				{Role: "system", Content: segPrompt},
-				{Role: "user", Content: "Review the following code segment:\n\n" + segment},
-			}
-			logger.Log(fmt.Sprintf("Sending API request for segment %d of %d", idx+1, len(segments)))
-			logger.Log("System prompt sent to AI: " + segPrompt)
-			logger.Log("User prompt sent to AI: " + "Review the following code segment:\n\n" + segment)
-			requestBody, err := json.Marshal(CompletionRequest{
-				Model:               cfg.AIModelConfig.Model,
-				Messages:            messages,
-				MaxCompletionTokens: cfg.AIModelConfig.MaxTokens,
-			})
-			if err != nil {
-				logger.Log(fmt.Sprintf("Error marshaling request: %v", err))
-				return "", err
-			}
-			req, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBody))
-			if err != nil {
-				logger.Log(fmt.Sprintf("Error creating request: %v", err))
-				return "", err
-			}
-			req.Header.Set("Content-Type", "application/json")
-			req.Header.Set("Authorization", "Bearer "+cfg.AIModelConfig.ApiKey)
-			client := &http.Client{Timeout: 60 * time.Second}
-			resp, err := client.Do(req)
-			if err != nil {
-				logger.Log(fmt.Sprintf("HTTP request error: %v", err))
-				return "", err
-			}
-			defer func(Body io.ReadCloser) {
-				err := Body.Close()
-				if err != nil {
-
-				}
-			}(resp.Body)
-			if resp.StatusCode != http.StatusOK {
-				body, _ := io.ReadAll(resp.Body)
-				errMsg := fmt.Sprintf("API responded with status code: %d, body: %s", resp.StatusCode, string(body))
-				logger.Log(errMsg)
-				return "", fmt.Errorf(errMsg)
-			}
-			var response CompletionResponse
-			if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
-				logger.Log(fmt.Sprintf("Error decoding response: %v", err))
-				return "", err
-			}
-			if len(response.Choices) == 0 {
-				errMsg := fmt.Sprintf("No response choices returned for segment %d", idx+1)
-				logger.Log(errMsg)
-				return "", fmt.Errorf(errMsg)
-			}
-			// Dodano logowanie odpowiedzi AI dla danego segmentu
-			logger.Log(fmt.Sprintf("AI response for segment %d: %s", idx+1, response.Choices[0].Message.Content))
-			logger.Log(fmt.Sprintf("Received response for segment %d", idx+1))
-			aggregatedReview += response.Choices[0].Message.Content + "\n"
-		}
-		return aggregatedReview, nil
-	} else {
-		messages := []Message{
-			{Role: "system", Content: promptText},
-			{Role: "user", Content: "Please review the following merge request code diff and provide actionable feedback:\n\n" + codeChanges},
-		}
-		logger.Log("Sending API request for merge request review")
-		logger.Log("System prompt sent to AI: " + promptText)
-		logger.Log("User prompt sent to AI: " + "Please review the following merge request code diff and provide actionable feedback:\n\n" + codeChanges)
-		requestBody, err := json.Marshal(CompletionRequest{
-			Model:               cfg.AIModelConfig.Model,
-			Messages:            messages,
-			MaxCompletionTokens: cfg.AIModelConfig.MaxTokens,
-		})
-		if err != nil {
-			logger.Log(fmt.Sprintf("Error marshaling request: %v", err))
-			return "", err
-		}
-		req, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBody))
-		if err != nil {
-			logger.Log(fmt.Sprintf("Error creating request: %v", err))
-			return "", err
-		}
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Authorization", "Bearer "+cfg.AIModelConfig.ApiKey)
-		client := &http.Client{Timeout: 60 * time.Second}
-		resp, err := client.Do(req)
-		if err != nil {
-			logger.Log(fmt.Sprintf("HTTP request error: %v", err))
-			return "", err
-		}
-		defer func(Body io.ReadCloser) {
-			err := Body.Close()
-			if err != nil {
-
-			}
-		}(resp.Body)
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			errMsg := fmt.Sprintf("API responded with status code: %d, body: %s", resp.StatusCode, string(body))
-			logger.Log(errMsg)
-			return "", fmt.Errorf(errMsg)
-		}
-		var response CompletionResponse
-		if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
-			logger.Log(fmt.Sprintf("Error decoding response: %v", err))
-			return "", err
-		}
-		if len(response.Choices) == 0 {
-			errMsg := "No response choices returned"
-			logger.Log(errMsg)
-			return "", fmt.Errorf(errMsg)
-		}
-		// Dodano logowanie odpowiedzi AI dla merge request review
-		logger.Log("AI response: " + response.Choices[0].Message.Content)
-		logger.Log("Received API response for code review")
-		return response.Choices[0].Message.Content, nil
+}
+
+func NewAzureProvider(cfg *config.Config) ai.ReviewProvider {
+	return &Provider{
+		ApiUrl:       cfg.AIModelConfig.GetAzureApiUrl(),
+		ApiKey:       cfg.AIModelConfig.ApiKey,
+		ApiKeyHeader: "api-key",
+		Model:        cfg.AIModelConfig.Model,
+		MaxTokens:    cfg.AIModelConfig.MaxTokens,
+		Client:       httpclient.NewSafePost("azure", 60*time.Second),
+		name:         "azure",
+	}
+}
+
+func (p *Provider) Name() string {
+	if p.name != "" {
+		return p.name
 	}
+	return "openai"
 }
 
-// Dodana funkcja pomocnicza do przetwarzania danych diff
-func preprocessDiff(diff string) string {
-	lines := strings.Split(diff, "\n")
-	var filtered []string
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		if trimmed == "" {
-			continue
+func (p *Provider) Review(ctx context.Context, req ai.ReviewRequest) (*ai.ReviewResult, error) {
+	logger.Log(fmt.Sprintf("Starting CodeReview request (%s)", p.Name()))
+	return ai.ReviewWith(ctx, p.complete, req)
+}
+
+// complete requests a response following ai.ReviewSchema. Models and
+// gateways without structured outputs (older Azure API versions, gpt-4,
+// vLLM, LM Studio) answer 400 naming response_format or json_schema, in
+// which case the request is repeated without response_format and the reply is
+// parsed as free-form JSON. Other 400 errors are returned as they are.
+func (p *Provider) complete(ctx context.Context, prompt ai.Prompt) (string, error) {
+	request := CompletionRequest{
+		Model: p.Model,
+		Messages: []Message{
+			{Role: "system", Content: prompt.System},
+			{Role: "user", Content: prompt.User},
+		},
+		MaxCompletionTokens: p.MaxTokens,
+	}
+	if !p.noSchema.Load() {
+		request.ResponseFormat = &ResponseFormat{
+			Type: "json_schema",
+			JSONSchema: &JSONSchema{
+				Name:   "code_review",
+				Schema: ai.ReviewSchema,
+				Strict: true,
+			},
 		}
-		if strings.HasPrefix(trimmed, "#") {
-			continue
+	}
+	content, status, err := p.send(ctx, request)
+	if status == http.StatusBadRequest && request.ResponseFormat != nil && schemaRejected(err) {
+		logger.Log(fmt.Sprintf("%s rejected the JSON schema response format, sending the rest of this review without it", p.Name()))
+		p.noSchema.Store(true)
+		request.ResponseFormat = nil
+		content, _, err = p.send(ctx, request)
+	}
+	return content, err
+}
+
+// schemaRejected reports whether the error body blames the response format
+// rather than e.g. the model name or the length of the prompt.
+func schemaRejected(err error) bool {
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "response_format") || strings.Contains(message, "json_schema")
+}
+
+// send posts the request and returns the content of the first choice along
+// with the response status, 0 when no response was received.
+func (p *Provider) send(ctx context.Context, request CompletionRequest) (string, int, error) {
+	requestBody, err := json.Marshal(request)
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error marshaling request: %v", err))
+		return "", 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", p.ApiUrl, bytes.NewBuffer(requestBody))
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error creating request: %v", err))
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.ApiKeyHeader != "" {
+		req.Header.Set(p.ApiKeyHeader, p.ApiKey)
+	} else if p.ApiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.ApiKey)
+	}
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		logger.Log(fmt.Sprintf("HTTP request error: %v", err))
+		return "", 0, err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			logger.Log(fmt.Sprintf("Error closing response body: %v", err))
 		}
-		filtered = append(filtered, line)
+	}(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		errMsg := fmt.Sprintf("API responded with status code: %d, body: %s", resp.StatusCode, string(body))
+		logger.Log(errMsg)
+		return "", resp.StatusCode, fmt.Errorf(errMsg)
+	}
+	var response CompletionResponse
+	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		logger.Log(fmt.Sprintf("Error decoding response: %v", err))
+		return "", resp.StatusCode, err
+	}
+	if len(response.Choices) == 0 {
+		errMsg := "No response choices returned"
+		logger.Log(errMsg)
+		return "", resp.StatusCode, fmt.Errorf(errMsg)
 	}
-	return strings.Join(filtered, "\n")
+	return response.Choices[0].Message.Content, resp.StatusCode, nil
 }
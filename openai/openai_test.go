@@ -0,0 +1,174 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/michalopenmakers/lazyreview/ai"
+	"github.com/michalopenmakers/lazyreview/httpclient"
+)
+
+const testDiff = "--- a/main.go\n+++ b/main.go\n@@ -1,2 +1,3 @@\n package main\n+var x = 1\n func main() {}\n"
+
+const testAnswer = `{"summary": "One issue.", "findings": [{"file": "main.go", "start_line": 2, "end_line": 2, "severity": "HIGH", "category": "bug", "message": "x is unused", "suggested_fix": ""}]}`
+
+func newTestProvider(url string) *Provider {
+	client := httpclient.NewSafePost("openai-test", 5*time.Second)
+	client.MaxRetries = 0
+	return &Provider{
+		ApiUrl:    url,
+		ApiKey:    "sk-test",
+		Model:     "gpt-4o",
+		MaxTokens: 1000,
+		Client:    client,
+	}
+}
+
+func completion(content string) string {
+	data, _ := json.Marshal(map[string]any{
+		"id":      "chatcmpl-1",
+		"choices": []any{map[string]any{"message": map[string]any{"content": content}}},
+	})
+	return string(data)
+}
+
+func TestReview(t *testing.T) {
+	var got CompletionRequest
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		_, _ = w.Write([]byte(completion(testAnswer)))
+	}))
+	defer srv.Close()
+
+	result, err := newTestProvider(srv.URL).Review(context.Background(), ai.ReviewRequest{Changes: testDiff})
+	if err != nil {
+		t.Fatalf("Review: %v", err)
+	}
+
+	if auth != "Bearer sk-test" {
+		t.Errorf("Authorization = %q", auth)
+	}
+	if got.Model != "gpt-4o" || got.MaxCompletionTokens != 1000 {
+		t.Errorf("model %q, max tokens %d", got.Model, got.MaxCompletionTokens)
+	}
+	if len(got.Messages) != 2 || got.Messages[0].Role != "system" || got.Messages[1].Role != "user" {
+		t.Fatalf("messages = %+v", got.Messages)
+	}
+	if !strings.Contains(got.Messages[1].Content, "+var x = 1") {
+		t.Errorf("user message does not contain the diff: %q", got.Messages[1].Content)
+	}
+	if got.ResponseFormat == nil || got.ResponseFormat.Type != "json_schema" || got.ResponseFormat.JSONSchema == nil {
+		t.Errorf("response_format = %+v", got.ResponseFormat)
+	}
+
+	if result.Summary != "One issue." {
+		t.Errorf("summary = %q", result.Summary)
+	}
+	want := ai.Finding{File: "main.go", StartLine: 2, EndLine: 2, Severity: "high", Category: "bug", Message: "x is unused"}
+	if len(result.Findings) != 1 || result.Findings[0] != want {
+		t.Errorf("findings = %+v, want %+v", result.Findings, want)
+	}
+}
+
+func TestReviewWithoutResponseFormat(t *testing.T) {
+	var formats []bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request CompletionRequest
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		formats = append(formats, request.ResponseFormat != nil)
+		if request.ResponseFormat != nil {
+			http.Error(w, `{"error": {"message": "response_format is not supported"}}`, http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(completion(testAnswer)))
+	}))
+	defer srv.Close()
+
+	p := newTestProvider(srv.URL)
+	for i := 0; i < 2; i++ {
+		result, err := p.Review(context.Background(), ai.ReviewRequest{Changes: testDiff})
+		if err != nil {
+			t.Fatalf("Review %d: %v", i, err)
+		}
+		if len(result.Findings) != 1 {
+			t.Errorf("Review %d: findings = %+v", i, result.Findings)
+		}
+	}
+	// Kolejne żądania tego samego dostawcy nie powtarzają odrzuconego formatu
+	if want := []bool{true, false, false}; !slices.Equal(formats, want) {
+		t.Errorf("requests with response_format = %v, want %v", formats, want)
+	}
+}
+
+func TestReviewBadRequestKeepsSchema(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Error(w, `{"error": {"message": "This model's maximum context length is 8192 tokens"}}`, http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	p := newTestProvider(srv.URL)
+	if _, err := p.Review(context.Background(), ai.ReviewRequest{Changes: testDiff}); err == nil {
+		t.Fatal("Review succeeded, want the 400 error")
+	}
+	// Błąd niezwiązany ze schematem nie wyłącza response_format
+	if requests != 1 || p.noSchema.Load() {
+		t.Errorf("sent %d requests, noSchema = %v; want 1 request with the schema kept", requests, p.noSchema.Load())
+	}
+}
+
+func TestReviewAzureHeader(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("api-key") != "sk-test" || r.Header.Get("Authorization") != "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(completion(testAnswer)))
+	}))
+	defer srv.Close()
+
+	p := newTestProvider(srv.URL)
+	p.ApiKeyHeader = "api-key"
+	if _, err := p.Review(context.Background(), ai.ReviewRequest{Changes: testDiff}); err != nil {
+		t.Fatalf("Review: %v", err)
+	}
+}
+
+func TestReviewErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"unauthorized", http.StatusUnauthorized, `{"error": {"message": "invalid api key"}}`},
+		{"server error", http.StatusInternalServerError, `{"error": {"message": "overloaded"}}`},
+		{"bad request without schema", http.StatusBadRequest, `{"error": {"message": "bad request"}}`},
+		{"no choices", http.StatusOK, `{"id": "chatcmpl-1", "choices": []}`},
+		{"invalid JSON", http.StatusOK, `{"choices": [`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			result, err := newTestProvider(srv.URL).Review(context.Background(), ai.ReviewRequest{Changes: testDiff})
+			if err == nil {
+				t.Fatalf("Review returned %+v, want an error", result)
+			}
+		})
+	}
+}
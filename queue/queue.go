@@ -0,0 +1,169 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/michalopenmakers/lazyreview/logger"
+)
+
+// Job is a unit of work executed by the queue.
+type Job struct {
+	// ID deduplicates jobs: only one job with a given ID is queued or
+	// running at a time.
+	ID string
+	// Version identifies the input of the job, e.g. a commit SHA. Submitting
+	// a job with a new version cancels the stale one.
+	Version string
+	// Group limits concurrency, e.g. "gitlab" or "github".
+	Group string
+	Run   func(ctx context.Context)
+}
+
+type runningJob struct {
+	version string
+	cancel  context.CancelFunc
+}
+
+// Queue runs jobs on a fixed number of workers, honoring per-group
+// concurrency limits.
+type Queue struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	workers   int
+	limits    map[string]int
+	pending   []Job
+	running   map[string]*runningJob
+	perGroup  map[string]int
+	ctx       context.Context
+	cancelAll context.CancelFunc
+	stopped   bool
+	wg        sync.WaitGroup
+}
+
+// New creates a queue with the given number of workers. A limit of 0 or a
+// missing group in limits means the group is bounded only by the workers.
+// Jobs run with a context derived from ctx, so cancelling it interrupts them.
+func New(ctx context.Context, workers int, limits map[string]int) *Queue {
+	if workers < 1 {
+		workers = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	q := &Queue{
+		workers:   workers,
+		limits:    limits,
+		running:   make(map[string]*runningJob),
+		perGroup:  make(map[string]int),
+		ctx:       ctx,
+		cancelAll: cancel,
+	}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+func (q *Queue) Start() {
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.worker()
+	}
+	logger.Log(fmt.Sprintf("Review queue started with %d workers", q.workers))
+}
+
+// Submit enqueues a job. It returns false when an identical job (same ID and
+// version) is already queued or running.
+func (q *Queue) Submit(job Job) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.stopped {
+		return false
+	}
+
+	if running, ok := q.running[job.ID]; ok {
+		if running.version == job.Version {
+			return false
+		}
+		logger.Log(fmt.Sprintf("Cancelling stale job %s (%s), newer version %s submitted", job.ID, running.version, job.Version))
+		running.cancel()
+	}
+	for i, pending := range q.pending {
+		if pending.ID == job.ID {
+			if pending.Version == job.Version {
+				return false
+			}
+			q.pending[i] = job
+			return true
+		}
+	}
+	q.pending = append(q.pending, job)
+	q.cond.Signal()
+	return true
+}
+
+// Stop cancels running jobs, drops the pending ones and waits for the
+// workers to finish.
+func (q *Queue) Stop() {
+	q.mu.Lock()
+	q.stopped = true
+	q.pending = nil
+	q.cancelAll()
+	q.cond.Broadcast()
+	q.mu.Unlock()
+	q.wg.Wait()
+	logger.Log("Review queue stopped")
+}
+
+// Len returns the number of queued and running jobs.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending) + len(q.running)
+}
+
+func (q *Queue) worker() {
+	defer q.wg.Done()
+	for {
+		q.mu.Lock()
+		job, ok := q.next()
+		for !ok && !q.stopped {
+			q.cond.Wait()
+			job, ok = q.next()
+		}
+		if q.stopped {
+			q.mu.Unlock()
+			return
+		}
+		ctx, cancel := context.WithCancel(q.ctx)
+		q.running[job.ID] = &runningJob{version: job.Version, cancel: cancel}
+		q.perGroup[job.Group]++
+		q.mu.Unlock()
+
+		job.Run(ctx)
+
+		q.mu.Lock()
+		cancel()
+		if running, ok := q.running[job.ID]; ok && running.version == job.Version {
+			delete(q.running, job.ID)
+		}
+		q.perGroup[job.Group]--
+		q.cond.Broadcast()
+		q.mu.Unlock()
+	}
+}
+
+// next takes the first pending job that may run now. It must be called
+// with the mutex held.
+func (q *Queue) next() (Job, bool) {
+	for i, job := range q.pending {
+		if _, busy := q.running[job.ID]; busy {
+			// Poprzednia wersja wciąż kończy pracę po anulowaniu
+			continue
+		}
+		if limit := q.limits[job.Group]; limit > 0 && q.perGroup[job.Group] >= limit {
+			continue
+		}
+		q.pending = append(q.pending[:i], q.pending[i+1:]...)
+		return job, true
+	}
+	return Job{}, false
+}
@@ -0,0 +1,192 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitFor fails the test when done is not closed in time.
+func waitFor(t *testing.T, done <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+}
+
+func TestSubmitDedup(t *testing.T) {
+	tests := []struct {
+		name    string
+		running Job
+		submits []Job
+		want    []bool
+		// wantRuns are the versions run after the running job finishes
+		wantRuns []string
+	}{
+		{
+			name:     "same version while running",
+			running:  Job{ID: "a", Version: "1"},
+			submits:  []Job{{ID: "a", Version: "1"}},
+			want:     []bool{false},
+			wantRuns: nil,
+		},
+		{
+			name:     "same version while pending",
+			running:  Job{ID: "block", Version: "1"},
+			submits:  []Job{{ID: "a", Version: "1"}, {ID: "a", Version: "1"}},
+			want:     []bool{true, false},
+			wantRuns: []string{"a@1"},
+		},
+		{
+			name:     "new version replaces the pending one",
+			running:  Job{ID: "block", Version: "1"},
+			submits:  []Job{{ID: "a", Version: "1"}, {ID: "a", Version: "2"}},
+			want:     []bool{true, true},
+			wantRuns: []string{"a@2"},
+		},
+		{
+			name:     "other jobs",
+			running:  Job{ID: "block", Version: "1"},
+			submits:  []Job{{ID: "a", Version: "1"}, {ID: "b", Version: "1"}},
+			want:     []bool{true, true},
+			wantRuns: []string{"a@1", "b@1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := New(context.Background(), 1, nil)
+			q.Start()
+			defer q.Stop()
+
+			started, release := make(chan struct{}), make(chan struct{})
+			tt.running.Run = func(ctx context.Context) {
+				close(started)
+				<-release
+			}
+			q.Submit(tt.running)
+			waitFor(t, started, "the running job")
+
+			var mu sync.Mutex
+			var runs []string
+			var wg sync.WaitGroup
+			for i, job := range tt.submits {
+				job.Run = func(ctx context.Context) {
+					mu.Lock()
+					runs = append(runs, job.ID+"@"+job.Version)
+					mu.Unlock()
+					wg.Done()
+				}
+				if got := q.Submit(job); got != tt.want[i] {
+					t.Errorf("Submit(%s@%s) = %v, want %v", job.ID, job.Version, got, tt.want[i])
+				}
+			}
+			wg.Add(len(tt.wantRuns))
+			close(release)
+			done := make(chan struct{})
+			go func() {
+				wg.Wait()
+				close(done)
+			}()
+			waitFor(t, done, "the submitted jobs")
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(runs) != len(tt.wantRuns) {
+				t.Fatalf("runs = %v, want %v", runs, tt.wantRuns)
+			}
+			for i := range runs {
+				if runs[i] != tt.wantRuns[i] {
+					t.Errorf("runs = %v, want %v", runs, tt.wantRuns)
+				}
+			}
+		})
+	}
+}
+
+func TestNewVersionCancelsRunningJob(t *testing.T) {
+	q := New(context.Background(), 2, nil)
+	q.Start()
+	defer q.Stop()
+
+	started, cancelled := make(chan struct{}), make(chan struct{})
+	q.Submit(Job{ID: "a", Version: "1", Run: func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+	}})
+	waitFor(t, started, "version 1")
+
+	ran := make(chan struct{})
+	if !q.Submit(Job{ID: "a", Version: "2", Run: func(ctx context.Context) { close(ran) }}) {
+		t.Fatal("Submit of version 2 returned false")
+	}
+	waitFor(t, cancelled, "version 1 to be cancelled")
+	waitFor(t, ran, "version 2")
+}
+
+func TestGroupLimit(t *testing.T) {
+	q := New(context.Background(), 4, map[string]int{"gitlab": 1})
+	q.Start()
+	defer q.Stop()
+
+	var active, peak atomic.Int32
+	var wg sync.WaitGroup
+	for _, id := range []string{"a", "b", "c"} {
+		wg.Add(1)
+		q.Submit(Job{ID: id, Group: "gitlab", Run: func(ctx context.Context) {
+			defer wg.Done()
+			n := active.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			active.Add(-1)
+		}})
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	waitFor(t, done, "the jobs")
+	if peak.Load() != 1 {
+		t.Errorf("%d gitlab jobs ran at once, want 1", peak.Load())
+	}
+}
+
+func TestStop(t *testing.T) {
+	q := New(context.Background(), 1, nil)
+	q.Start()
+
+	started, cancelled := make(chan struct{}), make(chan struct{})
+	q.Submit(Job{ID: "a", Run: func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+	}})
+	waitFor(t, started, "the running job")
+	var pendingRan atomic.Bool
+	q.Submit(Job{ID: "b", Run: func(ctx context.Context) { pendingRan.Store(true) }})
+
+	stopped := make(chan struct{})
+	go func() {
+		q.Stop()
+		close(stopped)
+	}()
+	waitFor(t, cancelled, "the running job to be cancelled")
+	waitFor(t, stopped, "Stop")
+
+	if pendingRan.Load() {
+		t.Error("a pending job ran after Stop")
+	}
+	if q.Submit(Job{ID: "c", Run: func(ctx context.Context) {}}) {
+		t.Error("Submit after Stop returned true")
+	}
+}
@@ -0,0 +1,108 @@
+package review
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/michalopenmakers/lazyreview/ai"
+	"github.com/michalopenmakers/lazyreview/config"
+	"github.com/michalopenmakers/lazyreview/diff"
+	"github.com/michalopenmakers/lazyreview/logger"
+)
+
+// fileFunc fetches the content of a file at the reviewed commit.
+type fileFunc func(ctx context.Context, filePath string) (string, error)
+
+// defaultContextLines is used when FileContextConfig.Lines is not set.
+const defaultContextLines = 20
+
+// fileContext fetches the post-change content of the files in changes, as
+// configured by cfg.FileContext. Files are added in diff order until the
+// token budget runs out; a whole file that does not fit is replaced by the
+// lines around its hunks.
+func fileContext(ctx context.Context, cfg *config.Config, changes string, file fileFunc) []ai.FileContext {
+	mode := cfg.FileContext.Mode
+	if mode != config.FileContextFull && mode != config.FileContextLines {
+		return nil
+	}
+	around := cfg.FileContext.Lines
+	if around <= 0 {
+		around = defaultContextLines
+	}
+	budget := cfg.FileContext.MaxTokens
+	if budget <= 0 {
+		budget = ai.ChunkBudget(cfg.AIModelConfig) / 2
+	}
+
+	var files []ai.FileContext
+	for _, changed := range diff.Parse(changes) {
+		if ctx.Err() != nil || budget <= 0 {
+			break
+		}
+		if changed.NewPath == "/dev/null" || len(changed.Hunks) == 0 {
+			continue
+		}
+		content, err := file(ctx, changed.NewPath)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				logger.Log(fmt.Sprintf("Cannot fetch %s for context: %v", changed.NewPath, err))
+			}
+			continue
+		}
+		lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+		text := ""
+		if mode == config.FileContextFull {
+			text = numberLines(lines, 1, len(lines))
+		}
+		if text == "" || ai.EstimateTokens(text) > budget {
+			text = hunkSurroundings(lines, changed.Hunks, around)
+		}
+		if tokens := ai.EstimateTokens(text); tokens <= budget {
+			files = append(files, ai.FileContext{Path: changed.NewPath, Text: text})
+			budget -= tokens
+		}
+	}
+	if len(files) > 0 {
+		logger.Log(fmt.Sprintf("Added context of %d files to the review", len(files)))
+	}
+	return files
+}
+
+// hunkSurroundings returns the lines around the hunks, n lines before and
+// after each, with overlapping ranges merged.
+func hunkSurroundings(lines []string, hunks []diff.Hunk, n int) string {
+	var sb strings.Builder
+	start, end := 0, -1
+	flush := func() {
+		if end >= start {
+			if sb.Len() > 0 {
+				sb.WriteString("...\n")
+			}
+			sb.WriteString(numberLines(lines, start, end))
+		}
+	}
+	for _, hunk := range hunks {
+		from := max(hunk.NewStart-n, 1)
+		to := min(hunk.NewStart+hunk.NewLines-1+n, len(lines))
+		if from > end+1 {
+			flush()
+			start = from
+		}
+		end = max(end, to)
+	}
+	flush()
+	return sb.String()
+}
+
+// numberLines renders lines first..last (1-based, inclusive) prefixed with
+// their numbers.
+func numberLines(lines []string, first, last int) string {
+	var sb strings.Builder
+	for i := first; i <= last; i++ {
+		sb.WriteString(fmt.Sprintf("%5d| %s\n", i, lines[i-1]))
+	}
+	return sb.String()
+}
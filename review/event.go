@@ -0,0 +1,134 @@
+package review
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	neturl "net/url"
+	"strconv"
+	"strings"
+
+	"github.com/michalopenmakers/lazyreview/config"
+	"github.com/michalopenmakers/lazyreview/github"
+	"github.com/michalopenmakers/lazyreview/gitlab"
+	"github.com/michalopenmakers/lazyreview/logger"
+)
+
+// ErrMonitoringStopped is returned for events arriving while monitoring is
+// not running.
+var ErrMonitoringStopped = errors.New("monitoring is not running")
+
+// EnqueueMergeRequest queues the review of a merge request reported by a
+// webhook. The merge request is read again from the API and goes through the
+// same checks as during polling, so events of unwatched projects or merge
+// requests the user does not review are ignored.
+func EnqueueMergeRequest(ctx context.Context, cfg *config.Config, projectID string, mrID int) error {
+	q := activeQueue()
+	if q == nil {
+		return ErrMonitoringStopped
+	}
+	mr, err := gitlab.GetMergeRequest(ctx, cfg, neturl.PathEscape(projectID), mrID)
+	if err != nil {
+		return err
+	}
+	if mr.State != "opened" {
+		logger.Log(fmt.Sprintf("MR #%d in project %s is %s, ignoring event", mr.IID, projectID, mr.State))
+		return nil
+	}
+	watched, err := watchesMergeRequest(ctx, cfg, mr)
+	if err != nil {
+		return err
+	}
+	if !watched {
+		logger.Log(fmt.Sprintf("MR #%d in project %s is not monitored, ignoring event", mr.IID, projectID))
+		return nil
+	}
+	queueMergeRequest(ctx, cfg, q, mr)
+	return nil
+}
+
+// EnqueuePullRequest queues the review of a pull request reported by a
+// webhook, like EnqueueMergeRequest.
+func EnqueuePullRequest(ctx context.Context, cfg *config.Config, repository string, prID int) error {
+	q := activeQueue()
+	if q == nil {
+		return ErrMonitoringStopped
+	}
+	pr, err := github.GetPullRequest(ctx, cfg, repository, prID)
+	if err != nil {
+		return err
+	}
+	if pr.State != "open" {
+		logger.Log(fmt.Sprintf("PR #%d in %s is %s, ignoring event", prID, repository, pr.State))
+		return nil
+	}
+	watched, err := watchesPullRequest(ctx, cfg, pr)
+	if err != nil {
+		return err
+	}
+	if !watched {
+		logger.Log(fmt.Sprintf("PR #%d in %s is not monitored, ignoring event", prID, repository))
+		return nil
+	}
+	queuePullRequest(ctx, cfg, q, pr)
+	return nil
+}
+
+// watchesMergeRequest reports whether polling would pick up the merge
+// request: in watch list mode its project has to be listed and pass the
+// watch filter, otherwise the user has to be one of its reviewers.
+func watchesMergeRequest(ctx context.Context, cfg *config.Config, mr gitlab.MergeRequest) (bool, error) {
+	if cfg.GitLabConfig.WatchListMode {
+		// Projekt może być podany jako ID albo ścieżka, ścieżkę bierzemy z URL
+		projects := []string{strconv.Itoa(mr.ProjectID)}
+		if _, path, _, err := ParseURL(mr.WebURL); err == nil {
+			projects = append(projects, path)
+		}
+		return listed(cfg.GitLabConfig.ProjectIDs, projects...) &&
+			cfg.GitLabConfig.WatchFilter.Matches(mr.Labels, mr.TargetBranch, mr.Author.Username), nil
+	}
+	user, err := gitlab.GetCurrentUser(ctx, cfg)
+	if err != nil {
+		return false, err
+	}
+	for _, reviewer := range mr.Reviewers {
+		if reviewer.Username == user.Username {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// watchesPullRequest reports whether polling would pick up the pull request:
+// in watch list mode its repository has to be listed and pass the watch
+// filter, otherwise it has to be assigned to the user.
+func watchesPullRequest(ctx context.Context, cfg *config.Config, pr github.PullRequest) (bool, error) {
+	if cfg.GitHubConfig.WatchListMode {
+		return listed(cfg.GitHubConfig.Repositories, pr.Repository) &&
+			cfg.GitHubConfig.WatchFilter.Matches(pr.Labels, pr.TargetBranch, pr.Author), nil
+	}
+	user, err := github.GetCurrentUser(ctx, cfg)
+	if err != nil {
+		return false, err
+	}
+	for _, assignee := range pr.Assignees {
+		if strings.EqualFold(assignee, user.Login) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// listed reports whether any of names is on the configured list. Names are
+// compared case-insensitively, as GitHub and GitLab paths are.
+func listed(list []string, names ...string) bool {
+	for _, entry := range list {
+		entry = strings.Trim(strings.TrimSpace(entry), "/")
+		for _, name := range names {
+			if strings.EqualFold(entry, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package review
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/michalopenmakers/lazyreview/ai"
+	"github.com/michalopenmakers/lazyreview/config"
+	"github.com/michalopenmakers/lazyreview/filter"
+	"github.com/michalopenmakers/lazyreview/logger"
+	"github.com/michalopenmakers/lazyreview/state"
+)
+
+// reviewInput is what a review job sends to the AI provider. BaseCommit is
+// set for incremental reviews and names the previously reviewed commit.
+// Skipped lists the files left out by the diff filters.
+type reviewInput struct {
+	ai.ReviewRequest
+	BaseCommit string
+	Skipped    []filter.Skipped
+}
+
+// changesFunc fetches a filtered diff and the files left out of it.
+type changesFunc func(ctx context.Context) (string, []filter.Skipped, error)
+
+// fetchInput prepares the review of commit. With incremental reviews enabled
+// and an earlier review available, only the delta since the reviewed commit
+// is fetched via compare; any failure there falls back to the full diff.
+// The content of the changed files at commit is added as configured in
+// Config.FileContext.
+func fetchInput(ctx context.Context, cfg *config.Config, reviewID, commit string, compare func(ctx context.Context, base string) (string, []filter.Skipped, error), full changesFunc, file fileFunc) (reviewInput, error) {
+	input, err := fetchChanges(ctx, cfg, reviewID, commit, compare, full)
+	if err != nil {
+		return input, err
+	}
+	input.FileContext = fileContext(ctx, cfg, input.Changes, file)
+	return input, nil
+}
+
+func fetchChanges(ctx context.Context, cfg *config.Config, reviewID, commit string, compare func(ctx context.Context, base string) (string, []filter.Skipped, error), full changesFunc) (reviewInput, error) {
+	if cfg.IncrementalReviews {
+		base, previous := lastReview(reviewID)
+		if base != "" && base != commit && previous != "" {
+			delta, skipped, err := compare(ctx, base)
+			switch {
+			case err != nil:
+				logger.Log(fmt.Sprintf("Cannot compare %s with the last review, running a full review: %v", reviewID, err))
+			case strings.TrimSpace(delta) == "" && len(skipped) == 0:
+				logger.Log(fmt.Sprintf("No changes in %s since the last review, running a full review", reviewID))
+			default:
+				logger.Log(fmt.Sprintf("Reviewing %s incrementally since %s", reviewID, base))
+				return reviewInput{
+					ReviewRequest: ai.ReviewRequest{Changes: delta, PreviousReview: previous},
+					BaseCommit:    base,
+					Skipped:       skipped,
+				}, nil
+			}
+		}
+	}
+
+	changes, skipped, err := full(ctx)
+	if err != nil {
+		return reviewInput{}, err
+	}
+	return reviewInput{ReviewRequest: ai.ReviewRequest{Changes: changes}, Skipped: skipped}, nil
+}
+
+// lastReview returns the commit and text of the latest completed review.
+func lastReview(reviewID string) (string, string) {
+	reviewsMutex.Lock()
+	defer reviewsMutex.Unlock()
+	for _, r := range reviews {
+		if r.ID == reviewID {
+			return r.LastCommit, r.ReviewText
+		}
+	}
+	return "", ""
+}
+
+// GetReviewHistory returns the review rounds of a merge/pull request, oldest
+// first.
+func GetReviewHistory(reviewID string) []state.ReviewRun {
+	return state.GetReviewRuns(reviewID)
+}
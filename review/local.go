@@ -0,0 +1,115 @@
+package review
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/michalopenmakers/lazyreview/ai"
+	"github.com/michalopenmakers/lazyreview/config"
+	"github.com/michalopenmakers/lazyreview/filter"
+	"github.com/michalopenmakers/lazyreview/localgit"
+	"github.com/michalopenmakers/lazyreview/logger"
+)
+
+// ErrNoChanges is returned by ReviewLocal when there is nothing to review.
+var ErrNoChanges = errors.New("no changes to review")
+
+// LocalOptions selects the changes reviewed by ReviewLocal.
+type LocalOptions struct {
+	// Dir is any directory inside the working copy.
+	Dir string
+	// Base is the branch HEAD is compared with (git diff Base...HEAD); empty
+	// uses the default branch of origin.
+	Base string
+	// Staged reviews the staged changes instead of the branch.
+	Staged bool
+}
+
+// ReviewLocal reviews a local working copy with the same AI pipeline as
+// merge and pull requests, without any forge. The review is added to the
+// list, but there is nowhere to submit it.
+func ReviewLocal(ctx context.Context, cfg *config.Config, opts LocalOptions) (CodeReview, error) {
+	dir := opts.Dir
+	if dir == "" {
+		dir = "."
+	}
+	root, err := localgit.TopLevel(ctx, dir)
+	if err != nil {
+		return CodeReview{}, err
+	}
+	commit, err := localgit.HeadCommit(ctx, root)
+	if err != nil {
+		return CodeReview{}, err
+	}
+	branch, err := localgit.CurrentBranch(ctx, root)
+	if err != nil {
+		return CodeReview{}, err
+	}
+
+	var reviewID, title string
+	var changes changesFunc
+	fileRef := commit
+	if opts.Staged {
+		reviewID = fmt.Sprintf("local-%s-staged", root)
+		title = fmt.Sprintf("%s: staged changes", filepath.Base(root))
+		changes = func(ctx context.Context) (string, []filter.Skipped, error) {
+			return localgit.GetStagedChanges(ctx, cfg, root)
+		}
+		fileRef = localgit.StagedRef
+	} else {
+		base := opts.Base
+		if base == "" {
+			if base, err = localgit.DefaultBase(ctx, root); err != nil {
+				return CodeReview{}, err
+			}
+		}
+		reviewID = fmt.Sprintf("local-%s-%s", root, branch)
+		title = fmt.Sprintf("%s: %s...%s", filepath.Base(root), base, branch)
+		changes = func(ctx context.Context) (string, []filter.Skipped, error) {
+			return localgit.GetBranchChanges(ctx, cfg, root, base)
+		}
+	}
+
+	registerReview(CodeReview{
+		ID:         reviewID,
+		Title:      title,
+		Source:     "local",
+		Repository: root,
+	})
+	logger.Log(fmt.Sprintf("Reviewing local changes: %s", title))
+	fetch := func(ctx context.Context) (reviewInput, error) {
+		diff, skipped, err := changes(ctx)
+		if err != nil {
+			return reviewInput{}, err
+		}
+		if strings.TrimSpace(diff) == "" && len(skipped) == 0 {
+			return reviewInput{}, ErrNoChanges
+		}
+		file := func(ctx context.Context, filePath string) (string, error) {
+			return localgit.GetFileContent(ctx, root, filePath, fileRef)
+		}
+		return reviewInput{
+			ReviewRequest: ai.ReviewRequest{Changes: diff, FileContext: fileContext(ctx, cfg, diff, file)},
+			Skipped:       skipped,
+		}, nil
+	}
+	if err := processReview(ctx, cfg, reviewID, commit, fetch); err != nil {
+		return CodeReview{}, err
+	}
+	return GetReview(reviewID)
+}
+
+// GetReview returns a copy of the review with the given ID.
+func GetReview(reviewID string) (CodeReview, error) {
+	reviewsMutex.Lock()
+	defer reviewsMutex.Unlock()
+	for _, r := range reviews {
+		if r.ID == reviewID {
+			return r, nil
+		}
+	}
+	return CodeReview{}, fmt.Errorf("review %s not found", reviewID)
+}
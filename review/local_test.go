@@ -0,0 +1,147 @@
+package review
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/michalopenmakers/lazyreview/ai"
+	"github.com/michalopenmakers/lazyreview/config"
+	"github.com/michalopenmakers/lazyreview/state"
+)
+
+func TestMain(m *testing.M) {
+	// Stan recenzji trafia do katalogu tymczasowego zamiast ~/.lazyreview_state.json
+	home, err := os.MkdirTemp("", "lazyreview-test")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("HOME", home)
+	code := m.Run()
+	state.Close()
+	os.RemoveAll(home)
+	os.Exit(code)
+}
+
+// fakeProvider records the reviewed diffs and answers with one finding.
+type fakeProvider struct {
+	mu      sync.Mutex
+	changes []string
+}
+
+var fake = &fakeProvider{}
+
+func init() {
+	ai.Register("fake", func(cfg *config.Config) ai.ReviewProvider { return fake })
+}
+
+func (p *fakeProvider) Name() string { return "fake" }
+
+func (p *fakeProvider) Review(ctx context.Context, req ai.ReviewRequest) (*ai.ReviewResult, error) {
+	p.mu.Lock()
+	p.changes = append(p.changes, req.Changes)
+	p.mu.Unlock()
+	findings := []ai.Finding{{File: "main.go", StartLine: 1, Severity: "low", Category: "style", Message: "Reviewed"}}
+	return &ai.ReviewResult{Text: ai.FormatReview("Done.", findings), Summary: "Done.", Findings: findings}, nil
+}
+
+func (p *fakeProvider) last() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.changes) == 0 {
+		return ""
+	}
+	return p.changes[len(p.changes)-1]
+}
+
+// initRepo creates a working copy with a commit on main and the feature
+// branch checked out.
+func initRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com",
+			"GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1",
+		)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run("init", "--quiet", "--initial-branch=main")
+	write("main.go", "package main\n\nfunc main() {}\n")
+	run("add", "-A")
+	run("commit", "--quiet", "-m", "Initial commit")
+	run("checkout", "--quiet", "-b", "feature")
+	write("main.go", "package main\n\nfunc main() {\n\tprintln(\"feature\")\n}\n")
+	run("add", "-A")
+	run("commit", "--quiet", "-m", "Print on start")
+	return dir
+}
+
+func localConfig() *config.Config {
+	return &config.Config{AIModelConfig: config.AIModelConfig{Provider: "fake"}}
+}
+
+func TestReviewLocal(t *testing.T) {
+	dir := initRepo(t)
+	ctx := context.Background()
+
+	r, err := ReviewLocal(ctx, localConfig(), LocalOptions{Dir: dir, Base: "main"})
+	if err != nil {
+		t.Fatalf("ReviewLocal: %v", err)
+	}
+	if !strings.Contains(fake.last(), "+\tprintln(\"feature\")") {
+		t.Errorf("reviewed diff = %q, want the feature commit", fake.last())
+	}
+	if r.Source != "local" || !strings.HasSuffix(r.Title, ": main...feature") || r.Summary != "Done." || len(r.Findings) != 1 {
+		t.Errorf("review = %+v", r)
+	}
+
+	// Bez staged zmian nie ma czego recenzować
+	if _, err := ReviewLocal(ctx, localConfig(), LocalOptions{Dir: dir, Staged: true}); !errors.Is(err, ErrNoChanges) {
+		t.Errorf("ReviewLocal(staged) error = %v, want ErrNoChanges", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {\n\tprintln(\"staged\")\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("git", "-C", dir, "add", "main.go")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git add: %v\n%s", err, out)
+	}
+	r, err = ReviewLocal(ctx, localConfig(), LocalOptions{Dir: dir, Staged: true})
+	if err != nil {
+		t.Fatalf("ReviewLocal(staged): %v", err)
+	}
+	if diff := fake.last(); !strings.Contains(diff, "-\tprintln(\"feature\")") || !strings.Contains(diff, "+\tprintln(\"staged\")") {
+		t.Errorf("reviewed diff = %q, want the staged change", diff)
+	}
+	if !strings.HasSuffix(r.Title, ": staged changes") {
+		t.Errorf("title = %q", r.Title)
+	}
+}
+
+func TestReviewLocalNoChanges(t *testing.T) {
+	dir := initRepo(t)
+	if _, err := ReviewLocal(context.Background(), localConfig(), LocalOptions{Dir: dir, Base: "feature"}); !errors.Is(err, ErrNoChanges) {
+		t.Errorf("ReviewLocal error = %v, want ErrNoChanges", err)
+	}
+}
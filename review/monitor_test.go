@@ -0,0 +1,36 @@
+package review
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/michalopenmakers/lazyreview/config"
+	"github.com/michalopenmakers/lazyreview/queue"
+)
+
+func TestStopMonitoringAndWait(t *testing.T) {
+	StartMonitoring(&config.Config{ReviewWorkers: 1})
+	started := make(chan struct{})
+	var finished atomic.Bool
+	activeQueue().Submit(queue.Job{ID: "slow", Group: "gitlab", Run: func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		// Recenzja zapisuje stan jeszcze po anulowaniu
+		time.Sleep(50 * time.Millisecond)
+		finished.Store(true)
+	}})
+	<-started
+
+	StopMonitoringAndWait()
+	if !finished.Load() {
+		t.Error("StopMonitoringAndWait returned before the running review finished")
+	}
+	if activeQueue() != nil {
+		t.Error("the queue is still active after stopping")
+	}
+	// Ponowne zatrzymanie nic nie robi
+	StopMonitoringAndWait()
+	StopMonitoring()
+}
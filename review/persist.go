@@ -0,0 +1,124 @@
+package review
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/michalopenmakers/lazyreview/logger"
+	"github.com/michalopenmakers/lazyreview/state"
+)
+
+func toRecord(r CodeReview) state.ReviewRecord {
+	return state.ReviewRecord{
+		ID:         r.ID,
+		Title:      r.Title,
+		URL:        r.URL,
+		LastCommit: r.LastCommit,
+		BaseCommit: r.BaseCommit,
+		ReviewedAt: r.ReviewedAt,
+		Source:     r.Source,
+		ProjectID:  r.ProjectID,
+		MergeReqID: r.MergeReqID,
+		Repository: r.Repository,
+		PullReqID:  r.PullReqID,
+		ReviewText: r.ReviewText,
+		Summary:    r.Summary,
+		Findings:   r.Findings,
+		Skipped:    r.Skipped,
+		Edited:     r.Edited,
+		Verdict:    string(r.Verdict),
+		Accepted:   r.Accepted,
+		Commented:  r.Commented,
+	}
+}
+
+func fromRecord(record state.ReviewRecord) CodeReview {
+	return CodeReview{
+		ID:         record.ID,
+		Title:      record.Title,
+		URL:        record.URL,
+		LastCommit: record.LastCommit,
+		BaseCommit: record.BaseCommit,
+		ReviewedAt: record.ReviewedAt,
+		Source:     record.Source,
+		ProjectID:  record.ProjectID,
+		MergeReqID: record.MergeReqID,
+		Repository: record.Repository,
+		PullReqID:  record.PullReqID,
+		ReviewText: record.ReviewText,
+		Summary:    record.Summary,
+		Findings:   record.Findings,
+		Skipped:    record.Skipped,
+		Edited:     record.Edited,
+		Verdict:    Verdict(record.Verdict),
+		Accepted:   record.Accepted,
+		Commented:  record.Commented,
+	}
+}
+
+// LoadReviews restores the reviews persisted in the application state.
+// Reviews already present in memory are kept.
+func LoadReviews() {
+	records := state.GetReviews()
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].ReviewedAt.Before(records[j].ReviewedAt)
+	})
+
+	reviewsMutex.Lock()
+	defer reviewsMutex.Unlock()
+	loaded := 0
+	for _, record := range records {
+		if record.ReviewText == "" {
+			// Recenzja przerwana przed zakończeniem zostanie wygenerowana ponownie
+			continue
+		}
+		exists := false
+		for _, r := range reviews {
+			if r.ID == record.ID {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			reviews = append(reviews, fromRecord(record))
+			loaded++
+		}
+	}
+	logger.Log(fmt.Sprintf("Loaded %d persisted reviews", loaded))
+}
+
+// saveReview persists the current in-memory version of the review.
+func saveReview(reviewID string) {
+	reviewsMutex.Lock()
+	var record *state.ReviewRecord
+	for _, r := range reviews {
+		if r.ID == reviewID {
+			rec := toRecord(r)
+			record = &rec
+			break
+		}
+	}
+	reviewsMutex.Unlock()
+	if record != nil {
+		state.SaveReview(*record)
+	}
+}
+
+// UpdateReviewText stores a review text edited by the user.
+func UpdateReviewText(reviewID, text string) {
+	reviewsMutex.Lock()
+	changed := false
+	for i := range reviews {
+		if reviews[i].ID == reviewID && reviews[i].ReviewText != text {
+			reviews[i].ReviewText = text
+			reviews[i].Edited = true
+			changed = true
+			break
+		}
+	}
+	reviewsMutex.Unlock()
+	if changed {
+		saveReview(reviewID)
+		logger.Log(fmt.Sprintf("Review %s edited", reviewID))
+	}
+}
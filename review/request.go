@@ -0,0 +1,168 @@
+package review
+
+import (
+	"context"
+	"fmt"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/michalopenmakers/lazyreview/config"
+	"github.com/michalopenmakers/lazyreview/filter"
+	"github.com/michalopenmakers/lazyreview/github"
+	"github.com/michalopenmakers/lazyreview/gitlab"
+	"github.com/michalopenmakers/lazyreview/state"
+)
+
+func mergeRequestReviewID(projectID string, mrID int) string {
+	return fmt.Sprintf("gitlab-%s-%d", projectID, mrID)
+}
+
+func pullRequestReviewID(repository string, prID int) string {
+	return fmt.Sprintf("github-%s-%d", repository, prID)
+}
+
+func registerMergeRequest(reviewID, projectID string, mr gitlab.MergeRequest) bool {
+	return registerReview(CodeReview{
+		ID:         reviewID,
+		Title:      mr.Title,
+		URL:        mr.WebURL,
+		Source:     "gitlab",
+		ProjectID:  projectID,
+		MergeReqID: mr.IID,
+	})
+}
+
+func registerPullRequest(reviewID string, pr github.PullRequest) bool {
+	return registerReview(CodeReview{
+		ID:         reviewID,
+		Title:      pr.Title,
+		URL:        pr.HTMLURL,
+		Source:     "github",
+		Repository: pr.Repository,
+		PullReqID:  pr.Number,
+	})
+}
+
+// reviewMergeRequest generates the review of a registered merge request at
+// commit.
+func reviewMergeRequest(ctx context.Context, cfg *config.Config, reviewID, projectID string, mrID int, commit string) error {
+	fetch := func(ctx context.Context) (reviewInput, error) {
+		compare := func(ctx context.Context, base string) (string, []filter.Skipped, error) {
+			return gitlab.GetCompareChanges(ctx, cfg, projectID, base, commit)
+		}
+		full := func(ctx context.Context) (string, []filter.Skipped, error) {
+			return gitlab.GetMergeRequestChanges(ctx, cfg, projectID, mrID)
+		}
+		file := func(ctx context.Context, filePath string) (string, error) {
+			return gitlab.GetFileContent(ctx, cfg, projectID, filePath, commit)
+		}
+		return fetchInput(ctx, cfg, reviewID, commit, compare, full, file)
+	}
+	if err := processReview(ctx, cfg, reviewID, commit, fetch); err != nil {
+		return err
+	}
+	state.UpdateGitLabProjectState(projectID, commit, time.Now().Unix())
+	return nil
+}
+
+// reviewPullRequest generates the review of a registered pull request at
+// commit.
+func reviewPullRequest(ctx context.Context, cfg *config.Config, reviewID, repository string, prID int, commit string) error {
+	fetch := func(ctx context.Context) (reviewInput, error) {
+		compare := func(ctx context.Context, base string) (string, []filter.Skipped, error) {
+			return github.GetCompareChanges(ctx, cfg, repository, base, commit)
+		}
+		full := func(ctx context.Context) (string, []filter.Skipped, error) {
+			return github.GetPullRequestChanges(ctx, cfg, repository, prID, commit)
+		}
+		file := func(ctx context.Context, filePath string) (string, error) {
+			return github.GetFileContent(ctx, cfg, repository, filePath, commit)
+		}
+		return fetchInput(ctx, cfg, reviewID, commit, compare, full, file)
+	}
+	if err := processReview(ctx, cfg, reviewID, commit, fetch); err != nil {
+		return err
+	}
+	state.UpdateGitHubRepoState(repository, commit, time.Now().Unix())
+	return nil
+}
+
+// ReviewMergeRequest reviews one GitLab merge request right away, even when
+// its head commit was reviewed before. projectID may be the numeric ID or
+// the path of the project.
+func ReviewMergeRequest(ctx context.Context, cfg *config.Config, projectID string, mrID int) (CodeReview, error) {
+	mr, err := gitlab.GetMergeRequest(ctx, cfg, neturl.PathEscape(projectID), mrID)
+	if err != nil {
+		return CodeReview{}, err
+	}
+	commit := mr.HeadSHA()
+	if commit == "" {
+		return CodeReview{}, fmt.Errorf("no head commit found for merge request")
+	}
+	// Identyfikator recenzji zawsze opiera się na numerycznym ID projektu
+	projectID = strconv.Itoa(mr.ProjectID)
+	reviewID := mergeRequestReviewID(projectID, mr.IID)
+	registerMergeRequest(reviewID, projectID, mr)
+	if err := reviewMergeRequest(ctx, cfg, reviewID, projectID, mr.IID, commit); err != nil {
+		return CodeReview{}, err
+	}
+	return GetReview(reviewID)
+}
+
+// ReviewPullRequest reviews one GitHub pull request right away, even when
+// its head commit was reviewed before.
+func ReviewPullRequest(ctx context.Context, cfg *config.Config, repository string, prID int) (CodeReview, error) {
+	pr, err := github.GetPullRequest(ctx, cfg, repository, prID)
+	if err != nil {
+		return CodeReview{}, err
+	}
+	if pr.HeadSHA == "" {
+		return CodeReview{}, fmt.Errorf("no head commit found for pull request")
+	}
+	reviewID := pullRequestReviewID(repository, prID)
+	registerPullRequest(reviewID, pr)
+	if err := reviewPullRequest(ctx, cfg, reviewID, repository, prID, pr.HeadSHA); err != nil {
+		return CodeReview{}, err
+	}
+	return GetReview(reviewID)
+}
+
+// ReviewURL reviews the merge or pull request at the given web URL, e.g.
+// https://gitlab.com/group/project/-/merge_requests/12 or
+// https://github.com/owner/repo/pull/34.
+func ReviewURL(ctx context.Context, cfg *config.Config, rawURL string) (CodeReview, error) {
+	source, project, number, err := ParseURL(rawURL)
+	if err != nil {
+		return CodeReview{}, err
+	}
+	if source == "gitlab" {
+		return ReviewMergeRequest(ctx, cfg, project, number)
+	}
+	return ReviewPullRequest(ctx, cfg, project, number)
+}
+
+// ParseURL splits the web URL of a merge or pull request into its source
+// ("gitlab" or "github"), project path or repository, and number.
+func ParseURL(rawURL string) (string, string, int, error) {
+	u, err := neturl.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", "", 0, err
+	}
+	path := strings.Trim(u.Path, "/")
+	if project, rest, ok := strings.Cut(path, "/-/merge_requests/"); ok {
+		number, err := strconv.Atoi(strings.SplitN(rest, "/", 2)[0])
+		if err == nil && project != "" {
+			return "gitlab", project, number, nil
+		}
+	}
+	parts := strings.Split(path, "/")
+	if len(parts) >= 4 && parts[2] == "pull" {
+		number, err := strconv.Atoi(parts[3])
+		if err == nil {
+			return "github", parts[0] + "/" + parts[1], number, nil
+		}
+	}
+	return "", "", 0, fmt.Errorf("%s is not a GitLab merge request or GitHub pull request URL", rawURL)
+}
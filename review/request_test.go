@@ -0,0 +1,36 @@
+package review
+
+import "testing"
+
+func TestParseURL(t *testing.T) {
+	tests := []struct {
+		url         string
+		wantSource  string
+		wantProject string
+		wantNumber  int
+		wantErr     bool
+	}{
+		{url: "https://gitlab.com/group/project/-/merge_requests/12", wantSource: "gitlab", wantProject: "group/project", wantNumber: 12},
+		{url: "https://gitlab.example.com/group/sub/project/-/merge_requests/3/diffs?commit_id=abc", wantSource: "gitlab", wantProject: "group/sub/project", wantNumber: 3},
+		{url: " https://gitlab.com/group/project/-/merge_requests/7#note_1 ", wantSource: "gitlab", wantProject: "group/project", wantNumber: 7},
+		{url: "https://github.com/owner/repo/pull/42", wantSource: "github", wantProject: "owner/repo", wantNumber: 42},
+		{url: "https://github.com/owner/repo/pull/42/files", wantSource: "github", wantProject: "owner/repo", wantNumber: 42},
+		{url: "https://github.com/owner/repo/issues/42", wantErr: true},
+		{url: "https://github.com/owner/repo/pull/abc", wantErr: true},
+		{url: "https://gitlab.com/-/merge_requests/12", wantErr: true},
+		{url: "https://gitlab.com/group/project/-/issues/12", wantErr: true},
+		{url: "not a url", wantErr: true},
+		{url: "://", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			source, project, number, err := ParseURL(tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseURL() error = %v, want error %v", err, tt.wantErr)
+			}
+			if source != tt.wantSource || project != tt.wantProject || number != tt.wantNumber {
+				t.Errorf("ParseURL() = %q, %q, %d; want %q, %q, %d", source, project, number, tt.wantSource, tt.wantProject, tt.wantNumber)
+			}
+		})
+	}
+}
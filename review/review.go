@@ -1,26 +1,43 @@
 package review
 
 import (
+	"context"
 	"fmt"
+	"github.com/michalopenmakers/lazyreview/ai"
 	"github.com/michalopenmakers/lazyreview/config"
+	"github.com/michalopenmakers/lazyreview/filter"
 	"github.com/michalopenmakers/lazyreview/github"
 	"github.com/michalopenmakers/lazyreview/gitlab"
+	"github.com/michalopenmakers/lazyreview/httpclient"
 	"github.com/michalopenmakers/lazyreview/logger"
-	"github.com/michalopenmakers/lazyreview/openai"
+	"github.com/michalopenmakers/lazyreview/queue"
 	"github.com/michalopenmakers/lazyreview/state"
+	"strings"
 	"sync"
 	"time"
 )
 
-var stopChan = make(chan struct{})
+var (
+	monitorMutex     sync.Mutex
+	cancelMonitoring context.CancelFunc
+	reviewQueue      *queue.Queue
+)
+
+// noReviewableChanges replaces the AI review when the diff filters left
+// nothing to review.
+const noReviewableChanges = "No reviewable changes: all files were skipped by the diff filters."
+
 var reviewsMutex = &sync.Mutex{}
 var reviews []CodeReview
 
 type CodeReview struct {
-	ID           string
-	Title        string
-	URL          string
-	LastCommit   string
+	ID         string
+	Title      string
+	URL        string
+	LastCommit string
+	// BaseCommit is set when the current review covers only the commits
+	// pushed after BaseCommit.
+	BaseCommit   string
 	ReviewedAt   time.Time
 	Source       string
 	ProjectID    string
@@ -28,169 +45,136 @@ type CodeReview struct {
 	Repository   string
 	PullReqID    int
 	ReviewText   string
+	Summary      string
+	Findings     []ai.Finding
+	Skipped      []filter.Skipped
+	Edited       bool
+	Verdict      Verdict
 	IsInProgress bool
 	Accepted     bool
 	Commented    bool
 }
 
-func monitorMergeRequests(cfg *config.Config) {
+func monitorMergeRequests(ctx context.Context, cfg *config.Config, q *queue.Queue) {
 	if !cfg.GitLabConfig.Enabled {
 		logger.Log("GitLab integration is disabled, not monitoring MRs")
 		return
 	}
 
-	// Natychmiastowe sprawdzenie przy starcie, bez czekania na ticker
+	// Natychmiastowe sprawdzenie przy starcie, bez czekania na timer
 	logger.Log("Starting immediate GitLab merge requests check")
 	if cfg.GitLabConfig.ApiToken != "" {
-		checkGitLabMergeRequests(cfg)
+		if user, err := gitlab.GetCurrentUser(ctx, cfg); err != nil {
+			logger.Log(fmt.Sprintf("Error resolving GitLab user: %v", err))
+		} else {
+			logger.Log(fmt.Sprintf("Monitoring GitLab merge requests for %s", user.Username))
+		}
+		checkGitLabMergeRequests(ctx, cfg, q)
 	} else {
 		logger.Log("GitLab API token not configured")
 	}
 
-	ticker := time.NewTicker(time.Duration(cfg.MergeRequestsPollingInterval) * time.Second)
-	defer ticker.Stop()
+	// Odstęp między odpytaniami rośnie, gdy zbliżamy się do limitu API
+	interval := time.Duration(cfg.MergeRequestsPollingInterval) * time.Second
+	timer := time.NewTimer(httpclient.PollInterval("gitlab", interval))
+	defer timer.Stop()
 
 	for {
 		select {
-		case <-stopChan:
+		case <-ctx.Done():
 			logger.Log("Stopping merge request monitoring")
 			return
-		case <-ticker.C:
+		case <-timer.C:
 			logger.Log("Pulling new merge requests")
 			if cfg.GitLabConfig.ApiToken == "" {
 				logger.Log("GitLab API token not configured")
-				continue
+			} else {
+				checkGitLabMergeRequests(ctx, cfg, q)
 			}
-			checkGitLabMergeRequests(cfg)
+			timer.Reset(httpclient.PollInterval("gitlab", interval))
 		}
 	}
 }
 
-func checkGitLabMergeRequests(cfg *config.Config) {
-	mergeRequests, err := gitlab.GetMergeRequestsToReview(cfg)
+func checkGitLabMergeRequests(ctx context.Context, cfg *config.Config, q *queue.Queue) {
+	var mergeRequests []gitlab.MergeRequest
+	var err error
+	if cfg.GitLabConfig.WatchListMode {
+		mergeRequests, err = gitlab.GetWatchedMergeRequests(ctx, cfg)
+	} else {
+		mergeRequests, err = gitlab.GetMergeRequestsToReview(ctx, cfg)
+	}
 	if err != nil {
 		logger.Log(fmt.Sprintf("Error fetching merge requests: %v", err))
 		return
 	}
 	for _, mr := range mergeRequests {
-		projectID := fmt.Sprintf("%d", mr.ProjectID)
-
-		// Od razu sprawdzamy, czy merge request został skomentowany
-		hasMyComment, err := gitlab.HasMyComment(cfg, projectID, mr.IID)
-		if err != nil {
-			logger.Log(fmt.Sprintf("Error checking if MR #%d has my comment: %v", mr.IID, err))
+		if ctx.Err() != nil {
+			return
 		}
+		queueMergeRequest(ctx, cfg, q, mr)
+	}
+}
 
-		hasReply := false
+// queueMergeRequest submits the review of the merge request unless its
+// current commit was already reviewed or it awaits a reply to our comment.
+func queueMergeRequest(ctx context.Context, cfg *config.Config, q *queue.Queue, mr gitlab.MergeRequest) {
+	projectID := fmt.Sprintf("%d", mr.ProjectID)
 
-		if hasMyComment {
-			hasReply, err = gitlab.HasReplyOnMyComment(cfg, projectID, mr.IID)
-			if err != nil {
-				logger.Log(fmt.Sprintf("Error checking for replies on MR #%d: %v", mr.IID, err))
-			}
-			if !hasReply {
-				logger.Log(fmt.Sprintf("MR #%d already has my comment with no reply, skipping", mr.IID))
-				continue // Przejdź do następnego MR, bez przetwarzania tego
-			}
-			logger.Log(fmt.Sprintf("MR #%d has a reply to my comment, will process", mr.IID))
+	// Od razu sprawdzamy, czy merge request został skomentowany
+	hasMyComment, err := gitlab.HasMyComment(ctx, cfg, projectID, mr.IID)
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error checking if MR #%d has my comment: %v", mr.IID, err))
+	}
+
+	if hasMyComment {
+		hasReply, err := gitlab.HasReplyOnMyComment(ctx, cfg, projectID, mr.IID)
+		if err != nil {
+			logger.Log(fmt.Sprintf("Error checking for replies on MR #%d: %v", mr.IID, err))
 		}
+		if !hasReply {
+			logger.Log(fmt.Sprintf("MR #%d already has my comment with no reply, skipping", mr.IID))
+			return
+		}
+		logger.Log(fmt.Sprintf("MR #%d has a reply to my comment, will process", mr.IID))
+	}
 
-		exists := false
-		reviewsMutex.Lock()
-		for i, review := range reviews {
-			if review.Source == "gitlab" && review.ProjectID == projectID && review.MergeReqID == mr.IID {
-				exists = true
-				currentCommit, err := gitlab.GetCurrentCommit(cfg, projectID, mr.IID)
-				if err != nil {
-					logger.Log(fmt.Sprintf("Error getting current commit: %v", err))
-					break
-				}
-
-				if currentCommit == review.LastCommit && hasMyComment && !hasReply {
-					logger.Log(fmt.Sprintf("MR #%d: same commit and already commented with no reply, skipping", mr.IID))
-					reviewsMutex.Unlock()
-					goto nextMR
-				} else if currentCommit != review.LastCommit && !review.IsInProgress {
-					logger.Log(fmt.Sprintf("New commit detected for MR #%d, generating review", mr.IID))
-					reviews[i].IsInProgress = true
-					reviewsMutex.Unlock()
-					changes, err := gitlab.GetMergeRequestChanges(cfg, projectID, mr.IID)
-					if err != nil {
-						logger.Log(fmt.Sprintf("Error getting changes: %v", err))
-						markReviewNotInProgress(review.ID)
-						goto nextMR
-					}
-					reviewText, err := openai.CodeReview(cfg, changes, false)
-					if err != nil {
-						logger.Log(fmt.Sprintf("Error generating review: %v", err))
-						markReviewNotInProgress(review.ID)
-						goto nextMR
-					}
-					reviewsMutex.Lock()
-					reviews[i].LastCommit = currentCommit
-					reviews[i].ReviewText = reviewText
-					reviews[i].ReviewedAt = time.Now()
-					reviews[i].IsInProgress = false
-					reviews[i].Commented = false
-					reviewsMutex.Unlock()
-					state.UpdateGitLabProjectState(projectID, currentCommit, time.Now().Unix())
-					logger.Log(fmt.Sprintf("Updated review for MR #%d", mr.IID))
-				} else {
-					reviewsMutex.Unlock()
-				}
-				break
-			}
+	currentCommit := mr.HeadSHA()
+	if currentCommit == "" {
+		currentCommit, err = gitlab.GetCurrentCommit(ctx, cfg, projectID, mr.IID)
+		if err != nil {
+			logger.Log(fmt.Sprintf("Error getting current commit: %v", err))
+			return
 		}
-		if !exists {
-			currentCommit, err := gitlab.GetCurrentCommit(cfg, projectID, mr.IID)
-			if err != nil {
-				logger.Log(fmt.Sprintf("Error getting current commit: %v", err))
-				reviewsMutex.Unlock()
-				goto nextMR
-			}
-			changes, err := gitlab.GetMergeRequestChanges(cfg, projectID, mr.IID)
-			if err != nil {
-				logger.Log(fmt.Sprintf("Error getting initial changes: %v", err))
-				reviewsMutex.Unlock()
-				goto nextMR
-			}
-			newReview := CodeReview{
-				ID:           fmt.Sprintf("gitlab-%s-%d", projectID, mr.IID),
-				Title:        mr.Title,
-				URL:          mr.WebURL,
-				LastCommit:   currentCommit,
-				ReviewedAt:   time.Now(),
-				Source:       "gitlab",
-				ProjectID:    projectID,
-				MergeReqID:   mr.IID,
-				IsInProgress: true,
-				Commented:    false,
-			}
-			reviews = append(reviews, newReview)
-			reviewsMutex.Unlock()
-			reviewText, err := openai.CodeReview(cfg, changes, false)
-			if err != nil {
-				logger.Log(fmt.Sprintf("Error generating review: %v", err))
-				markReviewNotInProgress(newReview.ID)
-				goto nextMR
+	}
+
+	reviewID := mergeRequestReviewID(projectID, mr.IID)
+	if !needsReview(reviewID, currentCommit) {
+		return
+	}
+	isNew := registerMergeRequest(reviewID, projectID, mr)
+	mrID := mr.IID
+	submitted := q.Submit(queue.Job{
+		ID:      reviewID,
+		Version: currentCommit,
+		Group:   "gitlab",
+		Run: func(ctx context.Context) {
+			if reviewMergeRequest(ctx, cfg, reviewID, projectID, mrID, currentCommit) != nil {
+				return
 			}
-			reviewsMutex.Lock()
-			for i := range reviews {
-				if reviews[i].ID == newReview.ID {
-					reviews[i].ReviewText = reviewText
-					reviews[i].IsInProgress = false
-				}
+			if isNew {
+				logger.Log(fmt.Sprintf("Added new review for MR #%d", mrID))
+			} else {
+				logger.Log(fmt.Sprintf("Updated review for MR #%d", mrID))
 			}
-			reviewsMutex.Unlock()
-			state.UpdateGitLabProjectState(projectID, currentCommit, time.Now().Unix())
-			logger.Log(fmt.Sprintf("Added new review for MR #%d", mr.IID))
-		}
-	nextMR:
-		continue
+		},
+	})
+	if submitted && !isNew {
+		logger.Log(fmt.Sprintf("New commit detected for MR #%d, generating review", mr.IID))
 	}
 }
 
-func monitorReviewRequests(cfg *config.Config) {
+func monitorReviewRequests(ctx context.Context, cfg *config.Config, q *queue.Queue) {
 	if !cfg.GitHubConfig.Enabled {
 		logger.Log("GitHub integration is disabled, not monitoring PRs")
 		return
@@ -198,180 +182,334 @@ func monitorReviewRequests(cfg *config.Config) {
 
 	logger.Log("Starting immediate GitHub pull requests check")
 	if cfg.GitHubConfig.ApiToken != "" {
-		checkGitHubPullRequests(cfg)
+		checkGitHubPullRequests(ctx, cfg, q)
 	} else {
 		logger.Log("GitHub API token not configured")
 	}
 
-	ticker := time.NewTicker(time.Duration(cfg.ReviewRequestsPollingInterval) * time.Second)
-	defer ticker.Stop()
+	// Odstęp między odpytaniami rośnie, gdy zbliżamy się do limitu API
+	interval := time.Duration(cfg.ReviewRequestsPollingInterval) * time.Second
+	timer := time.NewTimer(httpclient.PollInterval("github", interval))
+	defer timer.Stop()
 
 	for {
 		select {
-		case <-stopChan:
+		case <-ctx.Done():
 			logger.Log("Stopping pull request monitoring")
 			return
-		case <-ticker.C:
+		case <-timer.C:
 			logger.Log("Pulling new pull requests")
 			if cfg.GitHubConfig.ApiToken == "" {
 				logger.Log("GitHub API token not configured")
-				continue
+			} else {
+				checkGitHubPullRequests(ctx, cfg, q)
 			}
-			checkGitHubPullRequests(cfg)
+			timer.Reset(httpclient.PollInterval("github", interval))
 		}
 	}
 }
 
-func checkGitHubPullRequests(cfg *config.Config) {
-	pullRequests, err := github.GetPullRequestsToReview(cfg)
+func checkGitHubPullRequests(ctx context.Context, cfg *config.Config, q *queue.Queue) {
+	var pullRequests []github.PullRequest
+	var err error
+	if cfg.GitHubConfig.WatchListMode {
+		pullRequests, err = github.GetWatchedPullRequests(ctx, cfg)
+	} else {
+		pullRequests, err = github.GetPullRequestsToReview(ctx, cfg)
+	}
 	if err != nil {
 		logger.Log(fmt.Sprintf("Error fetching pull requests: %v", err))
 		return
 	}
 	for _, pr := range pullRequests {
-		exists := false
-		reviewsMutex.Lock()
-		for i, review := range reviews {
-			if review.Source == "github" && review.Repository == pr.Repository && review.PullReqID == pr.Number {
-				exists = true
-				currentCommit, err := github.GetCurrentCommit(cfg, pr.Repository, pr.Number)
-				if err != nil {
-					logger.Log(fmt.Sprintf("Error getting current commit: %v", err))
-					break
-				}
-				if currentCommit != review.LastCommit && !review.IsInProgress {
-					logger.Log(fmt.Sprintf("New commit detected for PR #%d in %s, generating review", pr.Number, pr.Repository))
-					reviews[i].IsInProgress = true
-					reviewsMutex.Unlock()
-					changes, err := github.GetPullRequestChanges(cfg, pr.Repository, pr.Number)
-					if err != nil {
-						logger.Log(fmt.Sprintf("Error getting changes: %v", err))
-						markReviewNotInProgress(review.ID)
-						break
-					}
-					reviewText, err := openai.CodeReview(cfg, changes, false)
-					if err != nil {
-						logger.Log(fmt.Sprintf("Error generating review: %v", err))
-						markReviewNotInProgress(review.ID)
-						break
-					}
-					reviewsMutex.Lock()
-					reviews[i].LastCommit = currentCommit
-					reviews[i].ReviewText = reviewText
-					reviews[i].ReviewedAt = time.Now()
-					reviews[i].IsInProgress = false
-					reviewsMutex.Unlock()
-					state.UpdateGitHubRepoState(pr.Repository, currentCommit, time.Now().Unix())
-					logger.Log(fmt.Sprintf("Updated review for PR #%d in %s", pr.Number, pr.Repository))
-				} else {
-					reviewsMutex.Unlock()
-				}
-				break
-			}
+		if ctx.Err() != nil {
+			return
 		}
-		if !exists {
-			currentCommit, err := github.GetCurrentCommit(cfg, pr.Repository, pr.Number)
-			if err != nil {
-				logger.Log(fmt.Sprintf("Error getting current commit: %v", err))
-				reviewsMutex.Unlock()
-				continue
-			}
-			changes, err := github.GetPullRequestChanges(cfg, pr.Repository, pr.Number)
-			if err != nil {
-				logger.Log(fmt.Sprintf("Error getting initial changes: %v", err))
-				reviewsMutex.Unlock()
-				continue
-			}
-			newReview := CodeReview{
-				ID:           fmt.Sprintf("github-%s-%d", pr.Repository, pr.Number),
-				Title:        pr.Title,
-				URL:          pr.HTMLURL,
-				LastCommit:   currentCommit,
-				ReviewedAt:   time.Now(),
-				Source:       "github",
-				Repository:   pr.Repository,
-				PullReqID:    pr.Number,
-				IsInProgress: true,
-			}
-			reviews = append(reviews, newReview)
-			reviewsMutex.Unlock()
-			reviewText, err := openai.CodeReview(cfg, changes, false)
-			if err != nil {
-				logger.Log(fmt.Sprintf("Error generating review: %v", err))
-				markReviewNotInProgress(newReview.ID)
-				continue
+		queuePullRequest(ctx, cfg, q, pr)
+	}
+}
+
+// queuePullRequest submits the review of the pull request unless its
+// current commit was already reviewed.
+func queuePullRequest(ctx context.Context, cfg *config.Config, q *queue.Queue, pr github.PullRequest) {
+	currentCommit := pr.HeadSHA
+	if currentCommit == "" {
+		var err error
+		currentCommit, err = github.GetCurrentCommit(ctx, cfg, pr.Repository, pr.Number)
+		if err != nil {
+			logger.Log(fmt.Sprintf("Error getting current commit: %v", err))
+			return
+		}
+	}
+
+	reviewID := pullRequestReviewID(pr.Repository, pr.Number)
+	if !needsReview(reviewID, currentCommit) {
+		return
+	}
+	isNew := registerPullRequest(reviewID, pr)
+	repository, prID := pr.Repository, pr.Number
+	submitted := q.Submit(queue.Job{
+		ID:      reviewID,
+		Version: currentCommit,
+		Group:   "github",
+		Run: func(ctx context.Context) {
+			if reviewPullRequest(ctx, cfg, reviewID, repository, prID, currentCommit) != nil {
+				return
 			}
-			reviewsMutex.Lock()
-			for i := range reviews {
-				if reviews[i].ID == newReview.ID {
-					reviews[i].ReviewText = reviewText
-					reviews[i].IsInProgress = false
-				}
+			if isNew {
+				logger.Log(fmt.Sprintf("Added new review for PR #%d in %s", prID, repository))
+			} else {
+				logger.Log(fmt.Sprintf("Updated review for PR #%d in %s", prID, repository))
 			}
-			reviewsMutex.Unlock()
-			state.UpdateGitHubRepoState(pr.Repository, currentCommit, time.Now().Unix())
-			logger.Log(fmt.Sprintf("Added new review for PR #%d in %s", pr.Number, pr.Repository))
-		} else {
-			reviewsMutex.Unlock()
-		}
+		},
+	})
+	if submitted && !isNew {
+		logger.Log(fmt.Sprintf("New commit detected for PR #%d in %s, generating review", pr.Number, pr.Repository))
 	}
 }
 
-func markReviewNotInProgress(reviewID string) {
+// needsReview reports whether the review is missing or was generated for
+// a different commit.
+func needsReview(reviewID, commit string) bool {
 	reviewsMutex.Lock()
 	defer reviewsMutex.Unlock()
-	for i, r := range reviews {
+	for _, r := range reviews {
 		if r.ID == reviewID {
-			reviews[i].IsInProgress = false
+			return r.LastCommit != commit
+		}
+	}
+	return true
+}
+
+// registerReview adds the review to the list if it is not there yet and
+// returns true in that case. Existing reviews get their title refreshed.
+func registerReview(newReview CodeReview) bool {
+	reviewsMutex.Lock()
+	defer reviewsMutex.Unlock()
+	for i := range reviews {
+		if reviews[i].ID == newReview.ID {
+			reviews[i].Title = newReview.Title
+			reviews[i].URL = newReview.URL
+			return false
+		}
+	}
+	newReview.ReviewedAt = time.Now()
+	reviews = append(reviews, newReview)
+	return true
+}
+
+// processReview fetches the changes and generates the review for the given
+// commit. Results of a cancelled job are discarded, since a newer commit or
+// a restart has made them stale.
+func processReview(ctx context.Context, cfg *config.Config, reviewID, commit string, fetch func(ctx context.Context) (reviewInput, error)) error {
+	setReviewInProgress(reviewID, true)
+	defer setReviewInProgress(reviewID, false)
+
+	input, err := fetch(ctx)
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error getting changes: %v", err))
+		return err
+	}
+	if ctx.Err() != nil {
+		logger.Log(fmt.Sprintf("Review of %s at %s cancelled", reviewID, commit))
+		return ctx.Err()
+	}
+	result, err := generateReview(ctx, cfg, reviewID, commit, input)
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error generating review: %v", err))
+		return err
+	}
+	if ctx.Err() != nil {
+		logger.Log(fmt.Sprintf("Discarding stale review of %s at %s", reviewID, commit))
+		return ctx.Err()
+	}
+
+	reviewsMutex.Lock()
+	for i := range reviews {
+		if reviews[i].ID == reviewID {
+			reviews[i].LastCommit = commit
+			reviews[i].BaseCommit = input.BaseCommit
+			reviews[i].ReviewText = result.Text
+			reviews[i].Findings = result.Findings
+			reviews[i].Summary = result.Summary
+			reviews[i].Skipped = input.Skipped
+			reviews[i].Edited = false
+			reviews[i].ReviewedAt = time.Now()
+			reviews[i].Commented = false
 			break
 		}
 	}
+	reviewsMutex.Unlock()
+	saveReview(reviewID)
+	return nil
 }
 
-func AcceptReview(reviewID string) {
+func generateReview(ctx context.Context, cfg *config.Config, reviewID, commit string, input reviewInput) (*ai.ReviewResult, error) {
+	provider, err := ai.NewProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var result *ai.ReviewResult
+	if strings.TrimSpace(input.Changes) == "" && len(input.Skipped) > 0 {
+		// Wszystkie pliki odfiltrowane, nie ma czego wysyłać do AI
+		result = &ai.ReviewResult{Text: noReviewableChanges}
+	} else {
+		input.ChunkTokens = ai.ChunkBudget(cfg.AIModelConfig)
+		result, err = provider.Review(ctx, input.ReviewRequest)
+		if err != nil {
+			return nil, err
+		}
+	}
+	state.AddReviewRun(state.ReviewRun{
+		ReviewID:   reviewID,
+		Commit:     commit,
+		BaseCommit: input.BaseCommit,
+		Provider:   provider.Name(),
+		CreatedAt:  time.Now(),
+		Summary:    result.Summary,
+		Text:       result.Text,
+		Findings:   result.Findings,
+	})
+	return result, nil
+}
+
+func setReviewInProgress(reviewID string, inProgress bool) {
 	reviewsMutex.Lock()
 	defer reviewsMutex.Unlock()
 	for i, r := range reviews {
 		if r.ID == reviewID {
+			reviews[i].IsInProgress = inProgress
+			break
+		}
+	}
+}
+
+// SubmitReview posts the review to its forge using the chosen verdict. The
+// review is marked accepted only when posting succeeded, so a failed
+// submission can be retried.
+func SubmitReview(ctx context.Context, cfg *config.Config, reviewID string, verdict Verdict) error {
+	// Wysyłamy kopię, żeby nie blokować listy recenzji na czas zapytań do API
+	r, err := GetReview(reviewID)
+	if err != nil {
+		return err
+	}
+	if r.Source != "gitlab" && r.Source != "github" {
+		return fmt.Errorf("review %s cannot be submitted: %s reviews have no forge", reviewID, r.Source)
+	}
+	logger.Log(fmt.Sprintf("Submitting review (%s): %s", verdict.Label(), r.Title))
+	if r.Source == "gitlab" {
+		// Recenzja edytowana ręcznie trafia w całości jako jeden komentarz
+		if len(r.Findings) > 0 && !r.Edited {
+			err = gitlab.PostMergeRequestFindings(ctx, cfg, r.ProjectID, r.MergeReqID, r.LastCommit, r.Summary, r.Findings)
+		} else {
+			err = gitlab.AcceptMergeRequestReview(ctx, cfg, r.ProjectID, r.MergeReqID, r.ReviewText)
+		}
+		if err == nil && verdict == VerdictApprove {
+			err = gitlab.ApproveMergeRequest(ctx, cfg, r.ProjectID, r.MergeReqID)
+		}
+	} else {
+		if len(r.Findings) > 0 && !r.Edited {
+			err = github.SubmitPullRequestReview(ctx, cfg, r.Repository, r.PullReqID, r.LastCommit, verdict.GitHubEvent(), r.Summary, r.Findings)
+		} else {
+			err = github.SubmitPullRequestReview(ctx, cfg, r.Repository, r.PullReqID, r.LastCommit, verdict.GitHubEvent(), r.ReviewText, nil)
+		}
+	}
+	recordPostEvent(r.ID, verdict, err)
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error submitting review in %s: %v", r.Source, err))
+		return err
+	}
+	if r.Source == "gitlab" {
+		// Zapisujemy w stanie, że MR został skomentowany
+		state.MarkGitLabProjectCommented(r.ProjectID)
+	}
+
+	reviewsMutex.Lock()
+	for i := range reviews {
+		if reviews[i].ID == reviewID {
 			reviews[i].Accepted = true
-			logger.Log(fmt.Sprintf("Review accepted: %s", r.Title))
-			if r.Source == "gitlab" {
-				cfg := config.LoadConfig()
-				if err := gitlab.AcceptMergeRequestReview(cfg, r.ProjectID, r.MergeReqID, r.ReviewText); err != nil {
-					logger.Log(fmt.Sprintf("Error accepting review in GitLab: %v", err))
-				} else {
-					reviews[i].Commented = true
-					// Zapisujemy w stanie, że MR został skomentowany
-					state.MarkGitLabProjectCommented(r.ProjectID)
-				}
-			} else if r.Source == "github" {
-				cfg := config.LoadConfig()
-				reviewMessage := "Review accepted: chore: add comment to EC2 example configuration\n" + r.ReviewText
-				err := github.AcceptPullRequest(cfg, r.Repository, r.PullReqID, reviewMessage)
-				if err != nil {
-					logger.Log(fmt.Sprintf("Error accepting review in GitHub: %v", err))
-				} else {
-					reviews[i].Commented = true
-					// (Jeśli chcesz, możesz też dodać funkcję stanu dla GitHub)
-				}
-			}
+			reviews[i].Verdict = verdict
+			reviews[i].Commented = true
 			break
 		}
 	}
+	reviewsMutex.Unlock()
+	saveReview(reviewID)
+	return nil
+}
+
+func recordPostEvent(reviewID string, verdict Verdict, err error) {
+	event := state.PostEvent{
+		ReviewID: reviewID,
+		Verdict:  string(verdict),
+		PostedAt: time.Now(),
+		Success:  err == nil,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	state.AddPostEvent(event)
 }
 
 func StartMonitoring(cfg *config.Config) {
-	stopChan = make(chan struct{})
-	go monitorMergeRequests(cfg)
-	go monitorReviewRequests(cfg)
+	monitorMutex.Lock()
+	defer monitorMutex.Unlock()
+	var ctx context.Context
+	ctx, cancelMonitoring = context.WithCancel(context.Background())
+	reviewQueue = queue.New(ctx, cfg.ReviewWorkers, map[string]int{
+		"gitlab": cfg.GitLabConfig.MaxConcurrentReviews,
+		"github": cfg.GitHubConfig.MaxConcurrentReviews,
+	})
+	reviewQueue.Start()
+	go monitorMergeRequests(ctx, cfg, reviewQueue)
+	go monitorReviewRequests(ctx, cfg, reviewQueue)
 }
 
+// StopMonitoring cancels the monitoring and returns without waiting for the
+// running reviews to finish, so the UI stays responsive.
 func StopMonitoring() {
-	close(stopChan)
+	if q := stopMonitoring(); q != nil {
+		// Zatrzymanie kolejki czeka na trwające zadania, więc nie blokujemy wywołującego
+		go q.Stop()
+	}
+}
+
+// StopMonitoringAndWait cancels the monitoring and waits until the running
+// reviews have finished, so the state can be closed afterwards.
+func StopMonitoringAndWait() {
+	if q := stopMonitoring(); q != nil {
+		q.Stop()
+	}
+}
+
+// stopMonitoring cancels the monitoring and returns its queue, nil when it
+// was not running.
+func stopMonitoring() *queue.Queue {
+	monitorMutex.Lock()
+	defer monitorMutex.Unlock()
+	if cancelMonitoring == nil {
+		return nil
+	}
+	// Anulowanie przerywa też trwające zapytania do API i modelu AI
+	cancelMonitoring()
+	cancelMonitoring = nil
+	q := reviewQueue
+	reviewQueue = nil
+	return q
+}
+
+// activeQueue returns the queue of the running monitoring, nil when it is
+// stopped.
+func activeQueue() *queue.Queue {
+	monitorMutex.Lock()
+	defer monitorMutex.Unlock()
+	return reviewQueue
 }
 
+// GetCodeReviews returns a copy of the reviews, safe to read while workers
+// update them.
 func GetCodeReviews() []CodeReview {
 	reviewsMutex.Lock()
 	defer reviewsMutex.Unlock()
-	return reviews
+	return append([]CodeReview(nil), reviews...)
 }
@@ -0,0 +1,73 @@
+package review
+
+import (
+	"github.com/michalopenmakers/lazyreview/ai"
+)
+
+// Verdict decides how a review is submitted to the forge.
+type Verdict string
+
+const (
+	VerdictComment        Verdict = "comment"
+	VerdictRequestChanges Verdict = "request_changes"
+	VerdictApprove        Verdict = "approve"
+)
+
+var Verdicts = []Verdict{VerdictComment, VerdictRequestChanges, VerdictApprove}
+
+func (v Verdict) Label() string {
+	switch v {
+	case VerdictRequestChanges:
+		return "Request changes"
+	case VerdictApprove:
+		return "Approve"
+	default:
+		return "Comment"
+	}
+}
+
+// GitHubEvent maps the verdict to a GitHub pull request review event.
+func (v Verdict) GitHubEvent() string {
+	switch v {
+	case VerdictRequestChanges:
+		return "REQUEST_CHANGES"
+	case VerdictApprove:
+		return "APPROVE"
+	default:
+		return "COMMENT"
+	}
+}
+
+func VerdictFromLabel(label string) Verdict {
+	for _, v := range Verdicts {
+		if v.Label() == label {
+			return v
+		}
+	}
+	return VerdictComment
+}
+
+// DefaultVerdict suggests a verdict based on the severity of the findings.
+// Free-text reviews, which carry no severities, default to a comment.
+func DefaultVerdict(r CodeReview) Verdict {
+	if len(r.Findings) == 0 {
+		if r.Summary == "" {
+			return VerdictComment
+		}
+		return VerdictApprove
+	}
+	highest := -1
+	for _, f := range r.Findings {
+		if rank := ai.SeverityRank(f.Severity); rank > highest {
+			highest = rank
+		}
+	}
+	switch {
+	case highest >= ai.SeverityRank("high"):
+		return VerdictRequestChanges
+	case highest >= ai.SeverityRank("low"):
+		return VerdictComment
+	default:
+		return VerdictApprove
+	}
+}
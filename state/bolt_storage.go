@@ -0,0 +1,296 @@
+package state
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/michalopenmakers/lazyreview/ai"
+	"github.com/michalopenmakers/lazyreview/logger"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucketMeta          = []byte("meta")
+	bucketProjects      = []byte("projects")
+	bucketMergeRequests = []byte("merge_requests")
+	bucketReviewRuns    = []byte("review_runs")
+	bucketFindings      = []byte("findings")
+	bucketPostEvents    = []byte("post_events")
+
+	keySchemaVersion = []byte("schema_version")
+)
+
+// migrations are applied in order; the schema version stored in the meta
+// bucket is the number of migrations already applied.
+var migrations = []func(s *boltStorage, tx *bolt.Tx) error{
+	// 1: utworzenie kubełków
+	func(s *boltStorage, tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketProjects, bucketMergeRequests, bucketReviewRuns, bucketFindings, bucketPostEvents} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	// 2: import istniejącego pliku JSON
+	func(s *boltStorage, tx *bolt.Tx) error {
+		return s.importJSON(tx)
+	},
+}
+
+// boltStorage keeps the state in an embedded bbolt database. Every change is
+// written as a single record, and review runs and post events are kept as
+// history.
+type boltStorage struct {
+	db         *bolt.DB
+	importPath string
+}
+
+func openBoltStorage(path, importPath string) (*boltStorage, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("error opening database %s: %w", path, err)
+	}
+	s := &boltStorage{db: db, importPath: importPath}
+	if err := s.migrate(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *boltStorage) migrate() error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(bucketMeta)
+		if err != nil {
+			return err
+		}
+		version := 0
+		if raw := meta.Get(keySchemaVersion); raw != nil {
+			version = int(binary.BigEndian.Uint64(raw))
+		}
+		for i := version; i < len(migrations); i++ {
+			logger.Log(fmt.Sprintf("Applying database migration %d", i+1))
+			if err := migrations[i](s, tx); err != nil {
+				return fmt.Errorf("error applying database migration %d: %w", i+1, err)
+			}
+		}
+		return meta.Put(keySchemaVersion, itob(uint64(len(migrations))))
+	})
+}
+
+func (s *boltStorage) importJSON(tx *bolt.Tx) error {
+	if s.importPath == "" {
+		return nil
+	}
+	if _, err := os.Stat(s.importPath); err != nil {
+		return nil
+	}
+	state, err := newJSONStorage(s.importPath).Load()
+	if err != nil {
+		logger.Log(fmt.Sprintf("Skipping import of %s: %v", s.importPath, err))
+		return nil
+	}
+	logger.Log(fmt.Sprintf("Importing state from %s", s.importPath))
+	if err := putAll(tx, state); err != nil {
+		return err
+	}
+	for _, run := range state.ReviewRuns {
+		if err := putReviewRun(tx, run); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *boltStorage) Load() (*AppState, error) {
+	state := &AppState{
+		GitLabProjects: make(map[string]*ProjectState),
+		GitHubRepos:    make(map[string]*ProjectState),
+	}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		err := tx.Bucket(bucketProjects).ForEach(func(k, v []byte) error {
+			source, id, found := strings.Cut(string(k), "/")
+			if !found {
+				return nil
+			}
+			var project ProjectState
+			if err := json.Unmarshal(v, &project); err != nil {
+				return err
+			}
+			if source == SourceGitHub {
+				state.GitHubRepos[id] = &project
+			} else {
+				state.GitLabProjects[id] = &project
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(bucketMergeRequests).ForEach(func(k, v []byte) error {
+			var record ReviewRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			state.Reviews = append(state.Reviews, &record)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error loading state from database: %w", err)
+	}
+	return state, nil
+}
+
+func (s *boltStorage) SaveAll(state *AppState) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putAll(tx, state)
+	})
+}
+
+func putAll(tx *bolt.Tx, state *AppState) error {
+	for id, project := range state.GitLabProjects {
+		if err := putJSON(tx.Bucket(bucketProjects), projectKey(SourceGitLab, id), project); err != nil {
+			return err
+		}
+	}
+	for id, project := range state.GitHubRepos {
+		if err := putJSON(tx.Bucket(bucketProjects), projectKey(SourceGitHub, id), project); err != nil {
+			return err
+		}
+	}
+	for _, record := range state.Reviews {
+		if err := putJSON(tx.Bucket(bucketMergeRequests), []byte(record.ID), record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *boltStorage) SaveProject(source, id string, project *ProjectState) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putJSON(tx.Bucket(bucketProjects), projectKey(source, id), project)
+	})
+}
+
+func (s *boltStorage) SaveReview(record *ReviewRecord) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putJSON(tx.Bucket(bucketMergeRequests), []byte(record.ID), record)
+	})
+}
+
+func (s *boltStorage) AddReviewRun(run *ReviewRun) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putReviewRun(tx, run)
+	})
+}
+
+// putReviewRun stores the run under a new ID, with its findings in their own
+// bucket.
+func putReviewRun(tx *bolt.Tx, run *ReviewRun) error {
+	runs := tx.Bucket(bucketReviewRuns)
+	seq, err := runs.NextSequence()
+	if err != nil {
+		return err
+	}
+	run.ID = seq
+	stored := *run
+	stored.Findings = nil
+	if err := putJSON(runs, historyKey(run.ReviewID, seq), &stored); err != nil {
+		return err
+	}
+	findings := tx.Bucket(bucketFindings)
+	for idx, finding := range run.Findings {
+		key := []byte(fmt.Sprintf("%020d/%06d", seq, idx))
+		if err := putJSON(findings, key, finding); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *boltStorage) AddPostEvent(event *PostEvent) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		events := tx.Bucket(bucketPostEvents)
+		seq, err := events.NextSequence()
+		if err != nil {
+			return err
+		}
+		event.ID = seq
+		return putJSON(events, historyKey(event.ReviewID, seq), event)
+	})
+}
+
+func (s *boltStorage) ReviewRuns(reviewID string) ([]ReviewRun, error) {
+	var runs []ReviewRun
+	err := s.db.View(func(tx *bolt.Tx) error {
+		prefix := []byte(reviewID + "/")
+		c := tx.Bucket(bucketReviewRuns).Cursor()
+		for k, v := c.Seek(prefix); k != nil && strings.HasPrefix(string(k), string(prefix)); k, v = c.Next() {
+			var run ReviewRun
+			if err := json.Unmarshal(v, &run); err != nil {
+				return err
+			}
+			findingsPrefix := []byte(fmt.Sprintf("%020d/", run.ID))
+			fc := tx.Bucket(bucketFindings).Cursor()
+			for fk, fv := fc.Seek(findingsPrefix); fk != nil && strings.HasPrefix(string(fk), string(findingsPrefix)); fk, fv = fc.Next() {
+				var finding ai.Finding
+				if err := json.Unmarshal(fv, &finding); err != nil {
+					return err
+				}
+				run.Findings = append(run.Findings, finding)
+			}
+			runs = append(runs, run)
+		}
+		return nil
+	})
+	return runs, err
+}
+
+func (s *boltStorage) PostEvents(reviewID string) ([]PostEvent, error) {
+	var events []PostEvent
+	err := s.db.View(func(tx *bolt.Tx) error {
+		prefix := []byte(reviewID + "/")
+		c := tx.Bucket(bucketPostEvents).Cursor()
+		for k, v := c.Seek(prefix); k != nil && strings.HasPrefix(string(k), string(prefix)); k, v = c.Next() {
+			var event PostEvent
+			if err := json.Unmarshal(v, &event); err != nil {
+				return err
+			}
+			events = append(events, event)
+		}
+		return nil
+	})
+	return events, err
+}
+
+func (s *boltStorage) Close() error {
+	return s.db.Close()
+}
+
+func putJSON(bucket *bolt.Bucket, key []byte, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(key, data)
+}
+
+func projectKey(source, id string) []byte {
+	return []byte(source + "/" + id)
+}
+
+func historyKey(reviewID string, seq uint64) []byte {
+	return []byte(fmt.Sprintf("%s/%020d", reviewID, seq))
+}
+
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
@@ -0,0 +1,102 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// jsonStorage keeps the whole state in a single JSON file, rewritten on every
+// change. Review runs are stored in the same file; post events are not kept.
+type jsonStorage struct {
+	path    string
+	current *AppState
+}
+
+func newJSONStorage(path string) *jsonStorage {
+	return &jsonStorage{path: path}
+}
+
+func (s *jsonStorage) Load() (*AppState, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading state file: %w", err)
+	}
+
+	var state AppState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("error unmarshaling state: %w", err)
+	}
+	s.current = &state
+	return &state, nil
+}
+
+func (s *jsonStorage) SaveAll(state *AppState) error {
+	s.current = state
+	tmpFile := s.path + ".tmp"
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling state: %w", err)
+	}
+
+	err = os.WriteFile(tmpFile, data, 0600)
+	if err != nil {
+		return fmt.Errorf("error writing to temporary state file: %w", err)
+	}
+
+	err = os.Rename(tmpFile, s.path)
+	if err != nil {
+		return fmt.Errorf("error renaming temporary state file: %w", err)
+	}
+	return nil
+}
+
+func (s *jsonStorage) SaveProject(source, id string, project *ProjectState) error {
+	return s.saveCurrent()
+}
+
+func (s *jsonStorage) SaveReview(record *ReviewRecord) error {
+	return s.saveCurrent()
+}
+
+func (s *jsonStorage) saveCurrent() error {
+	if s.current == nil {
+		return fmt.Errorf("cannot save nil state")
+	}
+	return s.SaveAll(s.current)
+}
+
+func (s *jsonStorage) AddReviewRun(run *ReviewRun) error {
+	if s.current == nil {
+		return fmt.Errorf("cannot save nil state")
+	}
+	run.ID = uint64(len(s.current.ReviewRuns)) + 1
+	stored := *run
+	s.current.ReviewRuns = append(s.current.ReviewRuns, &stored)
+	return s.saveCurrent()
+}
+
+func (s *jsonStorage) AddPostEvent(event *PostEvent) error {
+	return nil
+}
+
+func (s *jsonStorage) ReviewRuns(reviewID string) ([]ReviewRun, error) {
+	if s.current == nil {
+		return nil, nil
+	}
+	var runs []ReviewRun
+	for _, run := range s.current.ReviewRuns {
+		if run.ReviewID == reviewID {
+			runs = append(runs, *run)
+		}
+	}
+	return runs, nil
+}
+
+func (s *jsonStorage) PostEvents(reviewID string) ([]PostEvent, error) {
+	return nil, nil
+}
+
+func (s *jsonStorage) Close() error {
+	return nil
+}
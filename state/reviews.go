@@ -0,0 +1,122 @@
+package state
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/michalopenmakers/lazyreview/ai"
+	"github.com/michalopenmakers/lazyreview/filter"
+	"github.com/michalopenmakers/lazyreview/logger"
+)
+
+// ReviewRecord is the persisted form of a generated review, kept so that
+// restarts neither lose edits nor re-run AI reviews.
+type ReviewRecord struct {
+	ID         string
+	Title      string
+	URL        string
+	LastCommit string
+	BaseCommit string
+	ReviewedAt time.Time
+	Source     string
+	ProjectID  string
+	MergeReqID int
+	Repository string
+	PullReqID  int
+	ReviewText string
+	Summary    string
+	Findings   []ai.Finding
+	Skipped    []filter.Skipped
+	Edited     bool
+	Verdict    string
+	Accepted   bool
+	Commented  bool
+}
+
+func SaveReview(record ReviewRecord) {
+	if initialize() != nil {
+		return
+	}
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	replaced := false
+	for i, existing := range appState.Reviews {
+		if existing.ID == record.ID {
+			appState.Reviews[i] = &record
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		appState.Reviews = append(appState.Reviews, &record)
+	}
+	if err := storage.SaveReview(&record); err != nil {
+		logger.Log(fmt.Sprintf("Error saving review %s: %v", record.ID, err))
+	}
+}
+
+// AddReviewRun records a generated review in the history.
+func AddReviewRun(run ReviewRun) {
+	if initialize() != nil {
+		return
+	}
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+	if err := storage.AddReviewRun(&run); err != nil {
+		logger.Log(fmt.Sprintf("Error saving review run for %s: %v", run.ReviewID, err))
+	}
+}
+
+// AddPostEvent records an attempt to publish a review. The JSON file backend
+// does not keep post events.
+func AddPostEvent(event PostEvent) {
+	if initialize() != nil {
+		return
+	}
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+	if err := storage.AddPostEvent(&event); err != nil {
+		logger.Log(fmt.Sprintf("Error saving post event for %s: %v", event.ReviewID, err))
+	}
+}
+
+func GetReviewRuns(reviewID string) []ReviewRun {
+	if initialize() != nil {
+		return nil
+	}
+	stateMutex.RLock()
+	defer stateMutex.RUnlock()
+	runs, err := storage.ReviewRuns(reviewID)
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error reading review runs for %s: %v", reviewID, err))
+	}
+	return runs
+}
+
+func GetPostEvents(reviewID string) []PostEvent {
+	if initialize() != nil {
+		return nil
+	}
+	stateMutex.RLock()
+	defer stateMutex.RUnlock()
+	events, err := storage.PostEvents(reviewID)
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error reading post events for %s: %v", reviewID, err))
+	}
+	return events
+}
+
+func GetReviews() []ReviewRecord {
+	if initialize() != nil {
+		return nil
+	}
+	stateMutex.RLock()
+	defer stateMutex.RUnlock()
+
+	records := make([]ReviewRecord, 0, len(appState.Reviews))
+	for _, record := range appState.Reviews {
+		records = append(records, *record)
+	}
+	return records
+}
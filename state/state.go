@@ -1,7 +1,7 @@
 package state
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/michalopenmakers/lazyreview/logger"
 	"os"
@@ -20,6 +20,10 @@ type ProjectState struct {
 type AppState struct {
 	GitLabProjects map[string]*ProjectState
 	GitHubRepos    map[string]*ProjectState
+	Reviews        []*ReviewRecord
+	// ReviewRuns is the review history kept by the JSON file backend; the
+	// database keeps it in its own buckets.
+	ReviewRuns []*ReviewRun `json:",omitempty"`
 }
 
 var (
@@ -27,11 +31,16 @@ var (
 	stateMutex    sync.RWMutex
 	initialized   bool
 	stateFilePath string
+	backendName   = BackendJSON
+	storage       Storage
+	// initErr keeps the failure of opening the storage, so it is reported
+	// instead of retried on every access.
+	initErr error
 )
 
-func initialize() {
-	if initialized {
-		return
+func initialize() error {
+	if initialized || initErr != nil {
+		return initErr
 	}
 
 	stateMutex.Lock()
@@ -43,25 +52,35 @@ func initialize() {
 		homeDir = "."
 	}
 	stateFilePath = filepath.Join(homeDir, ".lazyreview_state.json")
-	logger.Log(fmt.Sprintf("Using state file: %s", stateFilePath))
 
-	if _, err := os.Stat(stateFilePath); err == nil {
-		// Plik istnieje, wczytaj stan
-		if err := LoadState(); err != nil {
+	if backendName == BackendBolt {
+		dbPath := filepath.Join(homeDir, ".lazyreview.db")
+		logger.Log(fmt.Sprintf("Using state database: %s", dbPath))
+		boltStorage, err := openBoltStorage(dbPath, stateFilePath)
+		if err != nil {
+			// Zapis do pliku JSON rozjechałby się z bazą, więc nie ma powrotu do JSON
+			initErr = fmt.Errorf("cannot open state database %s (is another LazyReview instance running?): %w", dbPath, err)
+			logger.Log(initErr.Error())
+			return initErr
+		}
+		storage = boltStorage
+	}
+	if storage == nil {
+		logger.Log(fmt.Sprintf("Using state file: %s", stateFilePath))
+		storage = newJSONStorage(stateFilePath)
+	}
+
+	if err := LoadState(); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			logger.Log("State file doesn't exist, creating new")
+		} else {
 			logger.Log(fmt.Sprintf("Error loading state, creating new: %v", err))
-			createNewState()
 		}
-	} else if os.IsNotExist(err) {
-		// Plik nie istnieje, utwórz nowy stan
-		logger.Log("State file doesn't exist, creating new")
-		createNewState()
-	} else {
-		// Inny błąd
-		logger.Log(fmt.Sprintf("Error checking state file: %v", err))
 		createNewState()
 	}
 
 	initialized = true
+	return nil
 }
 
 func createNewState() {
@@ -74,71 +93,133 @@ func createNewState() {
 	}
 }
 
+// SaveState writes the whole state to the storage backend.
 func SaveState() error {
 	if appState == nil {
 		return fmt.Errorf("cannot save nil state")
 	}
-
-	tmpFile := stateFilePath + ".tmp"
-	data, err := json.MarshalIndent(appState, "", "  ")
-	if err != nil {
-		return fmt.Errorf("error marshaling state: %w", err)
+	if err := storage.SaveAll(appState); err != nil {
+		return err
 	}
+	logger.Log("State saved successfully")
+	return nil
+}
 
-	err = os.WriteFile(tmpFile, data, 0600)
+func LoadState() error {
+	state, err := storage.Load()
 	if err != nil {
-		return fmt.Errorf("error writing to temporary state file: %w", err)
+		return err
 	}
-
-	err = os.Rename(tmpFile, stateFilePath)
-	if err != nil {
-		return fmt.Errorf("error renaming temporary state file: %w", err)
+	if state.GitLabProjects == nil {
+		state.GitLabProjects = make(map[string]*ProjectState)
+	}
+	if state.GitHubRepos == nil {
+		state.GitHubRepos = make(map[string]*ProjectState)
 	}
 
-	logger.Log("State saved successfully")
+	appState = state
+	logger.Log("State loaded successfully")
 	return nil
 }
 
-func LoadState() error {
-	data, err := os.ReadFile(stateFilePath)
-	if err != nil {
-		return fmt.Errorf("error reading state file: %w", err)
+// Init loads the state using the given storage backend ("json" or "bolt").
+// It fails when the bolt database cannot be opened, usually because another
+// process holds its lock; the state is then unavailable.
+func Init(backend string) error {
+	if backend != "" {
+		backendName = backend
+	}
+	if err := initialize(); err != nil {
+		return err
 	}
+	logger.Log("State module initialized")
+	return nil
+}
 
-	var state AppState
-	if err := json.Unmarshal(data, &state); err != nil {
-		return fmt.Errorf("error unmarshaling state: %w", err)
+// Close releases the storage backend.
+func Close() {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+	if storage != nil {
+		if err := storage.Close(); err != nil {
+			logger.Log(fmt.Sprintf("Error closing state storage: %v", err))
+		}
 	}
+}
 
-	appState = &state
-	logger.Log("State loaded successfully")
+func saveProject(source, id string, project *ProjectState) error {
+	if err := storage.SaveProject(source, id, project); err != nil {
+		return err
+	}
+	logger.Log("State saved successfully")
 	return nil
 }
 
-func Init() {
-	initialize()
-	logger.Log("State module initialized")
+// Location describes where the state is kept: the backend name and the path
+// of its file.
+func Location() (string, string) {
+	if initialize() != nil {
+		return backendName, ""
+	}
+	stateMutex.RLock()
+	defer stateMutex.RUnlock()
+	if bolt, ok := storage.(*boltStorage); ok {
+		return BackendBolt, bolt.db.Path()
+	}
+	return BackendJSON, stateFilePath
+}
+
+// GetState returns a copy of the whole application state.
+func GetState() AppState {
+	if initialize() != nil {
+		return AppState{}
+	}
+	stateMutex.RLock()
+	defer stateMutex.RUnlock()
+
+	snapshot := AppState{
+		GitLabProjects: make(map[string]*ProjectState, len(appState.GitLabProjects)),
+		GitHubRepos:    make(map[string]*ProjectState, len(appState.GitHubRepos)),
+		Reviews:        make([]*ReviewRecord, 0, len(appState.Reviews)),
+	}
+	for id, project := range appState.GitLabProjects {
+		copied := *project
+		snapshot.GitLabProjects[id] = &copied
+	}
+	for repo, project := range appState.GitHubRepos {
+		copied := *project
+		snapshot.GitHubRepos[repo] = &copied
+	}
+	for _, record := range appState.Reviews {
+		copied := *record
+		snapshot.Reviews = append(snapshot.Reviews, &copied)
+	}
+	return snapshot
 }
 
 func UpdateGitLabProjectState(projectID, commitID string, timestamp int64) {
-	initialize()
+	if initialize() != nil {
+		return
+	}
 	stateMutex.Lock()
 	defer stateMutex.Unlock()
 
-	if project, exists := appState.GitLabProjects[projectID]; exists {
+	project, exists := appState.GitLabProjects[projectID]
+	if exists {
 		project.LastReviewedCommit = commitID
 		project.LastReviewTime = timestamp
 		project.ReviewCount++
 		project.Commented = false // resetujemy flagę przy aktualizacji commit
 	} else {
-		appState.GitLabProjects[projectID] = &ProjectState{
+		project = &ProjectState{
 			LastReviewedCommit: commitID,
 			LastReviewTime:     timestamp,
 			ReviewCount:        1,
 			Commented:          false,
 		}
+		appState.GitLabProjects[projectID] = project
 	}
-	err := SaveState()
+	err := saveProject(SourceGitLab, projectID, project)
 	if err != nil {
 		logger.Log("Error saving state: " + err.Error())
 		return
@@ -147,24 +228,28 @@ func UpdateGitLabProjectState(projectID, commitID string, timestamp int64) {
 }
 
 func UpdateGitHubRepoState(repo, commitID string, timestamp int64) {
-	initialize()
+	if initialize() != nil {
+		return
+	}
 	stateMutex.Lock()
 	defer stateMutex.Unlock()
 
-	if project, exists := appState.GitHubRepos[repo]; exists {
+	project, exists := appState.GitHubRepos[repo]
+	if exists {
 		project.LastReviewedCommit = commitID
 		project.LastReviewTime = timestamp
 		project.ReviewCount++
 		project.Commented = false
 	} else {
-		appState.GitHubRepos[repo] = &ProjectState{
+		project = &ProjectState{
 			LastReviewedCommit: commitID,
 			LastReviewTime:     timestamp,
 			ReviewCount:        1,
 			Commented:          false,
 		}
+		appState.GitHubRepos[repo] = project
 	}
-	err := SaveState()
+	err := saveProject(SourceGitHub, repo, project)
 	if err != nil {
 		logger.Log("Error saving state: " + err.Error())
 		return
@@ -173,7 +258,9 @@ func UpdateGitHubRepoState(repo, commitID string, timestamp int64) {
 }
 
 func MarkGitLabProjectCommented(projectID string) {
-	initialize()
+	if initialize() != nil {
+		return
+	}
 	stateMutex.Lock()
 	defer stateMutex.Unlock()
 
@@ -182,21 +269,23 @@ func MarkGitLabProjectCommented(projectID string) {
 		appState.GitLabProjects = make(map[string]*ProjectState)
 	}
 
-	if project, exists := appState.GitLabProjects[projectID]; exists {
+	project, exists := appState.GitLabProjects[projectID]
+	if exists {
 		project.Commented = true
 		logger.Log(fmt.Sprintf("Setting commented flag for project %s", projectID))
 	} else {
 		logger.Log(fmt.Sprintf("Creating new state entry for project %s", projectID))
-		appState.GitLabProjects[projectID] = &ProjectState{
+		project = &ProjectState{
 			LastReviewedCommit: "unknown",
 			LastReviewTime:     time.Now().Unix(),
 			ReviewCount:        1,
 			Commented:          true,
 		}
+		appState.GitLabProjects[projectID] = project
 	}
 
-	logger.Log("Calling SaveState after marking project as commented")
-	err := SaveState()
+	logger.Log("Saving state after marking project as commented")
+	err := saveProject(SourceGitLab, projectID, project)
 	if err != nil {
 		logger.Log(fmt.Sprintf("ERROR marking GitLab project as commented: %v", err))
 	} else {
@@ -0,0 +1,53 @@
+package state
+
+import (
+	"time"
+
+	"github.com/michalopenmakers/lazyreview/ai"
+)
+
+const (
+	SourceGitLab = "gitlab"
+	SourceGitHub = "github"
+
+	BackendJSON = "json"
+	BackendBolt = "bolt"
+)
+
+// ReviewRun is a single AI review generated for a merge/pull request.
+// BaseCommit is set for incremental reviews of the commits after it.
+type ReviewRun struct {
+	ID         uint64
+	ReviewID   string
+	Commit     string
+	BaseCommit string
+	Provider   string
+	CreatedAt  time.Time
+	Summary    string
+	Text       string
+	Findings   []ai.Finding
+}
+
+// PostEvent records an attempt to publish a review on the forge.
+type PostEvent struct {
+	ID       uint64
+	ReviewID string
+	Verdict  string
+	PostedAt time.Time
+	Success  bool
+	Error    string
+}
+
+// Storage persists the application state. Both backends keep the history of
+// review runs; post events are kept only by the embedded database.
+type Storage interface {
+	Load() (*AppState, error)
+	SaveAll(state *AppState) error
+	SaveProject(source, id string, project *ProjectState) error
+	SaveReview(record *ReviewRecord) error
+	AddReviewRun(run *ReviewRun) error
+	AddPostEvent(event *PostEvent) error
+	ReviewRuns(reviewID string) ([]ReviewRun, error)
+	PostEvents(reviewID string) ([]PostEvent, error)
+	Close() error
+}
@@ -0,0 +1,218 @@
+package state
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/michalopenmakers/lazyreview/ai"
+	bolt "go.etcd.io/bbolt"
+)
+
+func testState() *AppState {
+	return &AppState{
+		GitLabProjects: map[string]*ProjectState{"42": {LastReviewedCommit: "abc", ReviewCount: 2, Commented: true}},
+		GitHubRepos:    map[string]*ProjectState{"owner/repo": {LastReviewedCommit: "def", ReviewCount: 1}},
+		Reviews: []*ReviewRecord{
+			{ID: "gitlab-42-7", Source: SourceGitLab, ProjectID: "42", MergeReqID: 7, LastCommit: "abc", ReviewText: "Looks good."},
+		},
+	}
+}
+
+func testRun(reviewID, commit string, findings ...ai.Finding) *ReviewRun {
+	return &ReviewRun{ReviewID: reviewID, Commit: commit, Provider: "openai", CreatedAt: time.Unix(1700000000, 0).UTC(), Text: "Review of " + commit, Findings: findings}
+}
+
+func writeJSONState(t *testing.T, path string, state *AppState) {
+	t.Helper()
+	if err := newJSONStorage(path).SaveAll(state); err != nil {
+		t.Fatalf("SaveAll: %v", err)
+	}
+}
+
+func schemaVersion(t *testing.T, s *boltStorage) uint64 {
+	t.Helper()
+	var version uint64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		version = binary.BigEndian.Uint64(tx.Bucket(bucketMeta).Get(keySchemaVersion))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return version
+}
+
+func TestBoltImportsJSONState(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath, dbPath := filepath.Join(dir, "state.json"), filepath.Join(dir, "state.db")
+	imported := testState()
+	imported.ReviewRuns = []*ReviewRun{testRun("gitlab-42-7", "abc", ai.Finding{File: "main.go", StartLine: 3, Severity: "high", Message: "Nil map"})}
+	writeJSONState(t, jsonPath, imported)
+
+	s, err := openBoltStorage(dbPath, jsonPath)
+	if err != nil {
+		t.Fatalf("openBoltStorage: %v", err)
+	}
+	if v := schemaVersion(t, s); v != uint64(len(migrations)) {
+		t.Errorf("schema version = %d, want %d", v, len(migrations))
+	}
+	state, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if p := state.GitLabProjects["42"]; p == nil || p.LastReviewedCommit != "abc" || p.ReviewCount != 2 || !p.Commented {
+		t.Errorf("GitLab project = %+v", p)
+	}
+	if p := state.GitHubRepos["owner/repo"]; p == nil || p.LastReviewedCommit != "def" {
+		t.Errorf("GitHub repository = %+v", p)
+	}
+	if len(state.Reviews) != 1 || state.Reviews[0].ReviewText != "Looks good." {
+		t.Errorf("reviews = %+v", state.Reviews)
+	}
+	runs, err := s.ReviewRuns("gitlab-42-7")
+	if err != nil || len(runs) != 1 || len(runs[0].Findings) != 1 || runs[0].Findings[0].Message != "Nil map" {
+		t.Errorf("ReviewRuns() = %+v, %v", runs, err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Plik JSON jest importowany tylko raz
+	writeJSONState(t, jsonPath, &AppState{GitLabProjects: map[string]*ProjectState{"99": {}}})
+	s, err = openBoltStorage(dbPath, jsonPath)
+	if err != nil {
+		t.Fatalf("openBoltStorage: %v", err)
+	}
+	defer s.Close()
+	state, err = s.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if _, ok := state.GitLabProjects["99"]; ok || len(state.GitLabProjects) != 1 {
+		t.Errorf("reopened database imported the JSON file again: %v", state.GitLabProjects)
+	}
+}
+
+func TestBoltMigrationsResume(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath, dbPath := filepath.Join(dir, "state.json"), filepath.Join(dir, "state.db")
+
+	// Baza utworzona przed migracją importu
+	s, err := openBoltStorage(dbPath, "")
+	if err != nil {
+		t.Fatalf("openBoltStorage: %v", err)
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketMeta).Put(keySchemaVersion, itob(1))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	writeJSONState(t, jsonPath, testState())
+	s, err = openBoltStorage(dbPath, jsonPath)
+	if err != nil {
+		t.Fatalf("openBoltStorage: %v", err)
+	}
+	defer s.Close()
+	if v := schemaVersion(t, s); v != uint64(len(migrations)) {
+		t.Errorf("schema version = %d, want %d", v, len(migrations))
+	}
+	state, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(state.GitLabProjects) != 1 || len(state.Reviews) != 1 {
+		t.Errorf("state after resumed migrations = %+v", state)
+	}
+}
+
+func TestBoltMissingOrInvalidImport(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalid, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, importPath := range []string{"", filepath.Join(dir, "missing.json"), invalid} {
+		s, err := openBoltStorage(filepath.Join(t.TempDir(), "state.db"), importPath)
+		if err != nil {
+			t.Fatalf("openBoltStorage(%q): %v", importPath, err)
+		}
+		state, err := s.Load()
+		if err != nil || len(state.GitLabProjects) != 0 || len(state.Reviews) != 0 {
+			t.Errorf("Load() after importing %q = %+v, %v", importPath, state, err)
+		}
+		s.Close()
+	}
+}
+
+func TestBoltHistory(t *testing.T) {
+	s, err := openBoltStorage(filepath.Join(t.TempDir(), "state.db"), "")
+	if err != nil {
+		t.Fatalf("openBoltStorage: %v", err)
+	}
+	defer s.Close()
+
+	finding := ai.Finding{File: "a.go", StartLine: 1, Severity: "low", Message: "Typo"}
+	for _, run := range []*ReviewRun{
+		testRun("gitlab-42-7", "abc", finding, finding),
+		testRun("gitlab-42-70", "fff"),
+		testRun("gitlab-42-7", "def"),
+	} {
+		if err := s.AddReviewRun(run); err != nil {
+			t.Fatalf("AddReviewRun: %v", err)
+		}
+	}
+	runs, err := s.ReviewRuns("gitlab-42-7")
+	if err != nil {
+		t.Fatalf("ReviewRuns: %v", err)
+	}
+	if len(runs) != 2 || runs[0].Commit != "abc" || runs[1].Commit != "def" || len(runs[0].Findings) != 2 || len(runs[1].Findings) != 0 {
+		t.Errorf("ReviewRuns() = %+v", runs)
+	}
+
+	for _, event := range []*PostEvent{
+		{ReviewID: "gitlab-42-7", Verdict: "comment", Success: false, Error: "timeout"},
+		{ReviewID: "gitlab-42-7", Verdict: "comment", Success: true},
+	} {
+		if err := s.AddPostEvent(event); err != nil {
+			t.Fatalf("AddPostEvent: %v", err)
+		}
+	}
+	events, err := s.PostEvents("gitlab-42-7")
+	if err != nil || len(events) != 2 || events[0].Error != "timeout" || !events[1].Success {
+		t.Errorf("PostEvents() = %+v, %v", events, err)
+	}
+}
+
+func TestJSONStorageKeepsReviewRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	s := newJSONStorage(path)
+	if err := s.SaveAll(testState()); err != nil {
+		t.Fatalf("SaveAll: %v", err)
+	}
+	for _, run := range []*ReviewRun{testRun("gitlab-42-7", "abc"), testRun("github-owner/repo-5", "fff"), testRun("gitlab-42-7", "def")} {
+		if err := s.AddReviewRun(run); err != nil {
+			t.Fatalf("AddReviewRun: %v", err)
+		}
+	}
+	if err := s.AddPostEvent(&PostEvent{ReviewID: "gitlab-42-7"}); err != nil {
+		t.Fatalf("AddPostEvent: %v", err)
+	}
+
+	reloaded := newJSONStorage(path)
+	if _, err := reloaded.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	runs, err := reloaded.ReviewRuns("gitlab-42-7")
+	if err != nil || len(runs) != 2 || runs[0].Commit != "abc" || runs[1].Commit != "def" || runs[1].ID != 3 {
+		t.Errorf("ReviewRuns() = %+v, %v", runs, err)
+	}
+	if events, err := reloaded.PostEvents("gitlab-42-7"); err != nil || len(events) != 0 {
+		t.Errorf("PostEvents() = %+v, %v", events, err)
+	}
+}
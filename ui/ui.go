@@ -1,11 +1,14 @@
 package ui
 
 import (
+	"context"
 	_ "embed"
 	"fmt"
 	"image/color"
+	"slices"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -17,10 +20,14 @@ import (
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 
+	"github.com/michalopenmakers/lazyreview/ai"
 	"github.com/michalopenmakers/lazyreview/business"
 	"github.com/michalopenmakers/lazyreview/config"
+	"github.com/michalopenmakers/lazyreview/filter"
+	"github.com/michalopenmakers/lazyreview/gitlab"
 	"github.com/michalopenmakers/lazyreview/logger"
 	"github.com/michalopenmakers/lazyreview/review"
+	"github.com/michalopenmakers/lazyreview/webhook"
 )
 
 //go:embed icon.png
@@ -36,7 +43,12 @@ var (
 	statusInfo         *widget.Label
 	currentReviewIndex = -1
 	selectedReview     *review.CodeReview
-	acceptButton       *widget.Button
+	submitButton       *widget.Button
+	verdictSelect      *widget.Select
+	verdictReviewID    string
+	submittingReview   atomic.Value
+	findingsLabel      *widget.Label
+	skippedLabel       *widget.Label
 	reviewsListScroll  *container.Scroll
 	isEditing          bool = false
 	prevReviewCount    int  = 0
@@ -76,6 +88,8 @@ func updateReviewsList(reviewsList *fyne.Container, reviewDetails *widget.Entry)
 		if reviewDetails != nil {
 			reviewDetails.SetText("")
 		}
+		showFindingsSummary(nil)
+		showSubmitControls(nil)
 		currentReviewIndex = -1
 		selectedReview = nil
 	} else {
@@ -91,18 +105,8 @@ func updateReviewsList(reviewsList *fyne.Container, reviewDetails *widget.Entry)
 					if reviewDetails != nil {
 						reviewDetails.SetText(currentReview.ReviewText)
 					}
-					if currentReview.ReviewText != "" {
-						if currentReview.Accepted {
-							acceptButton.SetText("Accepted")
-							acceptButton.Disable()
-						} else {
-							acceptButton.SetText("Accept")
-							acceptButton.Enable()
-						}
-						acceptButton.Show()
-					} else {
-						acceptButton.Hide()
-					}
+					showFindingsSummary(currentReview)
+					showSubmitControls(currentReview)
 					setStatus(fmt.Sprintf("Showing review: %s", currentReview.Title))
 				})
 				row := container.NewHBox(btnSelect)
@@ -114,18 +118,8 @@ func updateReviewsList(reviewsList *fyne.Container, reviewDetails *widget.Entry)
 					if reviewDetails != nil {
 						reviewDetails.SetText(currentReview.ReviewText)
 					}
-					if currentReview.ReviewText != "" {
-						if currentReview.Accepted {
-							acceptButton.SetText("Accepted")
-							acceptButton.Disable()
-						} else {
-							acceptButton.SetText("Accept")
-							acceptButton.Enable()
-						}
-						acceptButton.Show()
-					} else {
-						acceptButton.Hide()
-					}
+					showFindingsSummary(currentReview)
+					showSubmitControls(currentReview)
 				}
 			}
 		}
@@ -139,24 +133,112 @@ func updateReviewsList(reviewsList *fyne.Container, reviewDetails *widget.Entry)
 		for i := range reviews {
 			if reviews[i].ID == selectedReview.ID {
 				reviewDetails.SetText(reviews[i].ReviewText)
-				if reviews[i].ReviewText != "" {
-					if reviews[i].Accepted {
-						acceptButton.SetText("Accepted")
-						acceptButton.Disable()
-					} else {
-						acceptButton.SetText("Accept")
-						acceptButton.Enable()
-					}
-					acceptButton.Show()
-				} else {
-					acceptButton.Hide()
-				}
+				showFindingsSummary(&reviews[i])
+				showSubmitControls(&reviews[i])
 				break
 			}
 		}
 	}
 }
 
+func showFindingsSummary(r *review.CodeReview) {
+	if findingsLabel == nil {
+		return
+	}
+	showSkippedFiles(r)
+	if r == nil || r.ReviewText == "" {
+		findingsLabel.SetText("")
+		return
+	}
+	text := "Findings: " + ai.SeveritySummary(r.Findings)
+	if r.BaseCommit != "" {
+		text += " (new commits since " + shortCommit(r.BaseCommit) + ")"
+	}
+	findingsLabel.SetText(text)
+}
+
+// showSkippedFiles lists the files the diff filters left out of the review.
+func showSkippedFiles(r *review.CodeReview) {
+	if r == nil || len(r.Skipped) == 0 {
+		skippedLabel.SetText("")
+		skippedLabel.Hide()
+		return
+	}
+	names := make([]string, 0, len(r.Skipped))
+	for _, skipped := range r.Skipped {
+		names = append(names, skipped.String())
+	}
+	skippedLabel.SetText(fmt.Sprintf("Skipped %d files: %s", len(names), strings.Join(names, ", ")))
+	skippedLabel.Show()
+}
+
+func shortCommit(sha string) string {
+	if len(sha) > 8 {
+		return sha[:8]
+	}
+	return sha
+}
+
+// showHistoryDialog lists the review rounds of a merge/pull request as a
+// thread, with the latest round expanded.
+func showHistoryDialog(r *review.CodeReview) {
+	runs := review.GetReviewHistory(r.ID)
+	if len(runs) == 0 {
+		dialog.ShowInformation("Review history", "No review rounds are stored for this review yet.", mainWindow)
+		return
+	}
+	items := make([]*widget.AccordionItem, 0, len(runs))
+	for i, run := range runs {
+		scope := "full review of " + shortCommit(run.Commit)
+		if run.BaseCommit != "" {
+			scope = fmt.Sprintf("commits %s..%s", shortCommit(run.BaseCommit), shortCommit(run.Commit))
+		}
+		title := fmt.Sprintf("Round %d · %s · %s · %s", i+1, run.CreatedAt.Format("2006-01-02 15:04"), scope, ai.SeveritySummary(run.Findings))
+		text := widget.NewLabel(run.Text)
+		text.Wrapping = fyne.TextWrapWord
+		items = append(items, widget.NewAccordionItem(title, text))
+	}
+	accordion := widget.NewAccordion(items...)
+	accordion.Open(len(items) - 1)
+
+	historyDialog := dialog.NewCustom("Review history: "+r.Title, "Close", container.NewVScroll(accordion), mainWindow)
+	historyDialog.Resize(fyne.NewSize(900, 700))
+	historyDialog.Show()
+}
+
+// showSubmitControls updates the verdict choice and the submit button for the
+// given review. The suggested verdict is applied only when the selection
+// changes, so that the periodic refresh does not override the user's choice.
+func showSubmitControls(r *review.CodeReview) {
+	if submitButton == nil || verdictSelect == nil {
+		return
+	}
+	// Lokalnej recenzji nie ma gdzie wysłać
+	if r == nil || r.ReviewText == "" || r.Source == "local" {
+		submitButton.Hide()
+		verdictSelect.Hide()
+		return
+	}
+	// W trakcie wysyłki przyciski odblokowuje dopiero jej wynik
+	submitting, _ := submittingReview.Load().(string)
+	if r.Accepted {
+		verdictSelect.SetSelected(r.Verdict.Label())
+		verdictSelect.Disable()
+		submitButton.SetText("Submitted")
+		submitButton.Disable()
+	} else if submitting != r.ID {
+		if verdictReviewID != r.ID || verdictSelect.Selected == "" {
+			verdictSelect.SetSelected(review.DefaultVerdict(*r).Label())
+		}
+		verdictSelect.Enable()
+		submitButton.SetText("Submit")
+		submitButton.Enable()
+	}
+	verdictReviewID = r.ID
+	verdictSelect.Show()
+	submitButton.Show()
+}
+
 func setStatus(text string) {
 	if statusInfo != nil {
 		statusInfo.SetText(text)
@@ -191,7 +273,11 @@ func StartUI() {
 	toolbar := buildToolbar(func() {
 		updateReviewsList(reviewsListContainer, reviewDetails)
 		setStatus("Review list refreshed.")
-	}, showSettingsDialog)
+	}, showSettingsDialog, func() {
+		showLocalReviewDialog(func() {
+			updateReviewsList(reviewsListContainer, reviewDetails)
+		})
+	})
 
 	statusInfo = widget.NewLabel("")
 
@@ -263,12 +349,13 @@ func StartUI() {
 	mainWindow.ShowAndRun()
 }
 
-func buildToolbar(refreshAction func(), settingsAction func()) *widget.Toolbar {
+func buildToolbar(refreshAction func(), settingsAction func(), localReviewAction func()) *widget.Toolbar {
 	title := widget.NewLabel("LazyReview - AI Code Review")
 	title.TextStyle = fyne.TextStyle{Bold: true}
 	return widget.NewToolbar(
 		widget.NewToolbarAction(theme.ViewRefreshIcon(), func() { refreshAction() }),
 		widget.NewToolbarSeparator(),
+		widget.NewToolbarAction(theme.FolderOpenIcon(), func() { localReviewAction() }),
 		widget.NewToolbarAction(theme.SettingsIcon(), func() { settingsAction() }),
 		widget.NewToolbarSpacer(),
 		widget.NewToolbarAction(theme.InfoIcon(), func() {
@@ -277,18 +364,97 @@ func buildToolbar(refreshAction func(), settingsAction func()) *widget.Toolbar {
 	)
 }
 
+// showLocalReviewDialog asks for a working copy and reviews its branch or
+// staged changes in the background; done runs when the review is ready.
+func showLocalReviewDialog(done func()) {
+	dirEntry := widget.NewEntry()
+	dirEntry.PlaceHolder = "Path to a local git repository"
+	browseButton := widget.NewButtonWithIcon("", theme.FolderOpenIcon(), func() {
+		dialog.ShowFolderOpen(func(dir fyne.ListableURI, err error) {
+			if err == nil && dir != nil {
+				dirEntry.SetText(dir.Path())
+			}
+		}, mainWindow)
+	})
+
+	baseEntry := widget.NewEntry()
+	baseEntry.PlaceHolder = "Base branch (empty = default branch of origin)"
+
+	stagedCheck := widget.NewCheck("Review staged changes instead of the branch", func(checked bool) {
+		if checked {
+			baseEntry.Disable()
+		} else {
+			baseEntry.Enable()
+		}
+	})
+
+	items := []*widget.FormItem{
+		{Text: "Repository", Widget: container.NewBorder(nil, nil, nil, browseButton, dirEntry)},
+		{Text: "Base", Widget: baseEntry},
+		{Text: "Staged", Widget: stagedCheck},
+	}
+	localDialog := dialog.NewForm("Review local repo", "Review", "Cancel", items, func(confirmed bool) {
+		if !confirmed || strings.TrimSpace(dirEntry.Text) == "" {
+			return
+		}
+		opts := review.LocalOptions{
+			Dir:    strings.TrimSpace(dirEntry.Text),
+			Base:   strings.TrimSpace(baseEntry.Text),
+			Staged: stagedCheck.Checked,
+		}
+		setStatus("Reviewing local repository...")
+		go func(cfg config.Config) {
+			r, err := review.ReviewLocal(context.Background(), &cfg, opts)
+			if err != nil {
+				setStatus(fmt.Sprintf("Local review failed: %v", err))
+				return
+			}
+			setStatus(fmt.Sprintf("Local review ready: %s", r.Title))
+			done()
+		}(*currentConfig)
+	}, mainWindow)
+	localDialog.Resize(fyne.NewSize(700, 250))
+	localDialog.Show()
+}
+
 func buildDetailsSection(reviewDetails *widget.Entry) fyne.CanvasObject {
-	acceptButton = widget.NewButton("Accept", func() {
-		if selectedReview != nil && !selectedReview.Accepted {
-			review.AcceptReview(selectedReview.ID)
-			selectedReview.Accepted = true
-			acceptButton.SetText("Accepted")
-			acceptButton.Disable()
-			setStatus(fmt.Sprintf("Review accepted: %s", selectedReview.Title))
+	verdictLabels := make([]string, 0, len(review.Verdicts))
+	for _, v := range review.Verdicts {
+		verdictLabels = append(verdictLabels, v.Label())
+	}
+	verdictSelect = widget.NewSelect(verdictLabels, nil)
+	verdictSelect.Hide()
+
+	submitButton = widget.NewButton("Submit", func() {
+		if selectedReview == nil || selectedReview.Accepted {
+			return
 		}
+		r := selectedReview
+		verdict := review.VerdictFromLabel(verdictSelect.Selected)
+		submitButton.SetText("Submitting...")
+		submitButton.Disable()
+		verdictSelect.Disable()
+		setStatus(fmt.Sprintf("Submitting review: %s", r.Title))
+		submittingReview.Store(r.ID)
+		// Wysyłka trwa kilka zapytań do API, więc nie blokujemy okna
+		go func(cfg config.Config) {
+			err := review.SubmitReview(context.Background(), &cfg, r.ID, verdict)
+			submittingReview.Store("")
+			if err != nil {
+				submitButton.SetText("Submit")
+				submitButton.Enable()
+				verdictSelect.Enable()
+				setStatus(fmt.Sprintf("Submitting review failed: %v", err))
+				return
+			}
+			r.Accepted = true
+			r.Verdict = verdict
+			submitButton.SetText("Submitted")
+			setStatus(fmt.Sprintf("Review submitted (%s): %s", verdict.Label(), r.Title))
+		}(*currentConfig)
 	})
-	acceptButton.Disable()
-	acceptButton.Hide()
+	submitButton.Disable()
+	submitButton.Hide()
 
 	var editButton *widget.Button
 	editButton = widget.NewButton("Edit", func() {
@@ -302,8 +468,10 @@ func buildDetailsSection(reviewDetails *widget.Entry) fyne.CanvasObject {
 			isEditing = true
 		} else {
 			// Aktualizacja recenzji o zmieniony tekst przed zapisaniem
-			if selectedReview != nil {
+			if selectedReview != nil && selectedReview.ReviewText != reviewDetails.Text {
+				review.UpdateReviewText(selectedReview.ID, reviewDetails.Text)
 				selectedReview.ReviewText = reviewDetails.Text
+				selectedReview.Edited = true
 			}
 			reviewDetails.Disable()
 			editButton.SetText("Edit")
@@ -311,18 +479,35 @@ func buildDetailsSection(reviewDetails *widget.Entry) fyne.CanvasObject {
 		}
 	})
 
+	historyButton := widget.NewButton("History", func() {
+		if selectedReview != nil {
+			showHistoryDialog(selectedReview)
+		}
+	})
+
 	detailsLabel := widget.NewLabel("Review Details:")
 	detailsLabel.TextStyle = fyne.TextStyle{Bold: true}
 
+	findingsLabel = widget.NewLabel("")
+
+	skippedLabel = widget.NewLabel("")
+	skippedLabel.TextStyle = fyne.TextStyle{Italic: true}
+	skippedLabel.Wrapping = fyne.TextWrapWord
+	skippedLabel.Hide()
+
 	headerRow := container.NewHBox(
 		detailsLabel,
+		findingsLabel,
 		layout.NewSpacer(),
-		acceptButton,
+		verdictSelect,
+		submitButton,
+		historyButton,
 		editButton,
 	)
 
 	headerContainer := container.NewVBox(
 		headerRow,
+		skippedLabel,
 		widget.NewSeparator(),
 	)
 
@@ -371,30 +556,51 @@ func hideWindow() {
 
 func showSettingsDialog() {
 	var settingsDialog dialog.Dialog // dodana zmienna dla dialogu
+	// Zmiany trafiają do kopii, działające monitorowanie widzi je dopiero po zapisie
+	draft := *currentConfig
 
 	gitlabEnabledCheck := widget.NewCheck("Enable GitLab", func(enabled bool) {
-		currentConfig.GitLabConfig.Enabled = enabled
+		draft.GitLabConfig.Enabled = enabled
 	})
-	gitlabEnabledCheck.Checked = currentConfig.GitLabConfig.Enabled
+	gitlabEnabledCheck.Checked = draft.GitLabConfig.Enabled
 
 	gitlabUrlEntry := widget.NewEntry()
-	gitlabUrlEntry.SetText(currentConfig.GitLabConfig.ApiUrl)
+	gitlabUrlEntry.SetText(draft.GitLabConfig.ApiUrl)
 	gitlabUrlEntry.PlaceHolder = "e.g. gitlab.com or gitlab.hlag.altemista.cloud"
 
 	gitlabTokenEntry := widget.NewPasswordEntry()
-	gitlabTokenEntry.SetText(currentConfig.GitLabConfig.ApiToken)
+	gitlabTokenEntry.SetText(draft.GitLabConfig.ApiToken)
 	gitlabTokenEntry.PlaceHolder = "Personal Access Token"
 
+	gitlabUserInfo := widget.NewLabel("")
+	gitlabUserInfo.TextStyle = fyne.TextStyle{Italic: true}
+	if draft.GitLabConfig.ApiToken != "" {
+		gitlabUserInfo.SetText("Resolving GitLab user...")
+		go func(cfg config.Config) {
+			user, err := gitlab.GetCurrentUser(context.Background(), &cfg)
+			if err != nil {
+				gitlabUserInfo.SetText("Could not resolve GitLab user for this token")
+				return
+			}
+			gitlabUserInfo.SetText(fmt.Sprintf("Signed in as %s (%s)", user.Username, user.Name))
+		}(draft)
+	}
+
+	gitlabTokenContainer := container.NewVBox(
+		gitlabTokenEntry,
+		gitlabUserInfo,
+	)
+
 	githubEnabledCheck := widget.NewCheck("Enable GitHub", func(enabled bool) {
-		currentConfig.GitHubConfig.Enabled = enabled
+		draft.GitHubConfig.Enabled = enabled
 	})
-	githubEnabledCheck.Checked = currentConfig.GitHubConfig.Enabled
+	githubEnabledCheck.Checked = draft.GitHubConfig.Enabled
 
 	githubTokenEntry := widget.NewPasswordEntry()
-	githubTokenEntry.SetText(currentConfig.GitHubConfig.ApiToken)
+	githubTokenEntry.SetText(draft.GitHubConfig.ApiToken)
 	githubTokenEntry.PlaceHolder = "Personal Access Token"
 
-	githubApiInfo := widget.NewLabel("GitHub API uses the standard URL: https://api.github.com")
+	githubApiInfo := widget.NewLabel("GitHub API URL: " + draft.GitHubConfig.GetGitHubApiUrl())
 	githubApiInfo.TextStyle = fyne.TextStyle{Italic: true}
 	githubApiInfo.Alignment = fyne.TextAlignLeading
 
@@ -403,23 +609,176 @@ func showSettingsDialog() {
 		githubApiInfo,
 	)
 
+	gitlabWatchCheck := widget.NewCheck("Monitor all open MRs in the listed projects", nil)
+	gitlabWatchCheck.Checked = draft.GitLabConfig.WatchListMode
+
+	gitlabProjectsEntry := widget.NewMultiLineEntry()
+	gitlabProjectsEntry.SetText(strings.Join(draft.GitLabConfig.ProjectIDs, "\n"))
+	gitlabProjectsEntry.PlaceHolder = "One project ID or path per line, e.g. group/project"
+	gitlabProjectsEntry.SetMinRowsVisible(3)
+
+	gitlabFilterEditor, gitlabFilter := newWatchFilterEditor(draft.GitLabConfig.WatchFilter)
+
+	gitlabWatchContainer := container.NewVBox(
+		gitlabWatchCheck,
+		gitlabProjectsEntry,
+		gitlabFilterEditor,
+	)
+
+	githubWatchCheck := widget.NewCheck("Monitor all open PRs in the listed repositories", nil)
+	githubWatchCheck.Checked = draft.GitHubConfig.WatchListMode
+
+	githubReposEntry := widget.NewMultiLineEntry()
+	githubReposEntry.SetText(strings.Join(draft.GitHubConfig.Repositories, "\n"))
+	githubReposEntry.PlaceHolder = "One repository per line, e.g. owner/repo"
+	githubReposEntry.SetMinRowsVisible(3)
+
+	githubFilterEditor, githubFilter := newWatchFilterEditor(draft.GitHubConfig.WatchFilter)
+
+	githubWatchContainer := container.NewVBox(
+		githubWatchCheck,
+		githubReposEntry,
+		githubFilterEditor,
+	)
+
+	storageSelect := widget.NewSelect([]string{"json", "bolt"}, nil)
+	storageSelect.SetSelected(draft.StorageBackend)
+
+	storageInfo := widget.NewLabel("bolt keeps the state and the history of posted reviews in ~/.lazyreview.db; takes effect after restart")
+	storageInfo.TextStyle = fyne.TextStyle{Italic: true}
+	storageInfo.Alignment = fyne.TextAlignLeading
+
+	storageContainer := container.NewVBox(
+		storageSelect,
+		storageInfo,
+	)
+
 	mergeRequestsIntervalEntry := widget.NewEntry()
-	mergeRequestsIntervalEntry.SetText(strconv.Itoa(currentConfig.MergeRequestsPollingInterval))
+	mergeRequestsIntervalEntry.SetText(strconv.Itoa(draft.MergeRequestsPollingInterval))
 	mergeRequestsIntervalUnit := widget.NewLabel("seconds")
 	mergeRequestsLayout := container.NewHBox(mergeRequestsIntervalEntry, mergeRequestsIntervalUnit)
 
 	reviewRequestsIntervalEntry := widget.NewEntry()
-	reviewRequestsIntervalEntry.SetText(strconv.Itoa(currentConfig.ReviewRequestsPollingInterval))
+	reviewRequestsIntervalEntry.SetText(strconv.Itoa(draft.ReviewRequestsPollingInterval))
 	reviewRequestsIntervalUnit := widget.NewLabel("seconds")
 	reviewRequestsLayout := container.NewHBox(reviewRequestsIntervalEntry, reviewRequestsIntervalUnit)
 
-	openaiTokenEntry := widget.NewPasswordEntry()
-	openaiTokenEntry.SetText(currentConfig.AIModelConfig.ApiKey)
-	openaiTokenEntry.PlaceHolder = "OpenAI API Token"
+	reviewWorkersEntry := widget.NewEntry()
+	reviewWorkersEntry.SetText(strconv.Itoa(draft.ReviewWorkers))
+	reviewWorkersUnit := widget.NewLabel("reviews at a time")
+	reviewWorkersLayout := container.NewHBox(reviewWorkersEntry, reviewWorkersUnit)
+
+	incrementalCheck := widget.NewCheck("Review only commits pushed since the last review", nil)
+	incrementalCheck.SetChecked(draft.IncrementalReviews)
+
+	includeEntry := widget.NewMultiLineEntry()
+	includeEntry.SetText(strings.Join(draft.DiffFilter.Include, "\n"))
+	includeEntry.PlaceHolder = "Review only matching files, e.g. src/**/*.go (empty = all files)"
+
+	exclude := draft.DiffFilter.Exclude
+	if exclude == nil {
+		exclude = filter.DefaultExclude
+	}
+	excludeEntry := widget.NewMultiLineEntry()
+	excludeEntry.SetText(strings.Join(exclude, "\n"))
+	excludeEntry.PlaceHolder = "Skip matching files, e.g. vendor/ or *.min.js (empty = skip nothing)"
+
+	skipGeneratedCheck := widget.NewCheck("Skip generated files (DO NOT EDIT headers, linguist-generated)", nil)
+	skipGeneratedCheck.SetChecked(draft.DiffFilter.SkipGenerated)
+
+	diffFilterContainer := container.NewVBox(
+		includeEntry,
+		excludeEntry,
+		skipGeneratedCheck,
+	)
+
+	fileContextSelect := widget.NewSelect([]string{config.FileContextOff, config.FileContextFull, config.FileContextLines}, nil)
+	fileContextSelect.SetSelected(draft.FileContext.Mode)
+	if fileContextSelect.Selected == "" {
+		fileContextSelect.SetSelected(config.FileContextOff)
+	}
+	contextLinesEntry := widget.NewEntry()
+	contextLinesEntry.SetText(strconv.Itoa(draft.FileContext.Lines))
+	contextLinesUnit := widget.NewLabel("lines around changes")
+	contextTokensEntry := widget.NewEntry()
+	contextTokensEntry.SetText(strconv.Itoa(draft.FileContext.MaxTokens))
+	contextTokensUnit := widget.NewLabel("tokens at most (0 = half of the model's budget)")
+
+	fileContextContainer := container.NewVBox(
+		fileContextSelect,
+		container.NewHBox(contextLinesEntry, contextLinesUnit),
+		container.NewHBox(contextTokensEntry, contextTokensUnit),
+	)
+
+	webhookCheck := widget.NewCheck("Receive GitLab and GitHub webhooks (polling stays as a fallback)", nil)
+	webhookCheck.SetChecked(draft.Webhook.Enabled)
+	webhookListenEntry := widget.NewEntry()
+	webhookListenEntry.SetText(draft.Webhook.Listen)
+	webhookListenEntry.PlaceHolder = "Listen address, e.g. " + config.DefaultWebhookListen
+	webhookGitLabTokenEntry := widget.NewPasswordEntry()
+	webhookGitLabTokenEntry.SetText(draft.Webhook.GitLabToken)
+	webhookGitLabTokenEntry.PlaceHolder = "GitLab secret token"
+	webhookGitHubSecretEntry := widget.NewPasswordEntry()
+	webhookGitHubSecretEntry.SetText(draft.Webhook.GitHubSecret)
+	webhookGitHubSecretEntry.PlaceHolder = "GitHub webhook secret"
+	webhookInfo := widget.NewLabel("Hook URLs: " + webhook.GitLabPath + " and " + webhook.GitHubPath)
+	webhookInfo.TextStyle = fyne.TextStyle{Italic: true}
+	webhookInfo.Alignment = fyne.TextAlignLeading
+
+	webhookContainer := container.NewVBox(
+		webhookCheck,
+		webhookListenEntry,
+		webhookGitLabTokenEntry,
+		webhookGitHubSecretEntry,
+		webhookInfo,
+	)
+
+	aiProviderSelect := widget.NewSelect(ai.Providers(), nil)
+	aiProviderSelect.SetSelected(draft.AIModelConfig.Provider)
+
+	aiTokenEntry := widget.NewPasswordEntry()
+	aiTokenEntry.SetText(draft.AIModelConfig.ApiKey)
+	aiTokenEntry.PlaceHolder = "API key (optional for self-hosted models)"
+
+	aiUrlEntry := widget.NewEntry()
+	aiUrlEntry.SetText(draft.AIModelConfig.ApiUrl)
+	aiUrlEntry.PlaceHolder = "e.g. http://localhost:11434 or http://gateway.internal/v1"
+
+	aiUrlInfo := widget.NewLabel("Leave empty to use the provider's default endpoint")
+	aiUrlInfo.TextStyle = fyne.TextStyle{Italic: true}
+	aiUrlInfo.Alignment = fyne.TextAlignLeading
+
+	aiUrlContainer := container.NewVBox(
+		aiUrlEntry,
+		aiUrlInfo,
+	)
+
+	azureEndpointEntry := widget.NewEntry()
+	azureEndpointEntry.SetText(draft.AIModelConfig.AzureEndpoint)
+	azureEndpointEntry.PlaceHolder = "e.g. https://my-resource.openai.azure.com"
 
-	openaiModelEntry := widget.NewEntry()
-	openaiModelEntry.SetText(currentConfig.AIModelConfig.Model)
-	openaiModelEntry.PlaceHolder = "OpenAI Model (e.g. GPT-4o)"
+	azureDeploymentEntry := widget.NewEntry()
+	azureDeploymentEntry.SetText(draft.AIModelConfig.AzureDeployment)
+	azureDeploymentEntry.PlaceHolder = "Deployment name"
+
+	azureApiVersionEntry := widget.NewEntry()
+	azureApiVersionEntry.SetText(draft.AIModelConfig.AzureApiVersion)
+	azureApiVersionEntry.PlaceHolder = config.DefaultAzureApiVersion
+
+	azureInfo := widget.NewLabel("Used only when the AI provider is set to azure")
+	azureInfo.TextStyle = fyne.TextStyle{Italic: true}
+	azureInfo.Alignment = fyne.TextAlignLeading
+
+	azureContainer := container.NewVBox(
+		azureEndpointEntry,
+		azureDeploymentEntry,
+		azureApiVersionEntry,
+		azureInfo,
+	)
+
+	aiModelEntry := widget.NewEntry()
+	aiModelEntry.SetText(draft.AIModelConfig.Model)
+	aiModelEntry.PlaceHolder = "Model (e.g. gpt-4o or claude-sonnet-4-5)"
 
 	gitlabUrlInfo := widget.NewLabel("Enter only the GitLab domain; 'https://' and '/api/v4' will be added automatically")
 	gitlabUrlInfo.TextStyle = fyne.TextStyle{Italic: true}
@@ -434,47 +793,143 @@ func showSettingsDialog() {
 		Items: []*widget.FormItem{
 			{Text: "GitLab", Widget: gitlabEnabledCheck},
 			{Text: "GitLab URL", Widget: gitlabUrlContainer},
-			{Text: "GitLab Token", Widget: gitlabTokenEntry},
+			{Text: "GitLab Token", Widget: gitlabTokenContainer},
+			{Text: "GitLab watch list", Widget: gitlabWatchContainer},
 			{Text: "GitHub", Widget: githubEnabledCheck},
 			{Text: "GitHub Token", Widget: githubContainer},
-			{Text: "OpenAI API Token", Widget: openaiTokenEntry},
-			{Text: "OpenAI Model", Widget: openaiModelEntry},
+			{Text: "GitHub watch list", Widget: githubWatchContainer},
+			{Text: "AI Provider", Widget: aiProviderSelect},
+			{Text: "AI API URL", Widget: aiUrlContainer},
+			{Text: "AI API Key", Widget: aiTokenEntry},
+			{Text: "AI Model", Widget: aiModelEntry},
+			{Text: "Azure OpenAI", Widget: azureContainer},
 			{Text: "MR/PR polling interval", Widget: mergeRequestsLayout},
 			{Text: "Review polling interval", Widget: reviewRequestsLayout},
+			{Text: "Webhooks", Widget: webhookContainer},
+			{Text: "Review workers", Widget: reviewWorkersLayout},
+			{Text: "Incremental reviews", Widget: incrementalCheck},
+			{Text: "Diff filters", Widget: diffFilterContainer},
+			{Text: "File context", Widget: fileContextContainer},
+			{Text: "State storage", Widget: storageContainer},
 		},
 	}
 
 	saveButton := widget.NewButton("Save", func() {
-		currentConfig.GitLabConfig.ApiUrl = gitlabUrlEntry.Text
-		currentConfig.GitLabConfig.ApiToken = gitlabTokenEntry.Text
-		currentConfig.GitHubConfig.ApiToken = githubTokenEntry.Text
-		currentConfig.AIModelConfig.ApiKey = openaiTokenEntry.Text
-		currentConfig.AIModelConfig.Model = openaiModelEntry.Text
+		draft.GitLabConfig.ApiUrl = gitlabUrlEntry.Text
+		draft.GitLabConfig.ApiToken = gitlabTokenEntry.Text
+		draft.GitHubConfig.ApiToken = githubTokenEntry.Text
+		if storageSelect.Selected != "" {
+			draft.StorageBackend = storageSelect.Selected
+		}
+		draft.GitLabConfig.WatchListMode = gitlabWatchCheck.Checked
+		draft.GitLabConfig.ProjectIDs = splitList(gitlabProjectsEntry.Text)
+		draft.GitLabConfig.WatchFilter = gitlabFilter()
+		draft.GitHubConfig.WatchListMode = githubWatchCheck.Checked
+		draft.GitHubConfig.Repositories = splitList(githubReposEntry.Text)
+		draft.GitHubConfig.WatchFilter = githubFilter()
+		if aiProviderSelect.Selected != "" {
+			draft.AIModelConfig.Provider = aiProviderSelect.Selected
+		}
+		draft.AIModelConfig.ApiUrl = aiUrlEntry.Text
+		draft.AIModelConfig.ApiKey = aiTokenEntry.Text
+		draft.AIModelConfig.Model = aiModelEntry.Text
+		draft.AIModelConfig.AzureEndpoint = azureEndpointEntry.Text
+		draft.AIModelConfig.AzureDeployment = azureDeploymentEntry.Text
+		draft.AIModelConfig.AzureApiVersion = azureApiVersionEntry.Text
 
 		mrInterval, err := strconv.Atoi(mergeRequestsIntervalEntry.Text)
 		if err == nil && mrInterval > 0 {
-			currentConfig.MergeRequestsPollingInterval = mrInterval
+			draft.MergeRequestsPollingInterval = mrInterval
 		}
 		rrInterval, err := strconv.Atoi(reviewRequestsIntervalEntry.Text)
 		if err == nil && rrInterval > 0 {
-			currentConfig.ReviewRequestsPollingInterval = rrInterval
+			draft.ReviewRequestsPollingInterval = rrInterval
 		}
-		err = config.SaveConfig(currentConfig)
+		draft.IncrementalReviews = incrementalCheck.Checked
+		draft.DiffFilter.Include = splitList(includeEntry.Text)
+		draft.DiffFilter.Exclude = splitList(excludeEntry.Text)
+		if slices.Equal(draft.DiffFilter.Exclude, filter.DefaultExclude) {
+			// Niezmienione domyślne wzorce nie trafiają do konfiguracji, żeby nowe wersje mogły je rozszerzać
+			draft.DiffFilter.Exclude = nil
+		}
+		draft.DiffFilter.SkipGenerated = skipGeneratedCheck.Checked
+		if fileContextSelect.Selected != "" {
+			draft.FileContext.Mode = fileContextSelect.Selected
+		}
+		contextLines, err := strconv.Atoi(contextLinesEntry.Text)
+		if err == nil && contextLines > 0 {
+			draft.FileContext.Lines = contextLines
+		}
+		contextTokens, err := strconv.Atoi(contextTokensEntry.Text)
+		if err == nil && contextTokens >= 0 {
+			draft.FileContext.MaxTokens = contextTokens
+		}
+		draft.Webhook.Enabled = webhookCheck.Checked
+		draft.Webhook.Listen = strings.TrimSpace(webhookListenEntry.Text)
+		draft.Webhook.GitLabToken = webhookGitLabTokenEntry.Text
+		draft.Webhook.GitHubSecret = webhookGitHubSecretEntry.Text
+		workers, err := strconv.Atoi(reviewWorkersEntry.Text)
+		if err == nil && workers > 0 {
+			draft.ReviewWorkers = workers
+		}
+		err = config.SaveConfig(&draft)
 		if err != nil {
 			dialog.ShowError(err, mainWindow)
 			return
 		}
-		business.RestartMonitoring(currentConfig)
+		saved := draft
+		currentConfig = &saved
+		// Monitorowanie dostaje własną kopię, kolejne edycje jej nie dotkną
+		running := draft
+		business.RestartMonitoring(&running)
 		dialog.NewInformation("Settings saved", "Settings saved", mainWindow).Show()
 		setStatus("Settings saved.")
 		settingsDialog.Hide() // zamykamy okno ustawień
 	})
 
-	content := container.NewVBox(
+	content := container.NewVScroll(container.NewVBox(
 		form,
 		saveButton,
-	)
+	))
 
 	settingsDialog = dialog.NewCustom("Settings", "Close", content, mainWindow)
+	settingsDialog.Resize(fyne.NewSize(900, 750))
 	settingsDialog.Show()
 }
+
+// newWatchFilterEditor returns the widgets editing a watch filter and a
+// function reading the edited values back.
+func newWatchFilterEditor(filter config.WatchFilter) (fyne.CanvasObject, func() config.WatchFilter) {
+	labelsEntry := widget.NewEntry()
+	labelsEntry.SetText(strings.Join(filter.Labels, ", "))
+	labelsEntry.PlaceHolder = "Labels (comma separated, all required)"
+
+	targetBranchEntry := widget.NewEntry()
+	targetBranchEntry.SetText(filter.TargetBranch)
+	targetBranchEntry.PlaceHolder = "Target branch (e.g. main)"
+
+	authorsEntry := widget.NewEntry()
+	authorsEntry.SetText(strings.Join(filter.Authors, ", "))
+	authorsEntry.PlaceHolder = "Authors (comma separated usernames)"
+
+	editor := container.NewVBox(labelsEntry, targetBranchEntry, authorsEntry)
+	return editor, func() config.WatchFilter {
+		return config.WatchFilter{
+			Labels:       splitList(labelsEntry.Text),
+			TargetBranch: strings.TrimSpace(targetBranchEntry.Text),
+			Authors:      splitList(authorsEntry.Text),
+		}
+	}
+}
+
+// splitList splits text separated by commas or new lines into trimmed,
+// non-empty items.
+func splitList(text string) []string {
+	items := []string{}
+	for _, item := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,124 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	neturl "net/url"
+	"strings"
+
+	"github.com/michalopenmakers/lazyreview/config"
+	"github.com/michalopenmakers/lazyreview/logger"
+	"github.com/michalopenmakers/lazyreview/review"
+)
+
+type githubEvent struct {
+	Action      string `json:"action"`
+	PullRequest struct {
+		Number int `json:"number"`
+	} `json:"pull_request"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// pullRequestActions are the pull_request actions that can bring new commits
+// or make the pull request monitored.
+var pullRequestActions = map[string]bool{
+	"opened":           true,
+	"reopened":         true,
+	"synchronize":      true,
+	"ready_for_review": true,
+	"assigned":         true,
+	"labeled":          true,
+}
+
+// handleGitHub accepts "pull_request" and "pull_request_review_comment"
+// deliveries signed with the webhook secret. Both JSON and form encoded
+// payloads are supported. A new review comment queues the pull request like
+// polling would.
+func handleGitHub(ctx context.Context, cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	body, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+	if !cfg.GitHubConfig.Enabled {
+		http.Error(w, "GitHub integration is disabled", http.StatusNotFound)
+		return
+	}
+	if cfg.Webhook.GitHubSecret == "" {
+		logger.Log("Rejecting GitHub webhook: no secret configured")
+		http.Error(w, "webhook secret not configured", http.StatusForbidden)
+		return
+	}
+	if !validSignature(cfg.Webhook.GitHubSecret, r.Header.Get("X-Hub-Signature-256"), body) {
+		logger.Log(fmt.Sprintf("Rejecting GitHub webhook from %s: invalid signature", r.RemoteAddr))
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	payload := body
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/x-www-form-urlencoded" {
+		form, err := neturl.ParseQuery(string(body))
+		if err != nil {
+			http.Error(w, "invalid payload", http.StatusBadRequest)
+			return
+		}
+		payload = []byte(form.Get("payload"))
+	}
+
+	eventName := r.Header.Get("X-GitHub-Event")
+	if eventName == "ping" {
+		ignore(w, "ping")
+		return
+	}
+	var event githubEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	switch eventName {
+	case "pull_request":
+		if !pullRequestActions[event.Action] {
+			ignore(w, "action "+event.Action)
+			return
+		}
+	case "pull_request_review_comment":
+		if event.Action != "created" {
+			ignore(w, "action "+event.Action)
+			return
+		}
+	default:
+		ignore(w, "event "+eventName)
+		return
+	}
+	repository, prID := event.Repository.FullName, event.PullRequest.Number
+	if repository == "" || prID == 0 {
+		http.Error(w, "missing repository or pull request", http.StatusBadRequest)
+		return
+	}
+
+	accept(ctx, w, fmt.Sprintf("PR #%d in %s", prID, repository), func(ctx context.Context) error {
+		return review.EnqueuePullRequest(ctx, cfg, repository, prID)
+	})
+}
+
+// validSignature checks the "sha256=<hex>" HMAC of the body.
+func validSignature(secret, signature string, body []byte) bool {
+	sum, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	expected, err := hex.DecodeString(sum)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,111 @@
+package webhook
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/michalopenmakers/lazyreview/config"
+	"github.com/michalopenmakers/lazyreview/logger"
+	"github.com/michalopenmakers/lazyreview/review"
+)
+
+type gitlabEvent struct {
+	ObjectKind string `json:"object_kind"`
+	Project    struct {
+		ID int `json:"id"`
+	} `json:"project"`
+	ObjectAttributes struct {
+		IID          int    `json:"iid"`
+		Action       string `json:"action"`
+		OldRev       string `json:"oldrev"`
+		NoteableType string `json:"noteable_type"`
+	} `json:"object_attributes"`
+	MergeRequest struct {
+		IID int `json:"iid"`
+	} `json:"merge_request"`
+	Changes map[string]json.RawMessage `json:"changes"`
+}
+
+// scopeChanges are the merge request attributes that can make it monitored.
+var scopeChanges = []string{"reviewers", "labels", "target_branch"}
+
+// handleGitLab accepts "Merge Request Hook" and "Note Hook" deliveries
+// authenticated with the secret token. A comment goes through the same checks
+// as polling, so a reply to our comment releases a merge request that waited
+// for it without waiting for the next poll.
+func handleGitLab(ctx context.Context, cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	body, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+	if !cfg.GitLabConfig.Enabled {
+		http.Error(w, "GitLab integration is disabled", http.StatusNotFound)
+		return
+	}
+	if cfg.Webhook.GitLabToken == "" {
+		logger.Log("Rejecting GitLab webhook: no secret token configured")
+		http.Error(w, "webhook token not configured", http.StatusForbidden)
+		return
+	}
+	token := r.Header.Get("X-Gitlab-Token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(cfg.Webhook.GitLabToken)) != 1 {
+		logger.Log(fmt.Sprintf("Rejecting GitLab webhook from %s: invalid token", r.RemoteAddr))
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	var event gitlabEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	var mrID int
+	switch event.ObjectKind {
+	case "merge_request":
+		switch event.ObjectAttributes.Action {
+		case "open", "reopen":
+		case "update":
+			// Zmiana tytułu czy opisu nie wymaga nowej recenzji
+			if event.ObjectAttributes.OldRev == "" && !changesScope(event.Changes) {
+				ignore(w, "no new commits")
+				return
+			}
+		default:
+			ignore(w, "action "+event.ObjectAttributes.Action)
+			return
+		}
+		mrID = event.ObjectAttributes.IID
+	case "note":
+		if event.ObjectAttributes.NoteableType != "MergeRequest" {
+			ignore(w, "not a merge request note")
+			return
+		}
+		mrID = event.MergeRequest.IID
+	default:
+		ignore(w, "event "+r.Header.Get("X-Gitlab-Event"))
+		return
+	}
+	if event.Project.ID == 0 || mrID == 0 {
+		http.Error(w, "missing project or merge request", http.StatusBadRequest)
+		return
+	}
+
+	projectID := strconv.Itoa(event.Project.ID)
+	accept(ctx, w, fmt.Sprintf("MR #%d in project %s", mrID, projectID), func(ctx context.Context) error {
+		return review.EnqueueMergeRequest(ctx, cfg, projectID, mrID)
+	})
+}
+
+func changesScope(changes map[string]json.RawMessage) bool {
+	for _, key := range scopeChanges {
+		if _, ok := changes[key]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+// Package webhook receives merge and pull request events from GitLab and
+// GitHub and queues their reviews without waiting for the next poll.
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/michalopenmakers/lazyreview/config"
+	"github.com/michalopenmakers/lazyreview/logger"
+)
+
+const (
+	GitLabPath = "/webhook/gitlab"
+	GitHubPath = "/webhook/github"
+)
+
+// maxBodySize caps the payload read from a delivery; merge and pull request
+// events are far smaller.
+const maxBodySize = 5 << 20
+
+var (
+	serverMutex  sync.Mutex
+	server       *http.Server
+	cancelEvents context.CancelFunc
+)
+
+// Start runs the webhook server in the background when it is enabled in the
+// configuration. A server that cannot listen is logged and left stopped;
+// polling still picks up the changes.
+func Start(cfg *config.Config) {
+	if !cfg.Webhook.Enabled {
+		return
+	}
+	serverMutex.Lock()
+	defer serverMutex.Unlock()
+	if server != nil {
+		return
+	}
+	addr := strings.TrimSpace(cfg.Webhook.Listen)
+	if addr == "" {
+		addr = config.DefaultWebhookListen
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		logger.Log(fmt.Sprintf("Error starting webhook server: %v", err))
+		return
+	}
+
+	var ctx context.Context
+	ctx, cancelEvents = context.WithCancel(context.Background())
+	server = &http.Server{
+		Handler:           newHandler(ctx, cfg),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	logger.Log(fmt.Sprintf("Webhook server listening on %s", listener.Addr()))
+	go func(srv *http.Server) {
+		if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Log(fmt.Sprintf("Webhook server stopped: %v", err))
+		}
+	}(server)
+}
+
+// Stop shuts the webhook server down, waiting briefly for deliveries being
+// answered. Events still being looked up are cancelled.
+func Stop() {
+	serverMutex.Lock()
+	defer serverMutex.Unlock()
+	if server == nil {
+		return
+	}
+	cancelEvents()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Log(fmt.Sprintf("Error stopping webhook server: %v", err))
+	}
+	server = nil
+	logger.Log("Webhook server stopped")
+}
+
+func newHandler(ctx context.Context, cfg *config.Config) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(GitLabPath, func(w http.ResponseWriter, r *http.Request) {
+		handleGitLab(ctx, cfg, w, r)
+	})
+	mux.HandleFunc(GitHubPath, func(w http.ResponseWriter, r *http.Request) {
+		handleGitHub(ctx, cfg, w, r)
+	})
+	return mux
+}
+
+// readBody reads the delivery, answering the request itself when it is not
+// a POST or the body cannot be read.
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, "cannot read body", http.StatusBadRequest)
+		return nil, false
+	}
+	return body, true
+}
+
+// accept answers the delivery and looks the change up in the background, so
+// the forge does not time out waiting for the API calls.
+func accept(ctx context.Context, w http.ResponseWriter, what string, enqueue func(ctx context.Context) error) {
+	logger.Log(fmt.Sprintf("Webhook event for %s received", what))
+	w.WriteHeader(http.StatusAccepted)
+	_, _ = io.WriteString(w, "queued\n")
+	go func() {
+		if err := enqueue(ctx); err != nil && ctx.Err() == nil {
+			logger.Log(fmt.Sprintf("Error queueing review of %s: %v", what, err))
+		}
+	}()
+}
+
+// ignore answers a valid delivery that does not need a review.
+func ignore(w http.ResponseWriter, reason string) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.WriteString(w, "ignored: "+reason+"\n")
+}
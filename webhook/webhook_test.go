@@ -0,0 +1,229 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"strings"
+	"testing"
+
+	"github.com/michalopenmakers/lazyreview/config"
+)
+
+const (
+	testToken  = "gitlab-token"
+	testSecret = "github-secret"
+)
+
+func testConfig() *config.Config {
+	return &config.Config{
+		GitLabConfig: config.GitLabConfig{Enabled: true},
+		GitHubConfig: config.GitHubConfig{Enabled: true},
+		Webhook: config.WebhookConfig{
+			Enabled:      true,
+			GitLabToken:  testToken,
+			GitHubSecret: testSecret,
+		},
+	}
+}
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliver sends a request to the webhook handler and returns the response
+// status and body.
+func deliver(t *testing.T, cfg *config.Config, method, path string, header http.Header, body string) (int, string) {
+	t.Helper()
+	// Anulowany kontekst: zaakceptowane zdarzenia nie odpytują API
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	newHandler(ctx, cfg).ServeHTTP(rec, req)
+	return rec.Code, rec.Body.String()
+}
+
+func TestGitLab(t *testing.T) {
+	mrEvent := func(action, extra string) string {
+		return `{"object_kind": "merge_request", "project": {"id": 42}, "object_attributes": {"iid": 7, "action": "` + action + `"` + extra + `}}`
+	}
+	tests := []struct {
+		name     string
+		method   string
+		token    string
+		cfg      func(cfg *config.Config)
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{name: "opened", token: testToken, body: mrEvent("open", ""), wantCode: http.StatusAccepted},
+		{name: "reopened", token: testToken, body: mrEvent("reopen", ""), wantCode: http.StatusAccepted},
+		{name: "push", token: testToken, body: mrEvent("update", `, "oldrev": "abc123"`), wantCode: http.StatusAccepted},
+		{
+			name:     "reviewer added",
+			token:    testToken,
+			body:     `{"object_kind": "merge_request", "project": {"id": 42}, "object_attributes": {"iid": 7, "action": "update"}, "changes": {"reviewers": {}}}`,
+			wantCode: http.StatusAccepted,
+		},
+		{name: "title edited", token: testToken, body: mrEvent("update", ""), wantCode: http.StatusOK, wantBody: "ignored: no new commits"},
+		{name: "merged", token: testToken, body: mrEvent("merge", ""), wantCode: http.StatusOK, wantBody: "ignored: action merge"},
+		{
+			name:     "note",
+			token:    testToken,
+			body:     `{"object_kind": "note", "project": {"id": 42}, "object_attributes": {"noteable_type": "MergeRequest"}, "merge_request": {"iid": 7}}`,
+			wantCode: http.StatusAccepted,
+		},
+		{
+			name:     "issue note",
+			token:    testToken,
+			body:     `{"object_kind": "note", "project": {"id": 42}, "object_attributes": {"noteable_type": "Issue"}, "issue": {"iid": 7}}`,
+			wantCode: http.StatusOK,
+			wantBody: "ignored: not a merge request note",
+		},
+		{name: "pipeline", token: testToken, body: `{"object_kind": "pipeline", "project": {"id": 42}}`, wantCode: http.StatusOK, wantBody: "ignored: event"},
+		{name: "missing merge request", token: testToken, body: `{"object_kind": "merge_request", "object_attributes": {"action": "open"}}`, wantCode: http.StatusBadRequest},
+		{name: "invalid payload", token: testToken, body: `{"object_kind": `, wantCode: http.StatusBadRequest},
+		{name: "wrong token", token: "guess", body: mrEvent("open", ""), wantCode: http.StatusUnauthorized},
+		{name: "no token", body: mrEvent("open", ""), wantCode: http.StatusUnauthorized},
+		{
+			name:     "token not configured",
+			cfg:      func(cfg *config.Config) { cfg.Webhook.GitLabToken = "" },
+			body:     mrEvent("open", ""),
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "GitLab disabled",
+			token:    testToken,
+			cfg:      func(cfg *config.Config) { cfg.GitLabConfig.Enabled = false },
+			body:     mrEvent("open", ""),
+			wantCode: http.StatusNotFound,
+		},
+		{name: "GET", method: http.MethodGet, token: testToken, wantCode: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			if tt.cfg != nil {
+				tt.cfg(cfg)
+			}
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			header := http.Header{"X-Gitlab-Event": {"Merge Request Hook"}}
+			if tt.token != "" {
+				header.Set("X-Gitlab-Token", tt.token)
+			}
+			code, body := deliver(t, cfg, method, GitLabPath, header, tt.body)
+			if code != tt.wantCode || !strings.Contains(body, tt.wantBody) {
+				t.Errorf("got %d %q, want %d %q", code, body, tt.wantCode, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestGitHub(t *testing.T) {
+	prEvent := func(action string) string {
+		return `{"action": "` + action + `", "pull_request": {"number": 5}, "repository": {"full_name": "owner/repo"}}`
+	}
+	tests := []struct {
+		name        string
+		event       string
+		contentType string
+		body        string
+		signature   string
+		cfg         func(cfg *config.Config)
+		wantCode    int
+		wantBody    string
+	}{
+		{name: "opened", event: "pull_request", body: prEvent("opened"), wantCode: http.StatusAccepted},
+		{name: "synchronize", event: "pull_request", body: prEvent("synchronize"), wantCode: http.StatusAccepted},
+		{name: "labeled", event: "pull_request", body: prEvent("labeled"), wantCode: http.StatusAccepted},
+		{name: "closed", event: "pull_request", body: prEvent("closed"), wantCode: http.StatusOK, wantBody: "ignored: action closed"},
+		{name: "review comment", event: "pull_request_review_comment", body: prEvent("created"), wantCode: http.StatusAccepted},
+		{name: "review comment edited", event: "pull_request_review_comment", body: prEvent("edited"), wantCode: http.StatusOK, wantBody: "ignored: action edited"},
+		{name: "issue comment", event: "issue_comment", body: prEvent("created"), wantCode: http.StatusOK, wantBody: "ignored: event issue_comment"},
+		{name: "ping", event: "ping", body: `{"zen": "Keep it simple."}`, wantCode: http.StatusOK, wantBody: "ignored: ping"},
+		{
+			name:        "form payload",
+			event:       "pull_request",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "payload=" + neturl.QueryEscape(prEvent("reopened")),
+			wantCode:    http.StatusAccepted,
+		},
+		{name: "missing pull request", event: "pull_request", body: `{"action": "opened"}`, wantCode: http.StatusBadRequest},
+		{name: "wrong signature", event: "pull_request", body: prEvent("opened"), signature: sign("guess", prEvent("opened")), wantCode: http.StatusUnauthorized},
+		{name: "signature of another body", event: "pull_request", body: prEvent("opened"), signature: sign(testSecret, prEvent("closed")), wantCode: http.StatusUnauthorized},
+		{name: "no signature", event: "pull_request", body: prEvent("opened"), signature: "-", wantCode: http.StatusUnauthorized},
+		{
+			name:     "secret not configured",
+			event:    "pull_request",
+			body:     prEvent("opened"),
+			cfg:      func(cfg *config.Config) { cfg.Webhook.GitHubSecret = "" },
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "GitHub disabled",
+			event:    "pull_request",
+			body:     prEvent("opened"),
+			cfg:      func(cfg *config.Config) { cfg.GitHubConfig.Enabled = false },
+			wantCode: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			if tt.cfg != nil {
+				tt.cfg(cfg)
+			}
+			header := http.Header{"X-Github-Event": {tt.event}}
+			if tt.contentType != "" {
+				header.Set("Content-Type", tt.contentType)
+			}
+			switch tt.signature {
+			case "":
+				header.Set("X-Hub-Signature-256", sign(testSecret, tt.body))
+			case "-":
+			default:
+				header.Set("X-Hub-Signature-256", tt.signature)
+			}
+			code, body := deliver(t, cfg, http.MethodPost, GitHubPath, header, tt.body)
+			if code != tt.wantCode || !strings.Contains(body, tt.wantBody) {
+				t.Errorf("got %d %q, want %d %q", code, body, tt.wantCode, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestValidSignature(t *testing.T) {
+	body := []byte(`{"action": "opened"}`)
+	tests := []struct {
+		name      string
+		signature string
+		want      bool
+	}{
+		{"valid", sign(testSecret, string(body)), true},
+		{"other secret", sign("other", string(body)), false},
+		{"sha1 prefix", "sha1=" + strings.TrimPrefix(sign(testSecret, string(body)), "sha256="), false},
+		{"not hex", "sha256=zz", false},
+		{"truncated", sign(testSecret, string(body))[:20], false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validSignature(testSecret, tt.signature, body); got != tt.want {
+				t.Errorf("validSignature(%q) = %v, want %v", tt.signature, got, tt.want)
+			}
+		})
+	}
+}